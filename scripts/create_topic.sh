@@ -1,2 +1,3 @@
 #!/bin/bash
-docker exec kafka /opt/kafka_2.11-0.10.1.0/bin/kafka-topics.sh --create --zookeeper localhost:2181 --replication-factor 1 --partitions 12 --topic test2
+# topics are defined in topics.yaml, reconcile creates missing ones and applies changes
+cd "$(dirname "$0")/../src" && go run ./topic reconcile ../topics.yaml
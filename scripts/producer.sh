@@ -1,4 +1,4 @@
 #!/bin/bash
-# This command will run an unnamed instance of spotify/kafka linked to the kafka service,
-# start a producer, and wait for newline-delimited input until you quit (which destroys the container):
-docker run -it --rm --link kafka spotify/kafka /opt/kafka_2.11-0.10.1.0/bin/kafka-console-producer.sh --broker-list kafka:9092 --topic test
+# This command starts a producer writing newline-delimited input to the test topic until you quit,
+# pass flags of src/console/produce to change the topic or format, e.g. -format kv:
+cd "$(dirname "$0")/../src" && go run ./console/produce -topic test "$@"
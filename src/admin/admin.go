@@ -0,0 +1,309 @@
+// Package admin manages Kafka topics and consumer groups, it's used by topic and groups tooling
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// DefaultTimeout is the time brokers are given to apply topic changes
+const DefaultTimeout = 10 * time.Second
+
+// TopicSpec is the desired state of topic, Configs hold only topic level overrides
+type TopicSpec struct {
+	Name              string            `json:"name" yaml:"name"`
+	Partitions        int               `json:"partitions" yaml:"partitions"`
+	ReplicationFactor int               `json:"replication_factor" yaml:"replication_factor"`
+	Configs           map[string]string `json:"configs,omitempty" yaml:"configs,omitempty"`
+}
+
+// TopicInfo is the state of existing topic
+type TopicInfo struct {
+	Name     string `json:"name"`
+	Internal bool   `json:"internal,omitempty"`
+	// ReplicationFactor is the number of replicas of the first partition
+	ReplicationFactor int               `json:"replication_factor"`
+	Partitions        []PartitionInfo   `json:"partitions"`
+	Configs           map[string]string `json:"configs,omitempty"`
+}
+
+type PartitionInfo struct {
+	ID       int   `json:"id"`
+	Leader   int   `json:"leader"`
+	Replicas []int `json:"replicas"`
+	ISR      []int `json:"isr"`
+}
+
+// Spec returns current state of topic as spec, so it can be compared with desired one
+func (t TopicInfo) Spec() TopicSpec {
+	return TopicSpec{Name: t.Name, Partitions: len(t.Partitions), ReplicationFactor: t.ReplicationFactor, Configs: t.Configs}
+}
+
+// ErrTopicMismatch is returned when topic being created exists with different spec
+var ErrTopicMismatch = errors.New("topic exists with different spec")
+
+type Admin interface {
+	// ListTopics returns sorted names of topics, internal ones are included only if internal is true
+	ListTopics(ctx context.Context, internal bool) ([]string, error)
+	// DescribeTopics returns partitions and configs set on topics, all topics if names are empty
+	DescribeTopics(ctx context.Context, names ...string) ([]TopicInfo, error)
+	// CreateTopic creates topic, topic existing with the same spec isn't an error,
+	// created is false then, ErrTopicMismatch is returned if it differs
+	CreateTopic(ctx context.Context, spec TopicSpec) (created bool, err error)
+	DeleteTopics(ctx context.Context, names ...string) error
+	// CreatePartitions raises number of partitions of topic to count
+	CreatePartitions(ctx context.Context, topic string, count int) error
+	// AlterConfigs sets configs on topic keeping other overrides, empty value resets config to default
+	AlterConfigs(ctx context.Context, topic string, configs map[string]string) error
+}
+
+type handler struct {
+	client  *kafka.Client
+	timeout time.Duration
+}
+
+// NewAdmin returns Admin sending requests with client, client.Addr has to be set
+func NewAdmin(client *kafka.Client) Admin {
+	timeout := client.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	return &handler{client: client, timeout: timeout}
+}
+
+func (h *handler) ListTopics(ctx context.Context, internal bool) ([]string, error) {
+	meta, err := h.client.Metadata(ctx, &kafka.MetadataRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch metadata, %w", err)
+	}
+	var names []string
+	for _, t := range meta.Topics {
+		if t.Internal && !internal {
+			continue
+		}
+		names = append(names, t.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (h *handler) DescribeTopics(ctx context.Context, names ...string) ([]TopicInfo, error) {
+	req := &kafka.MetadataRequest{}
+	if len(names) > 0 {
+		req.Topics = names
+	}
+	meta, err := h.client.Metadata(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch metadata, %w", err)
+	}
+
+	var infos []TopicInfo
+	for _, t := range meta.Topics {
+		if t.Error != nil {
+			return nil, fmt.Errorf("failed to describe %s, %w", t.Name, t.Error)
+		}
+		info := TopicInfo{Name: t.Name, Internal: t.Internal}
+		for _, p := range t.Partitions {
+			info.Partitions = append(info.Partitions, PartitionInfo{
+				ID:       p.ID,
+				Leader:   p.Leader.ID,
+				Replicas: brokerIDs(p.Replicas),
+				ISR:      brokerIDs(p.Isr),
+			})
+		}
+		sort.Slice(info.Partitions, func(i, j int) bool { return info.Partitions[i].ID < info.Partitions[j].ID })
+		if len(info.Partitions) > 0 {
+			info.ReplicationFactor = len(info.Partitions[0].Replicas)
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+
+	if len(infos) == 0 {
+		return infos, nil
+	}
+	configs, err := h.topicConfigs(ctx, infos)
+	if err != nil {
+		return nil, err
+	}
+	for i := range infos {
+		infos[i].Configs = configs[infos[i].Name]
+	}
+	return infos, nil
+}
+
+// topicConfigs returns configs set on topics level
+func (h *handler) topicConfigs(ctx context.Context, topics []TopicInfo) (map[string]map[string]string, error) {
+	req := &describeConfigsRequest{}
+	for _, t := range topics {
+		req.Resources = append(req.Resources, describeConfigsResource{ResourceType: resourceTopic, ResourceName: t.Name})
+	}
+	m, err := h.roundTrip(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe configs, %w", err)
+	}
+
+	configs := make(map[string]map[string]string)
+	for _, r := range m.(*describeConfigsResponse).Resources {
+		if err := responseError(r.ErrorCode, r.ErrorMessage); err != nil {
+			return nil, fmt.Errorf("failed to describe configs of %s, %w", r.ResourceName, err)
+		}
+		for _, c := range r.Configs {
+			if !c.setOnTopic() {
+				continue
+			}
+			if configs[r.ResourceName] == nil {
+				configs[r.ResourceName] = make(map[string]string)
+			}
+			configs[r.ResourceName][c.Name] = c.Value
+		}
+	}
+	return configs, nil
+}
+
+func (h *handler) CreateTopic(ctx context.Context, spec TopicSpec) (bool, error) {
+	topic := kafka.TopicConfig{
+		Topic:             spec.Name,
+		NumPartitions:     spec.Partitions,
+		ReplicationFactor: spec.ReplicationFactor,
+	}
+	for _, name := range sortedKeys(spec.Configs) {
+		topic.ConfigEntries = append(topic.ConfigEntries, kafka.ConfigEntry{ConfigName: name, ConfigValue: spec.Configs[name]})
+	}
+	resp, err := h.client.CreateTopics(ctx, &kafka.CreateTopicsRequest{Topics: []kafka.TopicConfig{topic}})
+	if err != nil {
+		return false, fmt.Errorf("failed to create topic %s, %w", spec.Name, err)
+	}
+	err = resp.Errors[spec.Name]
+	if err == nil {
+		return true, nil
+	}
+	if !errors.Is(err, kafka.TopicAlreadyExists) {
+		return false, fmt.Errorf("failed to create topic %s, %w", spec.Name, err)
+	}
+
+	infos, err := h.DescribeTopics(ctx, spec.Name)
+	if err != nil {
+		return false, err
+	}
+	if len(infos) != 1 {
+		return false, fmt.Errorf("topic %s exists but can't be described", spec.Name)
+	}
+	if diff := Diff(spec, infos[0].Spec()); len(diff) > 0 {
+		return false, fmt.Errorf("%w %s: %v", ErrTopicMismatch, spec.Name, diff)
+	}
+	return false, nil
+}
+
+func (h *handler) DeleteTopics(ctx context.Context, names ...string) error {
+	resp, err := h.client.DeleteTopics(ctx, &kafka.DeleteTopicsRequest{Topics: names})
+	if err != nil {
+		return fmt.Errorf("failed to delete topics, %w", err)
+	}
+	for _, name := range names {
+		if err := resp.Errors[name]; err != nil {
+			return fmt.Errorf("failed to delete topic %s, %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (h *handler) CreatePartitions(ctx context.Context, topic string, count int) error {
+	m, err := h.roundTrip(ctx, &createPartitionsRequest{
+		Topics:    []createPartitionsRequestTopic{{Name: topic, Count: int32(count)}},
+		TimeoutMs: int32(h.timeout / time.Millisecond),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create partitions of %s, %w", topic, err)
+	}
+	for _, r := range m.(*createPartitionsResponse).Results {
+		if err := responseError(r.ErrorCode, r.ErrorMessage); err != nil {
+			return fmt.Errorf("failed to create partitions of %s, %w", r.Name, err)
+		}
+	}
+	return nil
+}
+
+func (h *handler) AlterConfigs(ctx context.Context, topic string, configs map[string]string) error {
+	// AlterConfigs replaces all overrides of topic, so current ones are sent along with changes
+	current, err := h.topicConfigs(ctx, []TopicInfo{{Name: topic}})
+	if err != nil {
+		return err
+	}
+	merged := current[topic]
+	if merged == nil {
+		merged = make(map[string]string)
+	}
+	for name, value := range configs {
+		if value == "" {
+			delete(merged, name)
+			continue
+		}
+		merged[name] = value
+	}
+
+	resource := alterConfigsRequestResource{ResourceType: resourceTopic, ResourceName: topic}
+	for _, name := range sortedKeys(merged) {
+		resource.Configs = append(resource.Configs, alterConfigsRequestEntry{Name: name, Value: merged[name]})
+	}
+	m, err := h.roundTrip(ctx, &alterConfigsRequest{Resources: []alterConfigsRequestResource{resource}})
+	if err != nil {
+		return fmt.Errorf("failed to alter configs of %s, %w", topic, err)
+	}
+	for _, r := range m.(*alterConfigsResponse).Responses {
+		if err := responseError(r.ErrorCode, r.ErrorMessage); err != nil {
+			return fmt.Errorf("failed to alter configs of %s, %w", r.ResourceName, err)
+		}
+	}
+	return nil
+}
+
+// roundTrip sends requests kafka.Client doesn't have methods for with its transport
+func (h *handler) roundTrip(ctx context.Context, req kafka.Request) (kafka.Response, error) {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+	transport := h.client.Transport
+	if transport == nil {
+		transport = kafka.DefaultTransport
+	}
+	return transport.RoundTrip(ctx, h.client.Addr, req)
+}
+
+// Diff lists differences of actual topic from desired spec, configs missing in desired spec aren't compared
+func Diff(desired, actual TopicSpec) []string {
+	var diff []string
+	if desired.Partitions != actual.Partitions {
+		diff = append(diff, fmt.Sprintf("partitions %d, want %d", actual.Partitions, desired.Partitions))
+	}
+	if desired.ReplicationFactor != actual.ReplicationFactor {
+		diff = append(diff, fmt.Sprintf("replication factor %d, want %d", actual.ReplicationFactor, desired.ReplicationFactor))
+	}
+	for _, name := range sortedKeys(desired.Configs) {
+		if value, ok := actual.Configs[name]; !ok || value != desired.Configs[name] {
+			diff = append(diff, fmt.Sprintf("%s %q, want %q", name, value, desired.Configs[name]))
+		}
+	}
+	return diff
+}
+
+func brokerIDs(brokers []kafka.Broker) []int {
+	ids := make([]int, len(brokers))
+	for i, b := range brokers {
+		ids[i] = b.ID
+	}
+	return ids
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
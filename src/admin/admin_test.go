@@ -0,0 +1,301 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/protocol/createtopics"
+	"github.com/segmentio/kafka-go/protocol/deletetopics"
+	"github.com/segmentio/kafka-go/protocol/metadata"
+	"github.com/segmentio/kafka-go/protocol/prototest"
+)
+
+// fakeCluster is a kafka.RoundTripper answering topic and group requests from memory, it has 3 brokers
+type fakeCluster struct {
+	mu     sync.Mutex
+	topics map[string]*TopicSpec
+	// highWatermarks are partition ends of topics, partitions start at 0
+	highWatermarks map[string][]int64
+	groups         map[string]*fakeGroup
+	// deleted topics leave metadata at once, but they can't be created again for deleteDelay create requests
+	deleteDelay int
+	deleting    map[string]int
+}
+
+func newFakeCluster(topics ...TopicSpec) *fakeCluster {
+	c := &fakeCluster{topics: make(map[string]*TopicSpec), highWatermarks: make(map[string][]int64), groups: make(map[string]*fakeGroup),
+		deleting: make(map[string]int)}
+	for i := range topics {
+		c.topics[topics[i].Name] = &topics[i]
+	}
+	return c
+}
+
+func (c *fakeCluster) admin() Admin {
+	return NewAdmin(&kafka.Client{Addr: kafka.TCP("fake:9092"), Transport: c})
+}
+
+func (c *fakeCluster) RoundTrip(_ context.Context, _ net.Addr, req kafka.Request) (kafka.Response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch r := req.(type) {
+	case *metadata.Request:
+		resp := &metadata.Response{ControllerID: 1}
+		for id := int32(1); id <= 3; id++ {
+			resp.Brokers = append(resp.Brokers, metadata.ResponseBroker{NodeID: id, Host: "fake", Port: 9092})
+		}
+		names := r.TopicNames
+		if names == nil {
+			for name := range c.topics {
+				names = append(names, name)
+			}
+			names = append(names, "__consumer_offsets")
+		}
+		for _, name := range names {
+			resp.Topics = append(resp.Topics, c.topicMetadata(name))
+		}
+		return resp, nil
+
+	case *createtopics.Request:
+		resp := &createtopics.Response{}
+		for _, t := range r.Topics {
+			result := createtopics.ResponseTopic{Name: t.Name}
+			if _, ok := c.topics[t.Name]; ok || c.deleting[t.Name] > 0 {
+				c.deleting[t.Name]--
+				result.ErrorCode = int16(kafka.TopicAlreadyExists)
+			} else {
+				spec := &TopicSpec{Name: t.Name, Partitions: int(t.NumPartitions), ReplicationFactor: int(t.ReplicationFactor)}
+				for _, cfg := range t.Configs {
+					if spec.Configs == nil {
+						spec.Configs = make(map[string]string)
+					}
+					spec.Configs[cfg.Name] = cfg.Value
+				}
+				c.topics[t.Name] = spec
+			}
+			resp.Topics = append(resp.Topics, result)
+		}
+		return resp, nil
+
+	case *deletetopics.Request:
+		resp := &deletetopics.Response{}
+		for _, name := range r.TopicNames {
+			result := deletetopics.ResponseTopic{Name: name}
+			if _, ok := c.topics[name]; !ok {
+				result.ErrorCode = int16(kafka.UnknownTopicOrPartition)
+			}
+			delete(c.topics, name)
+			c.deleting[name] = c.deleteDelay
+			resp.Responses = append(resp.Responses, result)
+		}
+		return resp, nil
+
+	case *createPartitionsRequest:
+		resp := &createPartitionsResponse{}
+		for _, t := range r.Topics {
+			result := createPartitionsResponseItem{Name: t.Name}
+			if spec, ok := c.topics[t.Name]; !ok {
+				result.ErrorCode = int16(kafka.UnknownTopicOrPartition)
+			} else if int(t.Count) <= spec.Partitions {
+				result.ErrorCode, result.ErrorMessage = int16(kafka.InvalidPartitionNumber), "partitions can only be increased"
+			} else {
+				spec.Partitions = int(t.Count)
+			}
+			resp.Results = append(resp.Results, result)
+		}
+		return resp, nil
+
+	case *describeConfigsRequest:
+		resp := &describeConfigsResponse{}
+		for _, res := range r.Resources {
+			result := describeConfigsResponseResource{ResourceType: res.ResourceType, ResourceName: res.ResourceName}
+			spec, ok := c.topics[res.ResourceName]
+			if !ok {
+				result.ErrorCode = int16(kafka.UnknownTopicOrPartition)
+			} else {
+				// brokers return defaults along with topic overrides
+				result.Configs = append(result.Configs, describeConfigsResponseEntry{Name: "cleanup.policy", Value: "delete", Source: 5})
+				for name, value := range spec.Configs {
+					result.Configs = append(result.Configs, describeConfigsResponseEntry{Name: name, Value: value, Source: configSourceTopic})
+				}
+			}
+			resp.Resources = append(resp.Resources, result)
+		}
+		return resp, nil
+
+	case *alterConfigsRequest:
+		resp := &alterConfigsResponse{}
+		for _, res := range r.Resources {
+			result := alterConfigsResponseResource{ResourceType: res.ResourceType, ResourceName: res.ResourceName}
+			if spec, ok := c.topics[res.ResourceName]; !ok {
+				result.ErrorCode = int16(kafka.UnknownTopicOrPartition)
+			} else {
+				spec.Configs = make(map[string]string)
+				for _, cfg := range res.Configs {
+					spec.Configs[cfg.Name] = cfg.Value
+				}
+			}
+			resp.Responses = append(resp.Responses, result)
+		}
+		return resp, nil
+	}
+	if resp := c.groupRoundTrip(req); resp != nil {
+		return resp, nil
+	}
+	return nil, errors.New("unexpected request " + req.ApiKey().String())
+}
+
+func (c *fakeCluster) topicMetadata(name string) metadata.ResponseTopic {
+	if name == "__consumer_offsets" {
+		return metadata.ResponseTopic{Name: name, IsInternal: true}
+	}
+	spec, ok := c.topics[name]
+	if !ok {
+		return metadata.ResponseTopic{Name: name, ErrorCode: int16(kafka.UnknownTopicOrPartition)}
+	}
+	t := metadata.ResponseTopic{Name: name}
+	for p := 0; p < spec.Partitions; p++ {
+		var replicas []int32
+		for r := 0; r < spec.ReplicationFactor; r++ {
+			replicas = append(replicas, int32((p+r)%3+1))
+		}
+		t.Partitions = append(t.Partitions, metadata.ResponsePartition{
+			PartitionIndex: int32(p), LeaderID: replicas[0], ReplicaNodes: replicas, IsrNodes: replicas,
+		})
+	}
+	return t
+}
+
+func TestCreateTopicIdempotent(t *testing.T) {
+	cluster := newFakeCluster()
+	a := cluster.admin()
+	ctx := context.Background()
+	spec := TopicSpec{Name: "currencies", Partitions: 3, ReplicationFactor: 2, Configs: map[string]string{"retention.ms": "86400000"}}
+
+	if created, err := a.CreateTopic(ctx, spec); err != nil || !created {
+		t.Fatalf("expected topic to be created, got %v, %v", created, err)
+	}
+	if created, err := a.CreateTopic(ctx, spec); err != nil || created {
+		t.Fatalf("creating the same topic again should succeed without changes, got %v, %v", created, err)
+	}
+	spec.Partitions = 6
+	if _, err := a.CreateTopic(ctx, spec); !errors.Is(err, ErrTopicMismatch) {
+		t.Fatalf("expected mismatch, got %v", err)
+	}
+}
+
+func TestDescribeAndListTopics(t *testing.T) {
+	a := newFakeCluster(
+		TopicSpec{Name: "spotify", Partitions: 2, ReplicationFactor: 3},
+		TopicSpec{Name: "currencies", Partitions: 3, ReplicationFactor: 1, Configs: map[string]string{"cleanup.policy": "compact"}},
+	).admin()
+	ctx := context.Background()
+
+	names, err := a.ListTopics(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 || names[0] != "currencies" || names[1] != "spotify" {
+		t.Fatalf("unexpected topics %v", names)
+	}
+	if all, _ := a.ListTopics(ctx, true); len(all) != 3 {
+		t.Fatalf("internal topics should be listed on request, got %v", all)
+	}
+
+	infos, err := a.DescribeTopics(ctx, "currencies", "spotify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 2 || len(infos[0].Partitions) != 3 || infos[1].ReplicationFactor != 3 {
+		t.Fatalf("unexpected topics %+v", infos)
+	}
+	// default configs aren't reported
+	if len(infos[0].Configs) != 1 || infos[0].Configs["cleanup.policy"] != "compact" || infos[1].Configs != nil {
+		t.Fatalf("unexpected configs %v, %v", infos[0].Configs, infos[1].Configs)
+	}
+	if _, err := a.DescribeTopics(ctx, "missing"); !errors.Is(err, kafka.UnknownTopicOrPartition) {
+		t.Fatalf("expected unknown topic, got %v", err)
+	}
+}
+
+func TestAlterTopic(t *testing.T) {
+	cluster := newFakeCluster(TopicSpec{Name: "currencies", Partitions: 3, ReplicationFactor: 1,
+		Configs: map[string]string{"retention.ms": "1000", "segment.ms": "100"}})
+	a := cluster.admin()
+	ctx := context.Background()
+
+	if err := a.CreatePartitions(ctx, "currencies", 6); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.CreatePartitions(ctx, "currencies", 2); !errors.Is(err, kafka.InvalidPartitionNumber) {
+		t.Fatalf("expected invalid partitions, got %v", err)
+	}
+
+	// overrides which aren't changed are kept, empty value removes override
+	if err := a.AlterConfigs(ctx, "currencies", map[string]string{"cleanup.policy": "compact", "segment.ms": ""}); err != nil {
+		t.Fatal(err)
+	}
+	got := cluster.topics["currencies"]
+	want := map[string]string{"retention.ms": "1000", "cleanup.policy": "compact"}
+	if got.Partitions != 6 || len(got.Configs) != len(want) {
+		t.Fatalf("unexpected topic %+v", got)
+	}
+	for name, value := range want {
+		if got.Configs[name] != value {
+			t.Fatalf("unexpected configs %v", got.Configs)
+		}
+	}
+
+	if err := a.DeleteTopics(ctx, "currencies"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.DeleteTopics(ctx, "currencies"); !errors.Is(err, kafka.UnknownTopicOrPartition) {
+		t.Fatalf("expected unknown topic, got %v", err)
+	}
+}
+
+func TestProtocolMessages(t *testing.T) {
+	for _, version := range []int16{0, 1} {
+		prototest.TestRequest(t, version, &createPartitionsRequest{
+			Topics:    []createPartitionsRequestTopic{{Name: "currencies", Count: 6}},
+			TimeoutMs: 1000,
+		})
+		prototest.TestResponse(t, version, &createPartitionsResponse{
+			Results: []createPartitionsResponseItem{{Name: "currencies", ErrorCode: 37, ErrorMessage: "invalid"}},
+		})
+		prototest.TestRequest(t, version, &alterConfigsRequest{
+			Resources: []alterConfigsRequestResource{{ResourceType: resourceTopic, ResourceName: "currencies",
+				Configs: []alterConfigsRequestEntry{{Name: "retention.ms", Value: "1000"}}}},
+		})
+		prototest.TestResponse(t, version, &alterConfigsResponse{
+			Responses: []alterConfigsResponseResource{{ResourceType: resourceTopic, ResourceName: "currencies"}},
+		})
+		prototest.TestRequest(t, version, &describeConfigsRequest{
+			Resources: []describeConfigsResource{{ResourceType: resourceTopic, ResourceName: "currencies"}},
+		})
+	}
+	prototest.TestResponse(t, 1, &describeConfigsResponse{
+		Resources: []describeConfigsResponseResource{{ResourceType: resourceTopic, ResourceName: "currencies",
+			Configs: []describeConfigsResponseEntry{{Name: "retention.ms", Value: "1000", Source: configSourceTopic}}}},
+	})
+}
+
+func TestDiff(t *testing.T) {
+	desired := TopicSpec{Name: "currencies", Partitions: 3, ReplicationFactor: 1, Configs: map[string]string{"retention.ms": "1000"}}
+	actual := TopicSpec{Name: "currencies", Partitions: 3, ReplicationFactor: 1, Configs: map[string]string{"retention.ms": "1000", "segment.ms": "10"}}
+	if diff := Diff(desired, actual); len(diff) != 0 {
+		t.Fatalf("configs missing in desired spec shouldn't differ, got %v", diff)
+	}
+	actual.Partitions, actual.Configs["retention.ms"] = 6, "2000"
+	diff := Diff(desired, actual)
+	sort.Strings(diff)
+	if len(diff) != 2 {
+		t.Fatalf("expected partitions and retention to differ, got %v", diff)
+	}
+}
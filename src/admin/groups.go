@@ -0,0 +1,430 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// GroupInfo is the state of consumer group
+type GroupInfo struct {
+	ID string `json:"id"`
+	// State is e.g. Stable, PreparingRebalance or Empty
+	State        string       `json:"state"`
+	ProtocolType string       `json:"protocol_type"`
+	Protocol     string       `json:"protocol"`
+	Members      []MemberInfo `json:"members"`
+}
+
+type MemberInfo struct {
+	ID       string `json:"id"`
+	ClientID string `json:"client_id"`
+	Host     string `json:"host"`
+	// Assignments are partitions of topics assigned to member
+	Assignments map[string][]int `json:"assignments"`
+}
+
+// PartitionOffset is the progress of group on partition, Committed and Lag are -1 if group hasn't committed
+type PartitionOffset struct {
+	Topic         string `json:"topic"`
+	Partition     int    `json:"partition"`
+	Committed     int64  `json:"committed"`
+	HighWatermark int64  `json:"high_watermark"`
+	Lag           int64  `json:"lag"`
+}
+
+const (
+	ResetEarliest  = "earliest"
+	ResetLatest    = "latest"
+	ResetTimestamp = "timestamp"
+	ResetOffset    = "offset"
+)
+
+// ResetTarget is the position offsets are reset to
+type ResetTarget struct {
+	// Position is ResetEarliest, ResetLatest, ResetTimestamp or ResetOffset
+	Position string    `json:"position"`
+	Time     time.Time `json:"time,omitempty"`
+	Offset   int64     `json:"offset,omitempty"`
+}
+
+// ParseResetTarget parses "earliest", "latest", RFC3339 time or offset
+func ParseResetTarget(s string) (ResetTarget, error) {
+	switch s {
+	case ResetEarliest, ResetLatest:
+		return ResetTarget{Position: s}, nil
+	}
+	if offset, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if offset < 0 {
+			return ResetTarget{}, fmt.Errorf("negative offset %d", offset)
+		}
+		return ResetTarget{Position: ResetOffset, Offset: offset}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return ResetTarget{Position: ResetTimestamp, Time: t}, nil
+	}
+	return ResetTarget{}, fmt.Errorf("invalid reset target %q, expected earliest, latest, RFC3339 time or offset", s)
+}
+
+// OffsetReset is committed offset of partition before and after reset
+type OffsetReset struct {
+	Topic     string `json:"topic"`
+	Partition int    `json:"partition"`
+	From      int64  `json:"from"`
+	To        int64  `json:"to"`
+}
+
+// ErrGroupActive is returned when offsets of group with members are reset, members would overwrite them
+var ErrGroupActive = errors.New("group has active members")
+
+type Groups interface {
+	// ListGroups returns sorted IDs of groups of all brokers
+	ListGroups(ctx context.Context) ([]string, error)
+	DescribeGroup(ctx context.Context, group string) (GroupInfo, error)
+	// Offsets returns committed offsets and lag of group on partitions of topics,
+	// if topics are empty, all topics group committed offsets for are returned
+	Offsets(ctx context.Context, group string, topics ...string) ([]PartitionOffset, error)
+	// ResetOffsets commits offsets of all partitions of topic at target, nothing is committed if dryRun is true,
+	// group must have no members
+	ResetOffsets(ctx context.Context, group, topic string, target ResetTarget, dryRun bool) ([]OffsetReset, error)
+}
+
+// NewGroups returns Groups sending requests with client, client.Addr has to be set
+func NewGroups(client *kafka.Client) Groups {
+	return NewAdmin(client).(*handler)
+}
+
+func (h *handler) ListGroups(ctx context.Context) ([]string, error) {
+	meta, err := h.client.Metadata(ctx, &kafka.MetadataRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch metadata, %w", err)
+	}
+	var groups []string
+	for _, b := range meta.Brokers {
+		m, err := h.roundTrip(ctx, &listGroupsRequest{brokerID: b.ID})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list groups of broker %d, %w", b.ID, err)
+		}
+		resp := m.(*listGroupsResponse)
+		if err := responseError(resp.ErrorCode, ""); err != nil {
+			return nil, fmt.Errorf("failed to list groups of broker %d, %w", b.ID, err)
+		}
+		for _, g := range resp.Groups {
+			groups = append(groups, g.GroupID)
+		}
+	}
+	sort.Strings(groups)
+	return groups, nil
+}
+
+func (h *handler) DescribeGroup(ctx context.Context, group string) (GroupInfo, error) {
+	m, err := h.roundTrip(ctx, &describeGroupsRequest{Groups: []string{group}})
+	if err != nil {
+		return GroupInfo{}, fmt.Errorf("failed to describe group %s, %w", group, err)
+	}
+	groups := m.(*describeGroupsResponse).Groups
+	if len(groups) != 1 {
+		return GroupInfo{}, fmt.Errorf("failed to describe group %s, got %d groups", group, len(groups))
+	}
+	g := groups[0]
+	if err := responseError(g.ErrorCode, ""); err != nil {
+		return GroupInfo{}, fmt.Errorf("failed to describe group %s, %w", group, err)
+	}
+
+	info := GroupInfo{ID: g.GroupID, State: g.GroupState, ProtocolType: g.ProtocolType, Protocol: g.ProtocolData}
+	for _, member := range g.Members {
+		mi := MemberInfo{ID: member.MemberID, ClientID: member.ClientID, Host: member.ClientHost}
+		// assignments of other protocols than consumer, e.g. connect, have different format
+		if g.ProtocolType == "consumer" && len(member.MemberAssignment) > 0 {
+			if mi.Assignments, err = decodeAssignment(member.MemberAssignment); err != nil {
+				return GroupInfo{}, fmt.Errorf("failed to decode assignment of %s, %w", member.MemberID, err)
+			}
+		}
+		info.Members = append(info.Members, mi)
+	}
+	sort.Slice(info.Members, func(i, j int) bool { return info.Members[i].ID < info.Members[j].ID })
+	return info, nil
+}
+
+func (h *handler) Offsets(ctx context.Context, group string, topics ...string) ([]PartitionOffset, error) {
+	partitions, err := h.partitions(ctx, topics...)
+	if err != nil {
+		return nil, err
+	}
+	committed, err := h.committed(ctx, group, partitions)
+	if err != nil {
+		return nil, err
+	}
+	offsets, err := h.listOffsets(ctx, partitions)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []PartitionOffset
+	for _, topic := range sortedTopics(partitions) {
+		for _, p := range partitions[topic] {
+			o := PartitionOffset{Topic: topic, Partition: p, Committed: -1, HighWatermark: offsets[topic][p].LastOffset, Lag: -1}
+			if c, ok := committed[topic][p]; ok && c >= 0 {
+				o.Committed, o.Lag = c, o.HighWatermark-c
+				if o.Lag < 0 {
+					o.Lag = 0
+				}
+			} else if len(topics) == 0 {
+				// without topics given only partitions group consumed are returned
+				continue
+			}
+			result = append(result, o)
+		}
+	}
+	return result, nil
+}
+
+func (h *handler) ResetOffsets(ctx context.Context, group, topic string, target ResetTarget, dryRun bool) ([]OffsetReset, error) {
+	info, err := h.DescribeGroup(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+	if len(info.Members) > 0 {
+		return nil, fmt.Errorf("%w, %d members have to be stopped first", ErrGroupActive, len(info.Members))
+	}
+
+	partitions, err := h.partitions(ctx, topic)
+	if err != nil {
+		return nil, err
+	}
+	committed, err := h.committed(ctx, group, partitions)
+	if err != nil {
+		return nil, err
+	}
+	offsets, err := h.listOffsets(ctx, partitions)
+	if err != nil {
+		return nil, err
+	}
+	var atTime map[string]map[int]int64
+	if target.Position == ResetTimestamp {
+		if atTime, err = h.timeOffsets(ctx, partitions, target.Time); err != nil {
+			return nil, err
+		}
+	}
+
+	var resets []OffsetReset
+	req := &offsetCommitRequest{GroupID: group, GenerationID: -1, RetentionTimeMs: -1,
+		Topics: []offsetCommitRequestTopic{{Name: topic}}}
+	for _, p := range partitions[topic] {
+		o := offsets[topic][p]
+		reset := OffsetReset{Topic: topic, Partition: p, From: -1}
+		if c, ok := committed[topic][p]; ok {
+			reset.From = c
+		}
+		switch target.Position {
+		case ResetEarliest:
+			reset.To = o.FirstOffset
+		case ResetLatest:
+			reset.To = o.LastOffset
+		case ResetTimestamp:
+			reset.To = atTime[topic][p]
+			// no message at or after time, group starts at the end
+			if reset.To < 0 {
+				reset.To = o.LastOffset
+			}
+		case ResetOffset:
+			// offsets outside of the log are moved to its nearest end
+			reset.To = target.Offset
+			if reset.To < o.FirstOffset {
+				reset.To = o.FirstOffset
+			}
+			if reset.To > o.LastOffset {
+				reset.To = o.LastOffset
+			}
+		default:
+			return nil, fmt.Errorf("unknown reset position %q", target.Position)
+		}
+		resets = append(resets, reset)
+		req.Topics[0].Partitions = append(req.Topics[0].Partitions,
+			offsetCommitRequestPartition{PartitionIndex: int32(p), CommittedOffset: reset.To})
+	}
+	if dryRun {
+		return resets, nil
+	}
+
+	m, err := h.roundTrip(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit offsets of group %s, %w", group, err)
+	}
+	for _, t := range m.(*offsetCommitResponse).Topics {
+		for _, p := range t.Partitions {
+			if err := responseError(p.ErrorCode, ""); err != nil {
+				return nil, fmt.Errorf("failed to commit offset of %s/%d, %w", t.Name, p.PartitionIndex, err)
+			}
+		}
+	}
+	return resets, nil
+}
+
+// partitions returns sorted partitions of topics, of all non internal topics if topics are empty
+func (h *handler) partitions(ctx context.Context, topics ...string) (map[string][]int, error) {
+	req := &kafka.MetadataRequest{}
+	if len(topics) > 0 {
+		req.Topics = topics
+	}
+	meta, err := h.client.Metadata(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch metadata, %w", err)
+	}
+	partitions := make(map[string][]int)
+	for _, t := range meta.Topics {
+		if t.Error != nil {
+			return nil, fmt.Errorf("failed to fetch metadata of %s, %w", t.Name, t.Error)
+		}
+		if t.Internal && len(topics) == 0 {
+			continue
+		}
+		for _, p := range t.Partitions {
+			partitions[t.Name] = append(partitions[t.Name], p.ID)
+		}
+		sort.Ints(partitions[t.Name])
+	}
+	return partitions, nil
+}
+
+// committed returns offsets committed by group, partitions without commit are missing
+func (h *handler) committed(ctx context.Context, group string, partitions map[string][]int) (map[string]map[int]int64, error) {
+	resp, err := h.client.OffsetFetch(ctx, &kafka.OffsetFetchRequest{GroupID: group, Topics: partitions})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch offsets of group %s, %w", group, err)
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("failed to fetch offsets of group %s, %w", group, resp.Error)
+	}
+	committed := make(map[string]map[int]int64)
+	for topic, offsets := range resp.Topics {
+		committed[topic] = make(map[int]int64)
+		for _, o := range offsets {
+			if o.Error != nil {
+				return nil, fmt.Errorf("failed to fetch offset of %s/%d, %w", topic, o.Partition, o.Error)
+			}
+			if o.CommittedOffset >= 0 {
+				committed[topic][o.Partition] = o.CommittedOffset
+			}
+		}
+	}
+	return committed, nil
+}
+
+// listOffsets returns first and last offsets of partitions
+func (h *handler) listOffsets(ctx context.Context, partitions map[string][]int) (map[string]map[int]kafka.PartitionOffsets, error) {
+	req := &kafka.ListOffsetsRequest{Topics: make(map[string][]kafka.OffsetRequest)}
+	for topic, ps := range partitions {
+		for _, p := range ps {
+			req.Topics[topic] = append(req.Topics[topic], kafka.FirstOffsetOf(p), kafka.LastOffsetOf(p))
+		}
+	}
+	resp, err := h.client.ListOffsets(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list offsets, %w", err)
+	}
+	offsets := make(map[string]map[int]kafka.PartitionOffsets)
+	for topic, ps := range resp.Topics {
+		offsets[topic] = make(map[int]kafka.PartitionOffsets)
+		for _, p := range ps {
+			if p.Error != nil {
+				return nil, fmt.Errorf("failed to list offsets of %s/%d, %w", topic, p.Partition, p.Error)
+			}
+			offsets[topic][p.Partition] = p
+		}
+	}
+	return offsets, nil
+}
+
+// timeOffsets returns offsets of the first messages at or after time, -1 for partitions without such message,
+// it's separate request from listOffsets, because kafka-go takes response without message for the last offset
+func (h *handler) timeOffsets(ctx context.Context, partitions map[string][]int, at time.Time) (map[string]map[int]int64, error) {
+	req := &kafka.ListOffsetsRequest{Topics: make(map[string][]kafka.OffsetRequest)}
+	for topic, ps := range partitions {
+		for _, p := range ps {
+			req.Topics[topic] = append(req.Topics[topic], kafka.TimeOffsetOf(p, at))
+		}
+	}
+	resp, err := h.client.ListOffsets(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list offsets at %s, %w", at.Format(time.RFC3339), err)
+	}
+	offsets := make(map[string]map[int]int64)
+	for topic, ps := range resp.Topics {
+		offsets[topic] = make(map[int]int64)
+		for _, p := range ps {
+			if p.Error != nil {
+				return nil, fmt.Errorf("failed to list offsets of %s/%d, %w", topic, p.Partition, p.Error)
+			}
+			offsets[topic][p.Partition] = -1
+			for offset := range p.Offsets {
+				offsets[topic][p.Partition] = offset
+			}
+		}
+	}
+	return offsets, nil
+}
+
+// decodeAssignment decodes assignment of consumer protocol:
+// version int16, topics [name string, partitions [int32]], user data bytes
+func decodeAssignment(b []byte) (map[string][]int, error) {
+	r := bytes.NewReader(b)
+	var version int16
+	var topics int32
+	if err := binary.Read(r, binary.BigEndian, &version); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(r, binary.BigEndian, &topics); err != nil {
+		return nil, err
+	}
+	// lengths are checked against remaining bytes, so malformed assignment can't allocate much
+	if topics < 0 || int(topics) > r.Len() {
+		return nil, fmt.Errorf("invalid number of topics %d", topics)
+	}
+	assignments := make(map[string][]int, topics)
+	for i := int32(0); i < topics; i++ {
+		var length int16
+		if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+			return nil, err
+		}
+		if length < 0 || int(length) > r.Len() {
+			return nil, fmt.Errorf("invalid topic name length %d", length)
+		}
+		name := make([]byte, length)
+		if _, err := io.ReadFull(r, name); err != nil {
+			return nil, err
+		}
+		var count int32
+		if err := binary.Read(r, binary.BigEndian, &count); err != nil {
+			return nil, err
+		}
+		if count < 0 || int(count) > r.Len()/4 {
+			return nil, fmt.Errorf("invalid number of partitions %d", count)
+		}
+		partitions := make([]int32, count)
+		if err := binary.Read(r, binary.BigEndian, partitions); err != nil {
+			return nil, err
+		}
+		for _, p := range partitions {
+			assignments[string(name)] = append(assignments[string(name)], int(p))
+		}
+	}
+	return assignments, nil
+}
+
+func sortedTopics(partitions map[string][]int) []string {
+	topics := make([]string, 0, len(partitions))
+	for t := range partitions {
+		topics = append(topics, t)
+	}
+	sort.Strings(topics)
+	return topics
+}
@@ -0,0 +1,280 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/protocol/listoffsets"
+	"github.com/segmentio/kafka-go/protocol/offsetfetch"
+	"github.com/segmentio/kafka-go/protocol/prototest"
+)
+
+type fakeGroup struct {
+	members   []describeGroupsResponseMember
+	committed map[string]map[int32]int64
+}
+
+// groupRoundTrip answers group and offset requests, nil is returned for other requests,
+// message at offset n of fake log has timestamp of n seconds
+func (c *fakeCluster) groupRoundTrip(req kafka.Request) kafka.Response {
+	switch r := req.(type) {
+	case *listGroupsRequest:
+		resp := &listGroupsResponse{}
+		// broker 1 coordinates all groups
+		if r.brokerID == 1 {
+			for id := range c.groups {
+				resp.Groups = append(resp.Groups, listGroupsResponseGroup{GroupID: id, ProtocolType: "consumer"})
+			}
+		}
+		return resp
+
+	case *describeGroupsRequest:
+		resp := &describeGroupsResponse{}
+		for _, id := range r.Groups {
+			result := describeGroupsResponseGroup{GroupID: id, GroupState: "Dead"}
+			if g, ok := c.groups[id]; ok {
+				result.GroupState, result.ProtocolType, result.ProtocolData = "Empty", "consumer", "range"
+				if len(g.members) > 0 {
+					result.GroupState, result.Members = "Stable", g.members
+				}
+			}
+			resp.Groups = append(resp.Groups, result)
+		}
+		return resp
+
+	case *offsetfetch.Request:
+		resp := &offsetfetch.Response{}
+		var committed map[string]map[int32]int64
+		if g, ok := c.groups[r.GroupID]; ok {
+			committed = g.committed
+		}
+		for _, t := range r.Topics {
+			topic := offsetfetch.ResponseTopic{Name: t.Name}
+			for _, p := range t.PartitionIndexes {
+				offset, ok := committed[t.Name][p]
+				if !ok {
+					offset = -1
+				}
+				topic.Partitions = append(topic.Partitions, offsetfetch.ResponsePartition{PartitionIndex: p, CommittedOffset: offset})
+			}
+			resp.Topics = append(resp.Topics, topic)
+		}
+		return resp
+
+	case *listoffsets.Request:
+		resp := &listoffsets.Response{}
+		for _, t := range r.Topics {
+			topic := listoffsets.ResponseTopic{Topic: t.Topic}
+			for _, p := range t.Partitions {
+				hw := c.highWatermarks[t.Topic][p.Partition]
+				result := listoffsets.ResponsePartition{Partition: p.Partition, Timestamp: p.Timestamp}
+				switch p.Timestamp {
+				case kafka.FirstOffset:
+				case kafka.LastOffset:
+					result.Offset = hw
+				default:
+					result.Offset = (p.Timestamp + 999) / 1000
+					if result.Offset >= hw {
+						result.Offset, result.Timestamp = -1, -1
+					}
+				}
+				topic.Partitions = append(topic.Partitions, result)
+			}
+			resp.Topics = append(resp.Topics, topic)
+		}
+		return resp
+
+	case *offsetCommitRequest:
+		resp := &offsetCommitResponse{}
+		g, ok := c.groups[r.GroupID]
+		if !ok {
+			g = &fakeGroup{committed: make(map[string]map[int32]int64)}
+			c.groups[r.GroupID] = g
+		}
+		for _, t := range r.Topics {
+			topic := offsetCommitResponseTopic{Name: t.Name}
+			for _, p := range t.Partitions {
+				result := offsetCommitResponsePartition{PartitionIndex: p.PartitionIndex}
+				if len(g.members) > 0 && r.GenerationID == -1 {
+					result.ErrorCode = int16(kafka.UnknownMemberId)
+				} else {
+					if g.committed[t.Name] == nil {
+						g.committed[t.Name] = make(map[int32]int64)
+					}
+					g.committed[t.Name][p.PartitionIndex] = p.CommittedOffset
+				}
+				topic.Partitions = append(topic.Partitions, result)
+			}
+			resp.Topics = append(resp.Topics, topic)
+		}
+		return resp
+	}
+	return nil
+}
+
+// encodeAssignment encodes assignment of consumer protocol
+func encodeAssignment(assignments map[string][]int32) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, int16(0))
+	binary.Write(&buf, binary.BigEndian, int32(len(assignments)))
+	for name, partitions := range assignments {
+		binary.Write(&buf, binary.BigEndian, int16(len(name)))
+		buf.WriteString(name)
+		binary.Write(&buf, binary.BigEndian, int32(len(partitions)))
+		binary.Write(&buf, binary.BigEndian, partitions)
+	}
+	binary.Write(&buf, binary.BigEndian, int32(-1))
+	return buf.Bytes()
+}
+
+func newGroupsCluster() *fakeCluster {
+	cluster := newFakeCluster(
+		TopicSpec{Name: "currencies", Partitions: 3, ReplicationFactor: 1},
+		TopicSpec{Name: "spotify", Partitions: 2, ReplicationFactor: 1},
+	)
+	cluster.highWatermarks["currencies"] = []int64{100, 50, 0}
+	cluster.highWatermarks["spotify"] = []int64{10, 10}
+	cluster.groups["consumer-group"] = &fakeGroup{
+		members: []describeGroupsResponseMember{
+			{MemberID: "consumer-2", ClientID: "rates", ClientHost: "/10.0.0.2",
+				MemberAssignment: encodeAssignment(map[string][]int32{"currencies": {2}})},
+			{MemberID: "consumer-1", ClientID: "rates", ClientHost: "/10.0.0.1",
+				MemberAssignment: encodeAssignment(map[string][]int32{"currencies": {0, 1}})},
+		},
+		committed: map[string]map[int32]int64{"currencies": {0: 90, 1: 50}},
+	}
+	cluster.groups["replay"] = &fakeGroup{committed: map[string]map[int32]int64{"spotify": {0: 4}}}
+	return cluster
+}
+
+func TestDescribeGroups(t *testing.T) {
+	g := NewGroups(&kafka.Client{Addr: kafka.TCP("fake:9092"), Transport: newGroupsCluster()})
+	ctx := context.Background()
+
+	groups, err := g.ListGroups(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 2 || groups[0] != "consumer-group" || groups[1] != "replay" {
+		t.Fatalf("unexpected groups %v", groups)
+	}
+
+	info, err := g.DescribeGroup(ctx, "consumer-group")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.State != "Stable" || len(info.Members) != 2 || info.Members[0].ID != "consumer-1" {
+		t.Fatalf("unexpected group %+v", info)
+	}
+	if a := info.Members[0].Assignments["currencies"]; len(a) != 2 || a[0] != 0 || a[1] != 1 {
+		t.Fatalf("unexpected assignments %v", info.Members[0].Assignments)
+	}
+
+	offsets, err := g.Offsets(ctx, "consumer-group")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []PartitionOffset{
+		{Topic: "currencies", Partition: 0, Committed: 90, HighWatermark: 100, Lag: 10},
+		{Topic: "currencies", Partition: 1, Committed: 50, HighWatermark: 50, Lag: 0},
+	}
+	if len(offsets) != len(want) || offsets[0] != want[0] || offsets[1] != want[1] {
+		t.Fatalf("expected %+v, got %+v", want, offsets)
+	}
+	// partitions without commit are returned for topics given explicitly
+	offsets, err = g.Offsets(ctx, "consumer-group", "currencies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(offsets) != 3 || offsets[2].Committed != -1 || offsets[2].Lag != -1 {
+		t.Fatalf("unexpected offsets %+v", offsets)
+	}
+}
+
+func TestResetOffsets(t *testing.T) {
+	cluster := newGroupsCluster()
+	g := NewGroups(&kafka.Client{Addr: kafka.TCP("fake:9092"), Transport: cluster})
+	ctx := context.Background()
+
+	if _, err := g.ResetOffsets(ctx, "consumer-group", "currencies", ResetTarget{Position: ResetEarliest}, true); !errors.Is(err, ErrGroupActive) {
+		t.Fatalf("expected active group error, got %v", err)
+	}
+
+	for _, tc := range []struct {
+		target ResetTarget
+		want   []int64
+	}{
+		{ResetTarget{Position: ResetLatest}, []int64{10, 10}},
+		{ResetTarget{Position: ResetOffset, Offset: 7}, []int64{7, 7}},
+		{ResetTarget{Position: ResetOffset, Offset: 70}, []int64{10, 10}},
+		{ResetTarget{Position: ResetTimestamp, Time: time.Unix(2, 5e8)}, []int64{3, 3}},
+		{ResetTarget{Position: ResetTimestamp, Time: time.Unix(60, 0)}, []int64{10, 10}},
+		{ResetTarget{Position: ResetEarliest}, []int64{0, 0}},
+	} {
+		resets, err := g.ResetOffsets(ctx, "replay", "spotify", tc.target, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(resets) != 2 || resets[0].From != 4 || resets[1].From != -1 ||
+			resets[0].To != tc.want[0] || resets[1].To != tc.want[1] {
+			t.Fatalf("%+v: expected %v, got %+v", tc.target, tc.want, resets)
+		}
+	}
+	if cluster.groups["replay"].committed["spotify"][0] != 4 {
+		t.Fatal("dry run shouldn't commit offsets")
+	}
+
+	if _, err := g.ResetOffsets(ctx, "replay", "spotify", ResetTarget{Position: ResetLatest}, false); err != nil {
+		t.Fatal(err)
+	}
+	if c := cluster.groups["replay"].committed["spotify"]; c[0] != 10 || c[1] != 10 {
+		t.Fatalf("unexpected committed offsets %v", c)
+	}
+}
+
+func TestParseResetTarget(t *testing.T) {
+	for s, want := range map[string]ResetTarget{
+		"earliest":             {Position: ResetEarliest},
+		"latest":               {Position: ResetLatest},
+		"42":                   {Position: ResetOffset, Offset: 42},
+		"2020-11-01T10:00:00Z": {Position: ResetTimestamp, Time: time.Date(2020, 11, 1, 10, 0, 0, 0, time.UTC)},
+	} {
+		got, err := ParseResetTarget(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Position != want.Position || got.Offset != want.Offset || !got.Time.Equal(want.Time) {
+			t.Fatalf("%s: expected %+v, got %+v", s, want, got)
+		}
+	}
+	for _, s := range []string{"-1", "yesterday", ""} {
+		if _, err := ParseResetTarget(s); err == nil {
+			t.Fatalf("expected %q to be invalid", s)
+		}
+	}
+}
+
+func TestGroupProtocolMessages(t *testing.T) {
+	for _, version := range []int16{0, 1, 2} {
+		prototest.TestResponse(t, version, &listGroupsResponse{
+			Groups: []listGroupsResponseGroup{{GroupID: "consumer-group", ProtocolType: "consumer"}},
+		})
+		prototest.TestRequest(t, version, &describeGroupsRequest{Groups: []string{"consumer-group"}})
+		prototest.TestResponse(t, version, &describeGroupsResponse{
+			Groups: []describeGroupsResponseGroup{{GroupID: "consumer-group", GroupState: "Stable", ProtocolType: "consumer",
+				Members: []describeGroupsResponseMember{{MemberID: "consumer-1", MemberAssignment: []byte{0, 1}}}}},
+		})
+	}
+	for _, version := range []int16{2, 3} {
+		prototest.TestRequest(t, version, &offsetCommitRequest{GroupID: "consumer-group", GenerationID: -1, RetentionTimeMs: -1,
+			Topics: []offsetCommitRequestTopic{{Name: "currencies", Partitions: []offsetCommitRequestPartition{{PartitionIndex: 1, CommittedOffset: 10}}}}})
+		prototest.TestResponse(t, version, &offsetCommitResponse{
+			Topics: []offsetCommitResponseTopic{{Name: "currencies", Partitions: []offsetCommitResponsePartition{{PartitionIndex: 1}}}},
+		})
+	}
+}
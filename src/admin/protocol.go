@@ -0,0 +1,263 @@
+package admin
+
+import (
+	"fmt"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/protocol"
+)
+
+// kafka-go client of this version has no CreatePartitions, DescribeConfigs, AlterConfigs
+// and consumer group APIs apart from OffsetFetch,
+// the messages below are registered in its protocol package, so kafka.Transport negotiates versions,
+// encodes and routes them as any other request
+
+func init() {
+	protocol.Register(&createPartitionsRequest{}, &createPartitionsResponse{})
+	protocol.Register(&describeConfigsRequest{}, &describeConfigsResponse{})
+	protocol.Register(&alterConfigsRequest{}, &alterConfigsResponse{})
+	protocol.Register(&listGroupsRequest{}, &listGroupsResponse{})
+	protocol.Register(&describeGroupsRequest{}, &describeGroupsResponse{})
+	protocol.Register(&offsetCommitRequest{}, &offsetCommitResponse{})
+}
+
+// resourceTopic is the resource type of topics in config requests
+const resourceTopic int8 = 2
+
+// configSourceTopic is the source of configs set on topic, see DescribeConfigs v1
+const configSourceTopic int8 = 1
+
+type createPartitionsRequest struct {
+	Topics       []createPartitionsRequestTopic `kafka:"min=v0,max=v1"`
+	TimeoutMs    int32                          `kafka:"min=v0,max=v1"`
+	ValidateOnly bool                           `kafka:"min=v0,max=v1"`
+}
+
+func (r *createPartitionsRequest) ApiKey() protocol.ApiKey { return protocol.CreatePartitions }
+
+// Broker sends request to controller, other brokers reject it
+func (r *createPartitionsRequest) Broker(cluster protocol.Cluster) (protocol.Broker, error) {
+	return cluster.Brokers[cluster.Controller], nil
+}
+
+type createPartitionsRequestTopic struct {
+	Name  string `kafka:"min=v0,max=v1"`
+	Count int32  `kafka:"min=v0,max=v1"`
+	// Assignments are left to brokers when nil
+	Assignments []createPartitionsAssignment `kafka:"min=v0,max=v1,nullable"`
+}
+
+type createPartitionsAssignment struct {
+	BrokerIDs []int32 `kafka:"min=v0,max=v1"`
+}
+
+type createPartitionsResponse struct {
+	ThrottleTimeMs int32                          `kafka:"min=v0,max=v1"`
+	Results        []createPartitionsResponseItem `kafka:"min=v0,max=v1"`
+}
+
+func (r *createPartitionsResponse) ApiKey() protocol.ApiKey { return protocol.CreatePartitions }
+
+type createPartitionsResponseItem struct {
+	Name         string `kafka:"min=v0,max=v1"`
+	ErrorCode    int16  `kafka:"min=v0,max=v1"`
+	ErrorMessage string `kafka:"min=v0,max=v1,nullable"`
+}
+
+type describeConfigsRequest struct {
+	Resources       []describeConfigsResource `kafka:"min=v0,max=v1"`
+	IncludeSynonyms bool                      `kafka:"min=v1,max=v1"`
+}
+
+func (r *describeConfigsRequest) ApiKey() protocol.ApiKey { return protocol.DescribeConfigs }
+
+type describeConfigsResource struct {
+	ResourceType int8   `kafka:"min=v0,max=v1"`
+	ResourceName string `kafka:"min=v0,max=v1"`
+	// ConfigNames nil describes all configs
+	ConfigNames []string `kafka:"min=v0,max=v1,nullable"`
+}
+
+type describeConfigsResponse struct {
+	ThrottleTimeMs int32                             `kafka:"min=v0,max=v1"`
+	Resources      []describeConfigsResponseResource `kafka:"min=v0,max=v1"`
+}
+
+func (r *describeConfigsResponse) ApiKey() protocol.ApiKey { return protocol.DescribeConfigs }
+
+type describeConfigsResponseResource struct {
+	ErrorCode    int16                          `kafka:"min=v0,max=v1"`
+	ErrorMessage string                         `kafka:"min=v0,max=v1,nullable"`
+	ResourceType int8                           `kafka:"min=v0,max=v1"`
+	ResourceName string                         `kafka:"min=v0,max=v1"`
+	Configs      []describeConfigsResponseEntry `kafka:"min=v0,max=v1"`
+}
+
+type describeConfigsResponseEntry struct {
+	Name      string `kafka:"min=v0,max=v1"`
+	Value     string `kafka:"min=v0,max=v1,nullable"`
+	ReadOnly  bool   `kafka:"min=v0,max=v1"`
+	IsDefault bool   `kafka:"min=v0,max=v0"`
+	Source    int8   `kafka:"min=v1,max=v1"`
+	Sensitive bool   `kafka:"min=v0,max=v1"`
+	// Synonyms are never requested, the field is decoded only to keep the response in sync
+	Synonyms []describeConfigsSynonym `kafka:"min=v1,max=v1"`
+}
+
+// setOnTopic tells whether config is overridden on topic level, v0 tells only if it's default
+func (e describeConfigsResponseEntry) setOnTopic() bool {
+	return e.Source == configSourceTopic || e.Source == 0 && !e.IsDefault
+}
+
+type describeConfigsSynonym struct {
+	Name   string `kafka:"min=v1,max=v1"`
+	Value  string `kafka:"min=v1,max=v1,nullable"`
+	Source int8   `kafka:"min=v1,max=v1"`
+}
+
+type alterConfigsRequest struct {
+	Resources    []alterConfigsRequestResource `kafka:"min=v0,max=v1"`
+	ValidateOnly bool                          `kafka:"min=v0,max=v1"`
+}
+
+func (r *alterConfigsRequest) ApiKey() protocol.ApiKey { return protocol.AlterConfigs }
+
+type alterConfigsRequestResource struct {
+	ResourceType int8                       `kafka:"min=v0,max=v1"`
+	ResourceName string                     `kafka:"min=v0,max=v1"`
+	Configs      []alterConfigsRequestEntry `kafka:"min=v0,max=v1"`
+}
+
+type alterConfigsRequestEntry struct {
+	Name  string `kafka:"min=v0,max=v1"`
+	Value string `kafka:"min=v0,max=v1,nullable"`
+}
+
+type alterConfigsResponse struct {
+	ThrottleTimeMs int32                          `kafka:"min=v0,max=v1"`
+	Responses      []alterConfigsResponseResource `kafka:"min=v0,max=v1"`
+}
+
+func (r *alterConfigsResponse) ApiKey() protocol.ApiKey { return protocol.AlterConfigs }
+
+type alterConfigsResponseResource struct {
+	ErrorCode    int16  `kafka:"min=v0,max=v1"`
+	ErrorMessage string `kafka:"min=v0,max=v1,nullable"`
+	ResourceType int8   `kafka:"min=v0,max=v1"`
+	ResourceName string `kafka:"min=v0,max=v1"`
+}
+
+// listGroupsRequest lists groups coordinated by one broker, so it's sent to every broker
+type listGroupsRequest struct {
+	// request has no fields, the blank one declares its versions
+	_        struct{} `kafka:"min=v0,max=v2"`
+	brokerID int
+}
+
+func (r *listGroupsRequest) ApiKey() protocol.ApiKey { return protocol.ListGroups }
+
+func (r *listGroupsRequest) Broker(cluster protocol.Cluster) (protocol.Broker, error) {
+	b, ok := cluster.Brokers[r.brokerID]
+	if !ok {
+		return b, fmt.Errorf("unknown broker %d", r.brokerID)
+	}
+	return b, nil
+}
+
+type listGroupsResponse struct {
+	ThrottleTimeMs int32                     `kafka:"min=v1,max=v2"`
+	ErrorCode      int16                     `kafka:"min=v0,max=v2"`
+	Groups         []listGroupsResponseGroup `kafka:"min=v0,max=v2"`
+}
+
+func (r *listGroupsResponse) ApiKey() protocol.ApiKey { return protocol.ListGroups }
+
+type listGroupsResponseGroup struct {
+	GroupID      string `kafka:"min=v0,max=v2"`
+	ProtocolType string `kafka:"min=v0,max=v2"`
+}
+
+// describeGroupsRequest is sent to coordinator of the first group, so it should describe one group
+type describeGroupsRequest struct {
+	Groups []string `kafka:"min=v0,max=v2"`
+}
+
+func (r *describeGroupsRequest) ApiKey() protocol.ApiKey { return protocol.DescribeGroups }
+
+func (r *describeGroupsRequest) Group() string { return r.Groups[0] }
+
+type describeGroupsResponse struct {
+	ThrottleTimeMs int32                         `kafka:"min=v1,max=v2"`
+	Groups         []describeGroupsResponseGroup `kafka:"min=v0,max=v2"`
+}
+
+func (r *describeGroupsResponse) ApiKey() protocol.ApiKey { return protocol.DescribeGroups }
+
+type describeGroupsResponseGroup struct {
+	ErrorCode    int16                          `kafka:"min=v0,max=v2"`
+	GroupID      string                         `kafka:"min=v0,max=v2"`
+	GroupState   string                         `kafka:"min=v0,max=v2"`
+	ProtocolType string                         `kafka:"min=v0,max=v2"`
+	ProtocolData string                         `kafka:"min=v0,max=v2"`
+	Members      []describeGroupsResponseMember `kafka:"min=v0,max=v2"`
+}
+
+type describeGroupsResponseMember struct {
+	MemberID         string `kafka:"min=v0,max=v2"`
+	ClientID         string `kafka:"min=v0,max=v2"`
+	ClientHost       string `kafka:"min=v0,max=v2"`
+	MemberMetadata   []byte `kafka:"min=v0,max=v2"`
+	MemberAssignment []byte `kafka:"min=v0,max=v2"`
+}
+
+// offsetCommitRequest starts at v2, the first version with retention time set by broker
+type offsetCommitRequest struct {
+	GroupID         string                     `kafka:"min=v2,max=v3"`
+	GenerationID    int32                      `kafka:"min=v2,max=v3"`
+	MemberID        string                     `kafka:"min=v2,max=v3"`
+	RetentionTimeMs int64                      `kafka:"min=v2,max=v3"`
+	Topics          []offsetCommitRequestTopic `kafka:"min=v2,max=v3"`
+}
+
+func (r *offsetCommitRequest) ApiKey() protocol.ApiKey { return protocol.OffsetCommit }
+
+func (r *offsetCommitRequest) Group() string { return r.GroupID }
+
+type offsetCommitRequestTopic struct {
+	Name       string                         `kafka:"min=v2,max=v3"`
+	Partitions []offsetCommitRequestPartition `kafka:"min=v2,max=v3"`
+}
+
+type offsetCommitRequestPartition struct {
+	PartitionIndex    int32  `kafka:"min=v2,max=v3"`
+	CommittedOffset   int64  `kafka:"min=v2,max=v3"`
+	CommittedMetadata string `kafka:"min=v2,max=v3,nullable"`
+}
+
+type offsetCommitResponse struct {
+	ThrottleTimeMs int32                       `kafka:"min=v3,max=v3"`
+	Topics         []offsetCommitResponseTopic `kafka:"min=v2,max=v3"`
+}
+
+func (r *offsetCommitResponse) ApiKey() protocol.ApiKey { return protocol.OffsetCommit }
+
+type offsetCommitResponseTopic struct {
+	Name       string                          `kafka:"min=v2,max=v3"`
+	Partitions []offsetCommitResponsePartition `kafka:"min=v2,max=v3"`
+}
+
+type offsetCommitResponsePartition struct {
+	PartitionIndex int32 `kafka:"min=v2,max=v3"`
+	ErrorCode      int16 `kafka:"min=v2,max=v3"`
+}
+
+// responseError returns kafka error of code with broker's message, nil if code is 0
+func responseError(code int16, message string) error {
+	if code == 0 {
+		return nil
+	}
+	if message == "" {
+		return kafka.Error(code)
+	}
+	return fmt.Errorf("%w: %s", kafka.Error(code), message)
+}
@@ -0,0 +1,211 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"gopkg.in/yaml.v2"
+)
+
+// Manifest is the desired state of topics, topics missing in it aren't touched
+type Manifest struct {
+	Topics []TopicSpec `yaml:"topics"`
+}
+
+// LoadManifest reads and validates YAML manifest, see topics.yaml in repository root
+func LoadManifest(path string) (Manifest, error) {
+	var m Manifest
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return m, fmt.Errorf("failed to read manifest, %w", err)
+	}
+	if err := yaml.UnmarshalStrict(data, &m); err != nil {
+		return m, fmt.Errorf("failed to parse manifest %s, %w", path, err)
+	}
+	return m, m.Validate()
+}
+
+func (m Manifest) Validate() error {
+	seen := make(map[string]bool)
+	for _, t := range m.Topics {
+		switch {
+		case t.Name == "":
+			return errors.New("topic without name")
+		case seen[t.Name]:
+			return fmt.Errorf("topic %s defined twice", t.Name)
+		case t.Partitions <= 0 || t.ReplicationFactor <= 0:
+			return fmt.Errorf("topic %s needs positive partitions and replication_factor", t.Name)
+		}
+		seen[t.Name] = true
+	}
+	return nil
+}
+
+type Action string
+
+const (
+	ActionCreate        Action = "create"
+	ActionAddPartitions Action = "add-partitions"
+	ActionAlterConfigs  Action = "alter-configs"
+	// ActionRecreate deletes topic with its messages and creates it again,
+	// it's the only way to decrease partitions or change replication factor
+	ActionRecreate Action = "recreate"
+)
+
+// Change is a step bringing topic to its spec
+type Change struct {
+	Action Action    `json:"action"`
+	Spec   TopicSpec `json:"spec"`
+	// Reasons are differences of topic from spec, empty for new topics
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// Destructive tells whether change loses messages
+func (c Change) Destructive() bool {
+	return c.Action == ActionRecreate
+}
+
+// ErrDestructive is returned by Apply if plan has destructive changes and isn't forced
+var ErrDestructive = errors.New("plan has destructive changes")
+
+// Plan compares manifest with cluster and returns changes needed, in order of manifest
+func Plan(ctx context.Context, a Admin, m Manifest) ([]Change, error) {
+	names, err := a.ListTopics(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]bool, len(names))
+	for _, name := range names {
+		existing[name] = true
+	}
+	var describe []string
+	for _, t := range m.Topics {
+		if existing[t.Name] {
+			describe = append(describe, t.Name)
+		}
+	}
+	var infos []TopicInfo
+	if len(describe) > 0 {
+		if infos, err = a.DescribeTopics(ctx, describe...); err != nil {
+			return nil, err
+		}
+	}
+	return PlanChanges(m, infos), nil
+}
+
+// PlanChanges returns changes turning actual topics into ones in manifest
+func PlanChanges(m Manifest, actual []TopicInfo) []Change {
+	current := make(map[string]TopicSpec, len(actual))
+	for _, t := range actual {
+		current[t.Name] = t.Spec()
+	}
+
+	var changes []Change
+	for _, desired := range m.Topics {
+		actual, ok := current[desired.Name]
+		if !ok {
+			changes = append(changes, Change{Action: ActionCreate, Spec: desired})
+			continue
+		}
+		diff := Diff(desired, actual)
+		if len(diff) == 0 {
+			continue
+		}
+		if desired.Partitions < actual.Partitions || desired.ReplicationFactor != actual.ReplicationFactor {
+			changes = append(changes, Change{Action: ActionRecreate, Spec: desired, Reasons: diff})
+			continue
+		}
+		if desired.Partitions > actual.Partitions {
+			changes = append(changes, Change{Action: ActionAddPartitions, Spec: desired,
+				Reasons: Diff(TopicSpec{Partitions: desired.Partitions}, TopicSpec{Partitions: actual.Partitions})})
+		}
+		if reasons := Diff(TopicSpec{Configs: desired.Configs}, TopicSpec{Configs: actual.Configs}); len(reasons) > 0 {
+			changes = append(changes, Change{Action: ActionAlterConfigs, Spec: desired, Reasons: reasons})
+		}
+	}
+	return changes
+}
+
+// Apply applies changes in order, nothing is applied if there are destructive changes and force is false
+func Apply(ctx context.Context, a Admin, changes []Change, force bool) error {
+	if !force {
+		var destructive []string
+		for _, c := range changes {
+			if c.Destructive() {
+				destructive = append(destructive, c.Spec.Name)
+			}
+		}
+		if len(destructive) > 0 {
+			return fmt.Errorf("%w, topics %v would lose messages", ErrDestructive, destructive)
+		}
+	}
+
+	for _, c := range changes {
+		var err error
+		switch c.Action {
+		case ActionCreate:
+			_, err = a.CreateTopic(ctx, c.Spec)
+		case ActionAddPartitions:
+			err = a.CreatePartitions(ctx, c.Spec.Name, c.Spec.Partitions)
+		case ActionAlterConfigs:
+			err = a.AlterConfigs(ctx, c.Spec.Name, c.Spec.Configs)
+		case ActionRecreate:
+			err = recreate(ctx, a, c.Spec)
+		default:
+			err = fmt.Errorf("unknown action %q", c.Action)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to %s %s, %w", c.Action, c.Spec.Name, err)
+		}
+	}
+	return nil
+}
+
+// recreateInterval is how often deletion of recreated topic is checked
+var recreateInterval = 500 * time.Millisecond
+
+// recreate deletes topic and creates it once brokers finish deletion, which is asynchronous,
+// topic leaves metadata before deletion finishes, so create is retried while the old topic still exists
+func recreate(ctx context.Context, a Admin, spec TopicSpec) error {
+	if err := a.DeleteTopics(ctx, spec.Name); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, DefaultTimeout)
+	defer cancel()
+	for {
+		names, err := a.ListTopics(ctx, false)
+		if err != nil {
+			return err
+		}
+		if i := sort.SearchStrings(names, spec.Name); i == len(names) || names[i] != spec.Name {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("topic wasn't deleted, %w", ctx.Err())
+		case <-time.After(recreateInterval):
+		}
+	}
+	for {
+		_, err := a.CreateTopic(ctx, spec)
+		if !stillDeleting(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("topic wasn't deleted, %w", err)
+		case <-time.After(recreateInterval):
+		}
+	}
+}
+
+// stillDeleting tells whether create failed because deleted topic still exists,
+// it's either reported as existing or found existing but not described
+func stillDeleting(err error) bool {
+	return errors.Is(err, kafka.TopicAlreadyExists) || errors.Is(err, ErrTopicMismatch) || errors.Is(err, kafka.UnknownTopicOrPartition)
+}
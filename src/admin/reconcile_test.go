@@ -0,0 +1,112 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "topics.yaml")
+	manifest := `
+topics:
+  - name: logs
+    partitions: 100
+    replication_factor: 2
+    configs:
+      segment.ms: 20000
+      cleanup.policy: compact
+`
+	if err := ioutil.WriteFile(path, []byte(manifest), 0600); err != nil {
+		t.Fatal(err)
+	}
+	m, err := LoadManifest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Topics) != 1 || m.Topics[0].Partitions != 100 || m.Topics[0].Configs["segment.ms"] != "20000" {
+		t.Fatalf("unexpected manifest %+v", m)
+	}
+
+	for _, invalid := range []Manifest{
+		{Topics: []TopicSpec{{Partitions: 1, ReplicationFactor: 1}}},
+		{Topics: []TopicSpec{{Name: "logs", ReplicationFactor: 1}}},
+		{Topics: []TopicSpec{{Name: "logs", Partitions: 1, ReplicationFactor: 1}, {Name: "logs", Partitions: 1, ReplicationFactor: 1}}},
+	} {
+		if err := invalid.Validate(); err == nil {
+			t.Fatalf("expected %+v to be invalid", invalid)
+		}
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	recreateInterval = time.Millisecond
+	cluster := newFakeCluster(
+		TopicSpec{Name: "currently-playing", Partitions: 10, ReplicationFactor: 1},
+		TopicSpec{Name: "logs", Partitions: 50, ReplicationFactor: 2, Configs: map[string]string{"cleanup.policy": "compact"}},
+		TopicSpec{Name: "test2", Partitions: 12, ReplicationFactor: 1},
+		TopicSpec{Name: "unmanaged", Partitions: 1, ReplicationFactor: 1},
+	)
+	// recreated topic is created only after a few attempts
+	cluster.deleteDelay = 2
+	a := cluster.admin()
+	ctx := context.Background()
+	m := Manifest{Topics: []TopicSpec{
+		{Name: "currencies", Partitions: 3, ReplicationFactor: 1},
+		{Name: "currently-playing", Partitions: 10, ReplicationFactor: 1},
+		{Name: "logs", Partitions: 100, ReplicationFactor: 2, Configs: map[string]string{"cleanup.policy": "compact", "segment.ms": "20000"}},
+		{Name: "test2", Partitions: 6, ReplicationFactor: 1},
+	}}
+
+	changes, err := Plan(ctx, a, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		action Action
+		topic  string
+	}{
+		{ActionCreate, "currencies"},
+		{ActionAddPartitions, "logs"},
+		{ActionAlterConfigs, "logs"},
+		{ActionRecreate, "test2"},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("unexpected plan %+v", changes)
+	}
+	for i, w := range want {
+		if changes[i].Action != w.action || changes[i].Spec.Name != w.topic {
+			t.Fatalf("change %d: expected %s %s, got %+v", i, w.action, w.topic, changes[i])
+		}
+	}
+	if len(changes[2].Reasons) != 1 {
+		t.Fatalf("only segment.ms should differ, got %v", changes[2].Reasons)
+	}
+
+	if err := Apply(ctx, a, changes, false); !errors.Is(err, ErrDestructive) {
+		t.Fatalf("expected destructive plan to be refused, got %v", err)
+	}
+	if _, ok := cluster.topics["currencies"]; ok {
+		t.Fatal("refused plan shouldn't be applied partially")
+	}
+
+	if err := Apply(ctx, a, changes, true); err != nil {
+		t.Fatal(err)
+	}
+	if changes, err := Plan(ctx, a, m); err != nil || len(changes) != 0 {
+		t.Fatalf("expected cluster to match manifest, got %+v, %v", changes, err)
+	}
+	if _, ok := cluster.topics["unmanaged"]; !ok {
+		t.Fatal("topics missing in manifest shouldn't be touched")
+	}
+}
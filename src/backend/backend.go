@@ -0,0 +1,161 @@
+// Package backend creates writers and readers of the log selected by configuration, i.e. Kafka,
+// local file log used for offline development or stdout printing written messages
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kafka-tryout/src/config"
+	"kafka-tryout/src/filelog"
+	"kafka-tryout/src/security"
+	"kafka-tryout/src/stream"
+	"os"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+type Kind string
+
+const (
+	// Kafka writes to and reads from Kafka brokers
+	Kafka Kind = config.BackendKafka
+	// File writes to and reads from filelog in local directory
+	File Kind = config.BackendFile
+	// Stdout prints written messages as JSON lines, it can't be read
+	Stdout Kind = config.BackendStdout
+)
+
+type Config struct {
+	Kind Kind
+	// Brokers are used by Kafka backend
+	Brokers []string
+	// Dir and Partitions are used by File backend, Partitions apply only to topics which don't exist yet
+	Dir        string
+	Partitions int
+	// Security is used by Kafka backend
+	Security security.Security
+	// RateLimit limits messages written per second by every writer, 0 disables it
+	RateLimit int
+	// BatchTimeout of Kafka writers, kafka-go default is used when it's zero
+	BatchTimeout time.Duration
+	// Metrics counts messages of all writers and readers, nil disables it
+	Metrics *stream.Metrics
+}
+
+// FromConfig returns backend selected by configuration
+func FromConfig(c config.Config) (Config, error) {
+	sec, err := security.FromConfig(c)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to configure connection security, %w", err)
+	}
+	return Config{
+		Kind:       Kind(c.Backend.Kind),
+		Brokers:    c.Brokers,
+		Dir:        c.Backend.Dir,
+		Partitions: c.Topics.Partitions,
+		Security:   sec,
+		RateLimit:  c.Backend.RateLimit,
+		Metrics:    &stream.Metrics{},
+	}, nil
+}
+
+// MetricsFields returns counts of messages written and read so far as log fields
+func (c Config) MetricsFields() logrus.Fields {
+	if c.Metrics == nil {
+		return logrus.Fields{}
+	}
+	s := c.Metrics.Snapshot()
+	return logrus.Fields{"messages": s.Messages, "bytes": s.Bytes, "errors": s.Errors}
+}
+
+// Writer returns writer of topic, balancer picks partitions of messages,
+// writes are rate limited and counted in metrics when configured
+func (c Config) Writer(topic string, balancer kafka.Balancer) (stream.MessageWriter, error) {
+	var w stream.MessageWriter
+	switch c.Kind {
+	case File:
+		fw, err := filelog.NewWriter(c.Dir, topic, c.Partitions, balancer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create file log writer, %w", err)
+		}
+		w = fw
+	case Stdout:
+		w = stream.NewStdoutWriter(os.Stdout, topic)
+	default:
+		w = kafka.NewWriter(kafka.WriterConfig{
+			Brokers:      c.Brokers,
+			Topic:        topic,
+			Balancer:     balancer,
+			Dialer:       c.Security.Dialer(),
+			BatchTimeout: c.BatchTimeout,
+		})
+	}
+	w = stream.RateLimited(w, c.RateLimit)
+	if c.Metrics != nil {
+		w = stream.WriterWithMetrics(w, c.Metrics)
+	}
+	return w, nil
+}
+
+// WriterFunc returns function creating writers of topics, e.g. for retry publisher, writer which failed
+// to be created fails every write, so the error is handled as any other write error
+func (c Config) WriterFunc(balancer kafka.Balancer) func(topic string) stream.MessageWriter {
+	return func(topic string) stream.MessageWriter {
+		w, err := c.Writer(topic, balancer)
+		if err != nil {
+			return failedWriter{err: err}
+		}
+		return w
+	}
+}
+
+// Reader returns reader of all partitions of topic which commits offsets of group,
+// fetched messages are counted in metrics when configured
+func (c Config) Reader(topic, group string) (stream.MessageReader, error) {
+	r, err := c.reader(topic, group)
+	if err != nil {
+		return nil, err
+	}
+	if c.Metrics != nil {
+		r = stream.ReaderWithMetrics(r, c.Metrics)
+	}
+	return r, nil
+}
+
+func (c Config) reader(topic, group string) (stream.MessageReader, error) {
+	switch c.Kind {
+	case File:
+		r, err := filelog.NewReader(c.Dir, topic, group, c.Partitions)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create file log reader, %w", err)
+		}
+		return r, nil
+	case Stdout:
+		return nil, errors.New("stdout backend can't be read")
+	}
+	return kafka.NewReader(kafka.ReaderConfig{
+		Brokers: c.Brokers,
+		Topic:   topic,
+		// groupID reads from all partitions of given topic
+		GroupID:     group,
+		MinBytes:    1,
+		MaxBytes:    10e6, // 10MB
+		StartOffset: kafka.FirstOffset,
+		Dialer:      c.Security.Dialer(),
+	}), nil
+}
+
+type failedWriter struct {
+	err error
+}
+
+func (w failedWriter) WriteMessages(context.Context, ...kafka.Message) error {
+	return w.err
+}
+
+func (w failedWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,199 @@
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	// HeaderSchema and HeaderVersion are stamped on every message encoded with Registry
+	HeaderSchema  = "schema"
+	HeaderVersion = "schema-version"
+	// HeaderMessageID identifies message content, messages with the same id are duplicates
+	HeaderMessageID = "message-id"
+)
+
+var (
+	ErrMissingSchema = errors.New("message has no schema headers")
+	ErrUnknownSchema = errors.New("unknown schema")
+)
+
+// Codec encodes and decodes values of a single schema version
+type Codec interface {
+	Encode(v interface{}) ([]byte, error)
+	// Decode returns pointer to decoded value, e.g. *rate.Rate
+	Decode(data []byte) (interface{}, error)
+}
+
+// Schema is a named and versioned Codec
+type Schema struct {
+	Name    string
+	Version int
+	Codec   Codec
+}
+
+func (s Schema) String() string {
+	return s.Name + "/v" + strconv.Itoa(s.Version)
+}
+
+// Headers returns headers identifying the schema
+func (s Schema) Headers() []kafka.Header {
+	return []kafka.Header{
+		{Key: HeaderSchema, Value: []byte(s.Name)},
+		{Key: HeaderVersion, Value: []byte(strconv.Itoa(s.Version))},
+	}
+}
+
+type schemaKey struct {
+	name    string
+	version int
+}
+
+// Registry keeps schemas, producers encode with it and consumers decode by looking up message headers
+type Registry struct {
+	mu      sync.RWMutex
+	schemas map[schemaKey]Schema
+	latest  map[string]int
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		schemas: make(map[schemaKey]Schema),
+		latest:  make(map[string]int),
+	}
+}
+
+// Register adds schema to the registry, registered versions can't be replaced
+func (r *Registry) Register(s Schema) error {
+	if s.Name == "" || s.Version <= 0 || s.Codec == nil {
+		return fmt.Errorf("invalid schema %s", s)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := schemaKey{s.Name, s.Version}
+	if _, ok := r.schemas[key]; ok {
+		return fmt.Errorf("schema %s already registered", s)
+	}
+	r.schemas[key] = s
+	if s.Version > r.latest[s.Name] {
+		r.latest[s.Name] = s.Version
+	}
+	return nil
+}
+
+// MustRegister registers schemas and panics on error, meant for package initialization
+func (r *Registry) MustRegister(schemas ...Schema) {
+	for _, s := range schemas {
+		if err := r.Register(s); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Lookup returns schema of given name and version
+func (r *Registry) Lookup(name string, version int) (Schema, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	s, ok := r.schemas[schemaKey{name, version}]
+	if !ok {
+		return Schema{}, fmt.Errorf("%w: %s/v%d", ErrUnknownSchema, name, version)
+	}
+	return s, nil
+}
+
+// Latest returns the newest version of given schema
+func (r *Registry) Latest(name string) (Schema, error) {
+	r.mu.RLock()
+	version, ok := r.latest[name]
+	r.mu.RUnlock()
+	if !ok {
+		return Schema{}, fmt.Errorf("%w: %s", ErrUnknownSchema, name)
+	}
+	return r.Lookup(name, version)
+}
+
+// Encode encodes v with given schema version, the latest version is used when version is 0,
+// returned headers should be added to the message
+func (r *Registry) Encode(name string, version int, v interface{}) ([]byte, []kafka.Header, error) {
+	var (
+		s   Schema
+		err error
+	)
+	if version == 0 {
+		s, err = r.Latest(name)
+	} else {
+		s, err = r.Lookup(name, version)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := s.Codec.Encode(v)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode %s, %w", s, err)
+	}
+	return data, s.Headers(), nil
+}
+
+// Decode decodes message value with the schema stamped in its headers
+func (r *Registry) Decode(m kafka.Message) (interface{}, Schema, error) {
+	name, version, err := SchemaOf(m)
+	if err != nil {
+		return nil, Schema{}, err
+	}
+	return r.decode(m.Value, name, version)
+}
+
+// DecodeOr decodes message like Decode, messages without schema headers (e.g. produced
+// before schemas were introduced) are decoded with given fallback schema
+func (r *Registry) DecodeOr(m kafka.Message, name string, version int) (interface{}, Schema, error) {
+	v, s, err := r.Decode(m)
+	if errors.Is(err, ErrMissingSchema) {
+		return r.decode(m.Value, name, version)
+	}
+	return v, s, err
+}
+
+func (r *Registry) decode(data []byte, name string, version int) (interface{}, Schema, error) {
+	s, err := r.Lookup(name, version)
+	if err != nil {
+		return nil, Schema{}, err
+	}
+	v, err := s.Codec.Decode(data)
+	if err != nil {
+		return nil, s, fmt.Errorf("failed to decode %s, %w", s, err)
+	}
+	return v, s, nil
+}
+
+// SchemaOf reads schema name and version from message headers
+func SchemaOf(m kafka.Message) (string, int, error) {
+	name, ok := Header(m, HeaderSchema)
+	if !ok {
+		return "", 0, ErrMissingSchema
+	}
+	v, ok := Header(m, HeaderVersion)
+	if !ok {
+		return "", 0, ErrMissingSchema
+	}
+	version, err := strconv.Atoi(v)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid schema version %q, %w", v, err)
+	}
+	return name, version, nil
+}
+
+// Header returns value of the first header with given key
+func Header(m kafka.Message, key string) (string, bool) {
+	for _, h := range m.Headers {
+		if h.Key == key {
+			return string(h.Value), true
+		}
+	}
+	return "", false
+}
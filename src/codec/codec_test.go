@@ -0,0 +1,91 @@
+package codec
+
+import (
+	"errors"
+	"kafka-tryout/src/rate"
+	"kafka-tryout/src/spotify_generator"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestDefaultRoundTrip(t *testing.T) {
+	r := rate.Rate{Base: "EUR", Rate: 1.1823, Date: "2020-09-04"}
+	cp := spotify_generator.CurrentlyPlaying{
+		PlayedAt: time.Date(2020, 9, 4, 12, 0, 0, 0, time.UTC),
+		Artists: []spotify_generator.Artist{
+			{Name: "Artist", Genres: []string{"rock", "indie"}, Followers: 1000, Popularity: 42},
+		},
+		TrackName:  "Track",
+		DurationMs: 180000,
+	}
+	prop := spotify_generator.Proposition{
+		Meta:      spotify_generator.Meta{PlaylistInx: 1, TrackInx: 2, PlaylistID: "id", TrackName: "Track"},
+		TrackName: "Other",
+		Artists:   []string{"Artist"},
+		Album:     "Album",
+	}
+
+	tests := []struct {
+		schema  string
+		version int
+		value   interface{}
+	}{
+		{SchemaRate, 1, r},
+		{SchemaRate, 2, r},
+		{SchemaCurrentlyPlaying, 1, cp},
+		{SchemaCurrentlyPlaying, 2, cp},
+		{SchemaProposition, 1, prop},
+	}
+	for _, tt := range tests {
+		value, headers, err := Default.Encode(tt.schema, tt.version, tt.value)
+		if err != nil {
+			t.Fatalf("%s/v%d: failed to encode: %v", tt.schema, tt.version, err)
+		}
+		decoded, schema, err := Default.Decode(kafka.Message{Value: value, Headers: headers})
+		if err != nil {
+			t.Fatalf("%s/v%d: failed to decode: %v", tt.schema, tt.version, err)
+		}
+		if schema.Name != tt.schema || schema.Version != tt.version {
+			t.Fatalf("unexpected schema %s", schema)
+		}
+		got := reflect.ValueOf(decoded).Elem().Interface()
+		if !reflect.DeepEqual(got, tt.value) {
+			t.Fatalf("%s/v%d: expected %+v, got %+v", tt.schema, tt.version, tt.value, got)
+		}
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	reg := NewRegistry()
+	reg.MustRegister(
+		Schema{Name: "rate", Version: 1, Codec: NewJSONCodec(rate.Rate{})},
+		Schema{Name: "rate", Version: 2, Codec: NewRateProtoCodec()},
+	)
+	if err := reg.Register(Schema{Name: "rate", Version: 1, Codec: NewRateProtoCodec()}); err == nil {
+		t.Fatal("registered version must not be replaced")
+	}
+	if s, err := reg.Latest("rate"); err != nil || s.Version != 2 {
+		t.Fatalf("expected latest version 2, got %v (%v)", s, err)
+	}
+	if _, _, err := reg.Encode("rate", 1, "not a rate"); err == nil {
+		t.Fatal("expected error for value of wrong type")
+	}
+	if _, err := reg.Lookup("rate", 3); !errors.Is(err, ErrUnknownSchema) {
+		t.Fatalf("expected ErrUnknownSchema, got %v", err)
+	}
+
+	legacy := kafka.Message{Value: []byte(`{"Base":"EUR","Rate":1.18,"Date":"2020-09-04"}`)}
+	if _, _, err := reg.Decode(legacy); !errors.Is(err, ErrMissingSchema) {
+		t.Fatalf("expected ErrMissingSchema, got %v", err)
+	}
+	v, _, err := reg.DecodeOr(legacy, "rate", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.(*rate.Rate).Rate != 1.18 {
+		t.Fatalf("unexpected legacy rate %+v", v)
+	}
+}
@@ -0,0 +1,47 @@
+package codec
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+type jsonCodec struct {
+	typ reflect.Type
+}
+
+// NewJSONCodec returns Codec encoding values as JSON, sample tells which type should be decoded,
+// e.g. NewJSONCodec(rate.Rate{}) decodes into *rate.Rate
+func NewJSONCodec(sample interface{}) Codec {
+	typ := reflect.TypeOf(sample)
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return &jsonCodec{typ: typ}
+}
+
+func (j *jsonCodec) Encode(v interface{}) ([]byte, error) {
+	if err := j.check(v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+func (j *jsonCodec) Decode(data []byte) (interface{}, error) {
+	v := reflect.New(j.typ).Interface()
+	if err := json.Unmarshal(data, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (j *jsonCodec) check(v interface{}) error {
+	typ := reflect.TypeOf(v)
+	if typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ != j.typ {
+		return fmt.Errorf("expected %s, got %T", j.typ, v)
+	}
+	return nil
+}
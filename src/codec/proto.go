@@ -0,0 +1,203 @@
+package codec
+
+import (
+	"fmt"
+	"kafka-tryout/src/rate"
+	"kafka-tryout/src/spotify_generator"
+	"math"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// protobuf codecs are written by hand with protowire, so no generated code is needed,
+// wire format of every message is described in schemas.proto
+
+type protoCodec struct {
+	encode func(interface{}) ([]byte, error)
+	decode func([]byte) (interface{}, error)
+}
+
+func (p *protoCodec) Encode(v interface{}) ([]byte, error) { return p.encode(v) }
+
+func (p *protoCodec) Decode(data []byte) (interface{}, error) { return p.decode(data) }
+
+// NewRateProtoCodec returns protobuf Codec of rate.Rate
+func NewRateProtoCodec() Codec {
+	return &protoCodec{encode: encodeRateProto, decode: decodeRateProto}
+}
+
+// NewCurrentlyPlayingProtoCodec returns protobuf Codec of spotify_generator.CurrentlyPlaying
+func NewCurrentlyPlayingProtoCodec() Codec {
+	return &protoCodec{encode: encodeCurrentlyPlayingProto, decode: decodeCurrentlyPlayingProto}
+}
+
+func encodeRateProto(v interface{}) ([]byte, error) {
+	var r rate.Rate
+	switch t := v.(type) {
+	case rate.Rate:
+		r = t
+	case *rate.Rate:
+		r = *t
+	default:
+		return nil, fmt.Errorf("expected rate.Rate, got %T", v)
+	}
+	var b []byte
+	b = appendString(b, 1, r.Base)
+	b = protowire.AppendTag(b, 2, protowire.Fixed64Type)
+	b = protowire.AppendFixed64(b, math.Float64bits(r.Rate))
+	b = appendString(b, 3, r.Date)
+	return b, nil
+}
+
+func decodeRateProto(data []byte) (interface{}, error) {
+	r := &rate.Rate{}
+	err := consumeFields(data, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			return consumeString(b, &r.Base)
+		case num == 2 && typ == protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(b)
+			r.Rate = math.Float64frombits(v)
+			return n, protowire.ParseError(n)
+		case num == 3 && typ == protowire.BytesType:
+			return consumeString(b, &r.Date)
+		}
+		return skip(num, typ, b)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func encodeCurrentlyPlayingProto(v interface{}) ([]byte, error) {
+	var c spotify_generator.CurrentlyPlaying
+	switch t := v.(type) {
+	case spotify_generator.CurrentlyPlaying:
+		c = t
+	case *spotify_generator.CurrentlyPlaying:
+		c = *t
+	default:
+		return nil, fmt.Errorf("expected spotify_generator.CurrentlyPlaying, got %T", v)
+	}
+	var b []byte
+	// zero time can't be represented in nanoseconds, missing field is decoded as zero time
+	if !c.PlayedAt.IsZero() {
+		b = appendVarint(b, 1, uint64(c.PlayedAt.UnixNano()))
+	}
+	for _, a := range c.Artists {
+		var artist []byte
+		artist = appendString(artist, 1, a.Name)
+		for _, g := range a.Genres {
+			artist = appendString(artist, 2, g)
+		}
+		artist = appendVarint(artist, 3, uint64(a.Followers))
+		artist = appendVarint(artist, 4, uint64(a.Popularity))
+
+		b = protowire.AppendTag(b, 2, protowire.BytesType)
+		b = protowire.AppendBytes(b, artist)
+	}
+	b = appendString(b, 3, c.TrackName)
+	b = appendVarint(b, 4, uint64(c.DurationMs))
+	return b, nil
+}
+
+func decodeCurrentlyPlayingProto(data []byte) (interface{}, error) {
+	c := &spotify_generator.CurrentlyPlaying{}
+	err := consumeFields(data, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == 1 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			c.PlayedAt = time.Unix(0, int64(v)).UTC()
+			return n, protowire.ParseError(n)
+		case num == 2 && typ == protowire.BytesType:
+			raw, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return n, protowire.ParseError(n)
+			}
+			artist, err := decodeArtistProto(raw)
+			if err != nil {
+				return n, err
+			}
+			c.Artists = append(c.Artists, artist)
+			return n, nil
+		case num == 3 && typ == protowire.BytesType:
+			return consumeString(b, &c.TrackName)
+		case num == 4 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			c.DurationMs = int(int64(v))
+			return n, protowire.ParseError(n)
+		}
+		return skip(num, typ, b)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func decodeArtistProto(data []byte) (spotify_generator.Artist, error) {
+	var a spotify_generator.Artist
+	err := consumeFields(data, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			return consumeString(b, &a.Name)
+		case num == 2 && typ == protowire.BytesType:
+			var genre string
+			n, err := consumeString(b, &genre)
+			a.Genres = append(a.Genres, genre)
+			return n, err
+		case num == 3 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			a.Followers = uint(v)
+			return n, protowire.ParseError(n)
+		case num == 4 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			a.Popularity = int(int64(v))
+			return n, protowire.ParseError(n)
+		}
+		return skip(num, typ, b)
+	})
+	return a, err
+}
+
+// consumeFields iterates over fields of the message, fn consumes value of the field
+// and returns number of consumed bytes
+func consumeFields(data []byte, fn func(protowire.Number, protowire.Type, []byte) (int, error)) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		m, err := fn(num, typ, data)
+		if err != nil {
+			return fmt.Errorf("invalid field %d, %w", num, err)
+		}
+		data = data[m:]
+	}
+	return nil
+}
+
+// skip consumes unknown field, so new fields can be added without breaking older consumers
+func skip(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+	n := protowire.ConsumeFieldValue(num, typ, b)
+	return n, protowire.ParseError(n)
+}
+
+func consumeString(b []byte, s *string) (int, error) {
+	v, n := protowire.ConsumeString(b)
+	*s = v
+	return n, protowire.ParseError(n)
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
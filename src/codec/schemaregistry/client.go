@@ -0,0 +1,87 @@
+package schemaregistry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Compatibility level of the subject, see
+// https://docs.confluent.io/current/schema-registry/avro.html#compatibility-types
+type Compatibility string
+
+const (
+	// CompatibilityNone accepts every change
+	CompatibilityNone Compatibility = "NONE"
+	// CompatibilityBackward means consumers using the new schema can read data written with the latest one
+	CompatibilityBackward Compatibility = "BACKWARD"
+	// CompatibilityForward means consumers using the latest schema can read data written with the new one
+	CompatibilityForward Compatibility = "FORWARD"
+	// CompatibilityFull is both backward and forward
+	CompatibilityFull Compatibility = "FULL"
+
+	// DefaultCompatibility is used for subjects without configured level, the same as Confluent's default
+	DefaultCompatibility = CompatibilityBackward
+
+	// SchemaTypeJSON is the type of schemas kept in registry, schemas are JSON Schema documents
+	SchemaTypeJSON = "JSON"
+)
+
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrIncompatible = errors.New("incompatible schema")
+)
+
+// ParseCompatibility parses compatibility level, case insensitive
+func ParseCompatibility(s string) (Compatibility, error) {
+	c := Compatibility(strings.ToUpper(s))
+	switch c {
+	case CompatibilityNone, CompatibilityBackward, CompatibilityForward, CompatibilityFull:
+		return c, nil
+	}
+	return "", fmt.Errorf("unknown compatibility %q", s)
+}
+
+// SubjectVersion is a schema registered under subject
+type SubjectVersion struct {
+	Subject string `json:"subject"`
+	ID      int    `json:"id"`
+	Version int    `json:"version"`
+	Schema  string `json:"schema"`
+}
+
+// Client of the schema registry
+type Client interface {
+	// Register registers schema under subject and returns its id, registering the same schema again
+	// returns existing id, schema breaking subject's compatibility is refused with ErrIncompatible
+	Register(ctx context.Context, subject, schema string) (int, error)
+	// Schema returns schema of given id
+	Schema(ctx context.Context, id int) (string, error)
+	// Latest returns the latest version registered under subject
+	Latest(ctx context.Context, subject string) (SubjectVersion, error)
+	// Compatibility returns compatibility level of subject
+	Compatibility(ctx context.Context, subject string) (Compatibility, error)
+	// SetCompatibility changes compatibility level of subject
+	SetCompatibility(ctx context.Context, subject string, c Compatibility) error
+}
+
+// NewClient returns HTTP client of registry at url or file client keeping schemas in file,
+// nil when both are empty, i.e. schema registry is disabled
+func NewClient(client *http.Client, url, file string) (Client, error) {
+	switch {
+	case url != "" && file != "":
+		return nil, errors.New("schema registry url and file can't be used together")
+	case url != "":
+		return NewHTTPClient(client, url), nil
+	case file != "":
+		return NewFileClient(file), nil
+	}
+	return nil, nil
+}
+
+// Subject returns subject of topic's values, the same as Confluent's TopicNameStrategy
+func Subject(topic string) string {
+	return topic + "-value"
+}
@@ -0,0 +1,77 @@
+package schemaregistry
+
+import (
+	"context"
+	"fmt"
+	"kafka-tryout/src/codec"
+	"kafka-tryout/src/rate"
+	"kafka-tryout/src/spotify_generator"
+)
+
+type wireCodec struct {
+	id    int
+	inner codec.Codec
+}
+
+// NewCodec registers JSON Schema of sample's type under subject and returns codec.Codec
+// encoding values as JSON in Confluent wire format, so they can be read by Confluent tooling
+func NewCodec(ctx context.Context, client Client, subject string, sample interface{}) (codec.Codec, error) {
+	schema, err := JSONSchemaOf(sample)
+	if err != nil {
+		return nil, err
+	}
+	id, err := client.Register(ctx, subject, schema)
+	if err != nil {
+		return nil, err
+	}
+	return &wireCodec{id: id, inner: codec.NewJSONCodec(sample)}, nil
+}
+
+func (w *wireCodec) Encode(v interface{}) ([]byte, error) {
+	payload, err := w.inner.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	return EncodeWire(w.id, payload), nil
+}
+
+// Decode accepts every schema id, older versions of the schema are compatible by registry's checks
+func (w *wireCodec) Decode(data []byte) (interface{}, error) {
+	_, payload, err := DecodeWire(data)
+	if err != nil {
+		return nil, err
+	}
+	return w.inner.Decode(payload)
+}
+
+// versions of codec.SchemaRate and codec.SchemaCurrentlyPlaying encoded in Confluent wire format,
+// they are added to codec registry by RegisterDefaults, so only commands with schema registry know them
+const (
+	RateWireVersion             = 3
+	CurrentlyPlayingWireVersion = 3
+)
+
+// RegisterDefaults registers schemas of rate.Rate and spotify_generator.CurrentlyPlaying under subjects
+// of given topics and adds their wire codecs to reg as RateWireVersion and CurrentlyPlayingWireVersion,
+// changes breaking subjects' compatibility are refused
+func RegisterDefaults(ctx context.Context, client Client, reg *codec.Registry, ratesTopic, currentlyPlayingTopic string) error {
+	schemas := []struct {
+		subject string
+		sample  interface{}
+		name    string
+		version int
+	}{
+		{Subject(ratesTopic), rate.Rate{}, codec.SchemaRate, RateWireVersion},
+		{Subject(currentlyPlayingTopic), spotify_generator.CurrentlyPlaying{}, codec.SchemaCurrentlyPlaying, CurrentlyPlayingWireVersion},
+	}
+	for _, s := range schemas {
+		c, err := NewCodec(ctx, client, s.subject, s.sample)
+		if err != nil {
+			return fmt.Errorf("failed to register %s, %w", s.subject, err)
+		}
+		if err := reg.Register(codec.Schema{Name: s.name, Version: s.version, Codec: c}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package schemaregistry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+type fileState struct {
+	NextID   int                     `json:"nextId"`
+	Subjects map[string]*fileSubject `json:"subjects"`
+}
+
+type fileSubject struct {
+	Compatibility Compatibility    `json:"compatibility,omitempty"`
+	Versions      []SubjectVersion `json:"versions"`
+}
+
+type fileClient struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileClient returns Client keeping schemas in local JSON file, file is created on first registration
+func NewFileClient(path string) Client {
+	return &fileClient{path: path}
+}
+
+func (f *fileClient) Register(_ context.Context, subject, schema string) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	state, err := f.load()
+	if err != nil {
+		return 0, err
+	}
+	sub, ok := state.Subjects[subject]
+	if !ok {
+		sub = &fileSubject{}
+		state.Subjects[subject] = sub
+	}
+	for _, v := range sub.Versions {
+		if v.Schema == schema {
+			return v.ID, nil
+		}
+	}
+	if n := len(sub.Versions); n > 0 {
+		if err := CheckCompatibility(sub.compatibility(), sub.Versions[n-1].Schema, schema); err != nil {
+			return 0, err
+		}
+	}
+
+	// the same schema has the same id in every subject
+	id := state.idOf(schema)
+	if id == 0 {
+		state.NextID++
+		id = state.NextID
+	}
+	sub.Versions = append(sub.Versions, SubjectVersion{
+		Subject: subject,
+		ID:      id,
+		Version: len(sub.Versions) + 1,
+		Schema:  schema,
+	})
+	if err := f.save(state); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (f *fileClient) Schema(_ context.Context, id int) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	state, err := f.load()
+	if err != nil {
+		return "", err
+	}
+	for _, sub := range state.Subjects {
+		for _, v := range sub.Versions {
+			if v.ID == id {
+				return v.Schema, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("schema %d %w", id, ErrNotFound)
+}
+
+func (f *fileClient) Latest(_ context.Context, subject string) (SubjectVersion, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	state, err := f.load()
+	if err != nil {
+		return SubjectVersion{}, err
+	}
+	sub, ok := state.Subjects[subject]
+	if !ok || len(sub.Versions) == 0 {
+		return SubjectVersion{}, fmt.Errorf("subject %s %w", subject, ErrNotFound)
+	}
+	return sub.Versions[len(sub.Versions)-1], nil
+}
+
+func (f *fileClient) Compatibility(_ context.Context, subject string) (Compatibility, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	state, err := f.load()
+	if err != nil {
+		return "", err
+	}
+	sub, ok := state.Subjects[subject]
+	if !ok {
+		return DefaultCompatibility, nil
+	}
+	return sub.compatibility(), nil
+}
+
+func (f *fileClient) SetCompatibility(_ context.Context, subject string, c Compatibility) error {
+	if _, err := ParseCompatibility(string(c)); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	state, err := f.load()
+	if err != nil {
+		return err
+	}
+	sub, ok := state.Subjects[subject]
+	if !ok {
+		sub = &fileSubject{}
+		state.Subjects[subject] = sub
+	}
+	sub.Compatibility = c
+	return f.save(state)
+}
+
+func (s *fileSubject) compatibility() Compatibility {
+	if s.Compatibility == "" {
+		return DefaultCompatibility
+	}
+	return s.Compatibility
+}
+
+func (s *fileState) idOf(schema string) int {
+	for _, sub := range s.Subjects {
+		for _, v := range sub.Versions {
+			if v.Schema == schema {
+				return v.ID
+			}
+		}
+	}
+	return 0
+}
+
+func (f *fileClient) load() (*fileState, error) {
+	state := &fileState{Subjects: make(map[string]*fileSubject)}
+	b, err := ioutil.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema registry file, %w", err)
+	}
+	if err := json.Unmarshal(b, state); err != nil {
+		return nil, fmt.Errorf("failed to decode schema registry file, %w", err)
+	}
+	if state.Subjects == nil {
+		state.Subjects = make(map[string]*fileSubject)
+	}
+	return state, nil
+}
+
+// save writes state to temporary file and renames it, so crash never leaves half written registry
+func (f *fileClient) save(state *fileState) error {
+	b, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode schema registry, %w", err)
+	}
+	tmp := filepath.Join(filepath.Dir(f.path), "."+filepath.Base(f.path)+".tmp")
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return fmt.Errorf("failed to write schema registry file, %w", err)
+	}
+	if err := os.Rename(tmp, f.path); err != nil {
+		return fmt.Errorf("failed to replace schema registry file, %w", err)
+	}
+	return nil
+}
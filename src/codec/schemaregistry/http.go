@@ -0,0 +1,169 @@
+package schemaregistry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+const contentType = "application/vnd.schemaregistry.v1+json"
+
+type httpClient struct {
+	client  *http.Client
+	baseURL string
+
+	mu sync.RWMutex
+	// schemas are immutable, so they are cached by id
+	schemas map[int]string
+}
+
+// NewHTTPClient returns Client of Confluent Schema Registry REST API
+func NewHTTPClient(client *http.Client, baseURL string) Client {
+	return &httpClient{
+		client:  client,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		schemas: make(map[int]string),
+	}
+}
+
+// apiError is the error body returned by schema registry
+type apiError struct {
+	ErrorCode int    `json:"error_code"`
+	Message   string `json:"message"`
+}
+
+func (h *httpClient) Register(ctx context.Context, subject, schema string) (int, error) {
+	// registry checks compatibility on its own, it's checked here as well to return meaningful error
+	latest, err := h.Latest(ctx, subject)
+	switch {
+	case errors.Is(err, ErrNotFound):
+	case err != nil:
+		return 0, err
+	case latest.Schema != schema:
+		c, err := h.Compatibility(ctx, subject)
+		if err != nil {
+			return 0, err
+		}
+		if err := CheckCompatibility(c, latest.Schema, schema); err != nil {
+			return 0, err
+		}
+	}
+
+	var resp struct {
+		ID int `json:"id"`
+	}
+	req := map[string]string{"schema": schema, "schemaType": SchemaTypeJSON}
+	if err := h.do(ctx, http.MethodPost, "/subjects/"+url.PathEscape(subject)+"/versions", req, &resp); err != nil {
+		return 0, fmt.Errorf("failed to register schema under %s, %w", subject, err)
+	}
+	h.mu.Lock()
+	h.schemas[resp.ID] = schema
+	h.mu.Unlock()
+	return resp.ID, nil
+}
+
+func (h *httpClient) Schema(ctx context.Context, id int) (string, error) {
+	h.mu.RLock()
+	schema, ok := h.schemas[id]
+	h.mu.RUnlock()
+	if ok {
+		return schema, nil
+	}
+
+	var resp struct {
+		Schema string `json:"schema"`
+	}
+	if err := h.do(ctx, http.MethodGet, fmt.Sprintf("/schemas/ids/%d", id), nil, &resp); err != nil {
+		return "", fmt.Errorf("failed to get schema %d, %w", id, err)
+	}
+	h.mu.Lock()
+	h.schemas[id] = resp.Schema
+	h.mu.Unlock()
+	return resp.Schema, nil
+}
+
+func (h *httpClient) Latest(ctx context.Context, subject string) (SubjectVersion, error) {
+	var resp SubjectVersion
+	if err := h.do(ctx, http.MethodGet, "/subjects/"+url.PathEscape(subject)+"/versions/latest", nil, &resp); err != nil {
+		return SubjectVersion{}, fmt.Errorf("failed to get latest schema of %s, %w", subject, err)
+	}
+	return resp, nil
+}
+
+func (h *httpClient) Compatibility(ctx context.Context, subject string) (Compatibility, error) {
+	var resp struct {
+		CompatibilityLevel Compatibility `json:"compatibilityLevel"`
+	}
+	err := h.do(ctx, http.MethodGet, "/config/"+url.PathEscape(subject), nil, &resp)
+	if errors.Is(err, ErrNotFound) {
+		// subject has no own config, global one is used
+		err = h.do(ctx, http.MethodGet, "/config", nil, &resp)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get compatibility of %s, %w", subject, err)
+	}
+	return resp.CompatibilityLevel, nil
+}
+
+func (h *httpClient) SetCompatibility(ctx context.Context, subject string, c Compatibility) error {
+	if _, err := ParseCompatibility(string(c)); err != nil {
+		return err
+	}
+	req := map[string]Compatibility{"compatibility": c}
+	if err := h.do(ctx, http.MethodPut, "/config/"+url.PathEscape(subject), req, nil); err != nil {
+		return fmt.Errorf("failed to set compatibility of %s, %w", subject, err)
+	}
+	return nil
+}
+
+// do sends request with JSON body and decodes JSON response into out, if given
+func (h *httpClient) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request, %w", err)
+		}
+		reader = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, h.baseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request, %w", err)
+	}
+	req.Header.Set("Accept", contentType)
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request, %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var apiErr apiError
+		_ = json.NewDecoder(resp.Body).Decode(&apiErr)
+		switch resp.StatusCode {
+		case http.StatusNotFound:
+			return fmt.Errorf("%w: %s", ErrNotFound, apiErr.Message)
+		case http.StatusConflict:
+			return fmt.Errorf("%w: %s", ErrIncompatible, apiErr.Message)
+		}
+		return fmt.Errorf("unexpected status %s (%d): %s", resp.Status, apiErr.ErrorCode, apiErr.Message)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response, %w", err)
+	}
+	return nil
+}
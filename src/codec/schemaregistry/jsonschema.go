@@ -0,0 +1,188 @@
+package schemaregistry
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// jsonSchema is the subset of JSON Schema describing Go values encoded with encoding/json
+type jsonSchema struct {
+	Title      string                 `json:"title,omitempty"`
+	Type       string                 `json:"type"`
+	Format     string                 `json:"format,omitempty"`
+	Properties map[string]*jsonSchema `json:"properties,omitempty"`
+	Required   []string               `json:"required,omitempty"`
+	Items      *jsonSchema            `json:"items,omitempty"`
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// JSONSchemaOf generates JSON Schema of the value's type, every field without omitempty is required
+func JSONSchemaOf(v interface{}) (string, error) {
+	typ := reflect.TypeOf(v)
+	if typ == nil {
+		return "", fmt.Errorf("can't generate schema of nil")
+	}
+	s, err := schemaOf(typ)
+	if err != nil {
+		return "", err
+	}
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	s.Title = typ.String()
+	b, err := json.Marshal(s)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal schema, %w", err)
+	}
+	return string(b), nil
+}
+
+func schemaOf(typ reflect.Type) (*jsonSchema, error) {
+	if typ == timeType {
+		return &jsonSchema{Type: "string", Format: "date-time"}, nil
+	}
+	switch typ.Kind() {
+	case reflect.Ptr:
+		return schemaOf(typ.Elem())
+	case reflect.String:
+		return &jsonSchema{Type: "string"}, nil
+	case reflect.Bool:
+		return &jsonSchema{Type: "boolean"}, nil
+	case reflect.Float32, reflect.Float64:
+		return &jsonSchema{Type: "number"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &jsonSchema{Type: "integer"}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := schemaOf(typ.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &jsonSchema{Type: "array", Items: items}, nil
+	case reflect.Map:
+		return &jsonSchema{Type: "object"}, nil
+	case reflect.Struct:
+		s := &jsonSchema{Type: "object", Properties: make(map[string]*jsonSchema)}
+		if err := addFields(s, typ); err != nil {
+			return nil, err
+		}
+		sort.Strings(s.Required)
+		return s, nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", typ)
+}
+
+// addFields adds fields of struct to the schema the same way encoding/json encodes them,
+// fields of embedded structs without tags are promoted
+func addFields(s *jsonSchema, typ reflect.Type) error {
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || (f.PkgPath != "" && !f.Anonymous) {
+			continue
+		}
+		name, opts := tag, ""
+		if idx := strings.Index(tag, ","); idx >= 0 {
+			name, opts = tag[:idx], tag[idx+1:]
+		}
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			if err := addFields(s, f.Type); err != nil {
+				return err
+			}
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		// fields of the outer struct shadow promoted ones
+		if _, ok := s.Properties[name]; ok {
+			continue
+		}
+		fs, err := schemaOf(f.Type)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", f.Name, err)
+		}
+		s.Properties[name] = fs
+		if !strings.Contains(opts, "omitempty") {
+			s.Required = append(s.Required, name)
+		}
+	}
+	return nil
+}
+
+// CheckCompatibility checks whether next schema can be registered after latest one with given compatibility
+func CheckCompatibility(c Compatibility, latest, next string) error {
+	if c == CompatibilityNone {
+		return nil
+	}
+	var l, n jsonSchema
+	if err := json.Unmarshal([]byte(latest), &l); err != nil {
+		return fmt.Errorf("failed to parse latest schema, %w", err)
+	}
+	if err := json.Unmarshal([]byte(next), &n); err != nil {
+		return fmt.Errorf("failed to parse new schema, %w", err)
+	}
+
+	var problems []string
+	if c == CompatibilityBackward || c == CompatibilityFull {
+		// consumers using new schema read data written with the latest one
+		problems = append(problems, canRead(&n, &l, "")...)
+	}
+	if c == CompatibilityForward || c == CompatibilityFull {
+		// consumers using latest schema read data written with the new one
+		problems = append(problems, canRead(&l, &n, "")...)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%w (%s): %s", ErrIncompatible, c, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// canRead returns problems of reader schema reading data written with writer schema
+func canRead(reader, writer *jsonSchema, path string) []string {
+	if path == "" {
+		path = "$"
+	}
+	if reader.Type != writer.Type && !(reader.Type == "number" && writer.Type == "integer") {
+		return []string{fmt.Sprintf("%s changed type from %s to %s", path, writer.Type, reader.Type)}
+	}
+
+	var problems []string
+	switch reader.Type {
+	case "array":
+		if reader.Items != nil && writer.Items != nil {
+			problems = append(problems, canRead(reader.Items, writer.Items, path+"[]")...)
+		}
+	case "object":
+		writerRequired := toSet(writer.Required)
+		for _, name := range reader.Required {
+			if _, ok := writerRequired[name]; !ok {
+				problems = append(problems, fmt.Sprintf("%s.%s is required but may be missing", path, name))
+			}
+		}
+		names := make([]string, 0, len(reader.Properties))
+		for name := range reader.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if w, ok := writer.Properties[name]; ok {
+				problems = append(problems, canRead(reader.Properties[name], w, path+"."+name)...)
+			}
+		}
+	}
+	return problems
+}
+
+func toSet(values []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(values))
+	for _, v := range values {
+		set[v] = struct{}{}
+	}
+	return set
+}
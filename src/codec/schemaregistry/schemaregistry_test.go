@@ -0,0 +1,289 @@
+package schemaregistry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"kafka-tryout/src/codec"
+	"kafka-tryout/src/rate"
+	"kafka-tryout/src/spotify_generator"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// breaking changes of rate.Rate
+type (
+	rateRenamedField struct {
+		Base  string
+		Value float64
+		Date  string
+	}
+	rateChangedType struct {
+		Base string
+		Rate string
+		Date string
+	}
+	rateOptionalField struct {
+		Base   string
+		Rate   float64
+		Date   string
+		Source string `json:",omitempty"`
+	}
+)
+
+// breaking change of spotify_generator.CurrentlyPlaying
+type currentlyPlayingChangedArtists struct {
+	PlayedAt   time.Time
+	Artists    []string
+	TrackName  string
+	DurationMs int
+}
+
+func mustSchema(t *testing.T, v interface{}) string {
+	t.Helper()
+	s, err := JSONSchemaOf(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestWire(t *testing.T) {
+	data := EncodeWire(42, []byte("payload"))
+	if !bytes.Equal(data[:5], []byte{0, 0, 0, 0, 42}) {
+		t.Fatalf("unexpected header % x", data[:5])
+	}
+	id, payload, err := DecodeWire(data)
+	if err != nil || id != 42 || string(payload) != "payload" {
+		t.Fatalf("unexpected decoded %d %q %v", id, payload, err)
+	}
+	if _, _, err := DecodeWire([]byte(`{"Base":"EUR"}`)); !errors.Is(err, ErrNotWireFormat) {
+		t.Fatalf("expected ErrNotWireFormat, got %v", err)
+	}
+}
+
+func TestCheckCompatibility(t *testing.T) {
+	latest := mustSchema(t, rate.Rate{})
+	tests := []struct {
+		name  string
+		next  interface{}
+		level Compatibility
+		ok    bool
+	}{
+		{"same", rate.Rate{}, CompatibilityFull, true},
+		{"renamed field", rateRenamedField{}, CompatibilityBackward, false},
+		{"renamed field without checks", rateRenamedField{}, CompatibilityNone, true},
+		{"changed type", rateChangedType{}, CompatibilityForward, false},
+		{"optional field", rateOptionalField{}, CompatibilityFull, true},
+	}
+	for _, tt := range tests {
+		err := CheckCompatibility(tt.level, latest, mustSchema(t, tt.next))
+		if tt.ok && err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrIncompatible) {
+			t.Errorf("%s: expected ErrIncompatible, got %v", tt.name, err)
+		}
+	}
+
+	// new required field can't be read from old data, but old consumers just ignore it
+	type rateRequiredField struct {
+		Base, Date, Source string
+		Rate               float64
+	}
+	next := mustSchema(t, rateRequiredField{})
+	if err := CheckCompatibility(CompatibilityBackward, latest, next); err == nil {
+		t.Error("new required field should break backward compatibility")
+	}
+	if err := CheckCompatibility(CompatibilityForward, latest, next); err != nil {
+		t.Errorf("new required field should keep forward compatibility: %v", err)
+	}
+}
+
+func testClient(t *testing.T, client Client) {
+	ctx := context.Background()
+	subject := Subject("currencies")
+
+	id, err := client.Register(ctx, subject, mustSchema(t, rate.Rate{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, err := client.Register(ctx, subject, mustSchema(t, rate.Rate{})); err != nil || again != id {
+		t.Fatalf("registering the same schema should return %d, got %d (%v)", id, again, err)
+	}
+	if _, err := client.Register(ctx, subject, mustSchema(t, rateChangedType{})); !errors.Is(err, ErrIncompatible) {
+		t.Fatalf("expected ErrIncompatible, got %v", err)
+	}
+	if _, err := client.Register(ctx, subject, mustSchema(t, rateOptionalField{})); err != nil {
+		t.Fatalf("compatible change should be registered: %v", err)
+	}
+
+	cpSubject := Subject("currently-playing")
+	if _, err := client.Register(ctx, cpSubject, mustSchema(t, spotify_generator.CurrentlyPlaying{})); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SetCompatibility(ctx, cpSubject, CompatibilityFull); err != nil {
+		t.Fatal(err)
+	}
+	if c, err := client.Compatibility(ctx, cpSubject); err != nil || c != CompatibilityFull {
+		t.Fatalf("expected FULL, got %s (%v)", c, err)
+	}
+	_, err = client.Register(ctx, cpSubject, mustSchema(t, currentlyPlayingChangedArtists{}))
+	if !errors.Is(err, ErrIncompatible) {
+		t.Fatalf("expected ErrIncompatible, got %v", err)
+	}
+
+	latest, err := client.Latest(ctx, subject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.Version != 2 || latest.Schema != mustSchema(t, rateOptionalField{}) {
+		t.Fatalf("unexpected latest version %+v", latest)
+	}
+	schema, err := client.Schema(ctx, id)
+	if err != nil || schema != mustSchema(t, rate.Rate{}) {
+		t.Fatalf("unexpected schema %d: %s (%v)", id, schema, err)
+	}
+	if _, err := client.Latest(ctx, "unknown"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	// codec writes Confluent wire format with registered id
+	c, err := NewCodec(ctx, client, subject, rate.Rate{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := c.Encode(rate.Rate{Base: "EUR", Rate: 1.18, Date: "2020-09-04"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wireID, _, err := DecodeWire(data); err != nil || wireID != id {
+		t.Fatalf("expected schema id %d, got %d (%v)", id, wireID, err)
+	}
+	v, err := c.Decode(data)
+	if err != nil || v.(*rate.Rate).Rate != 1.18 {
+		t.Fatalf("unexpected decoded %+v (%v)", v, err)
+	}
+}
+
+func TestFileClient(t *testing.T) {
+	testClient(t, NewFileClient(filepath.Join(t.TempDir(), "schemas.json")))
+}
+
+// fakeRegistry serves subset of Confluent REST API backed by file client,
+// it does not check compatibility so client side checks are tested
+func fakeRegistry(t *testing.T) *httptest.Server {
+	backend := NewFileClient(filepath.Join(t.TempDir(), "schemas.json")).(*fileClient)
+	levels := make(map[string]Compatibility)
+	ctx := context.Background()
+	reply := func(w http.ResponseWriter, v interface{}, err error) {
+		if errors.Is(err, ErrNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(apiError{ErrorCode: 40401, Message: err.Error()})
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(apiError{ErrorCode: 50001, Message: err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(v)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		switch {
+		case r.Method == http.MethodPost && len(parts) == 3 && parts[0] == "subjects":
+			var req struct{ Schema, SchemaType string }
+			json.NewDecoder(r.Body).Decode(&req)
+			if req.SchemaType != SchemaTypeJSON {
+				t.Errorf("unexpected schema type %q", req.SchemaType)
+			}
+			state, _ := backend.load()
+			sub, ok := state.Subjects[parts[1]]
+			if !ok {
+				sub = &fileSubject{}
+				state.Subjects[parts[1]] = sub
+			}
+			// bypass compatibility checks of file client
+			sub.Compatibility = CompatibilityNone
+			backend.save(state)
+			id, err := backend.Register(ctx, parts[1], req.Schema)
+			reply(w, map[string]int{"id": id}, err)
+		case r.Method == http.MethodGet && len(parts) == 4 && parts[0] == "subjects":
+			v, err := backend.Latest(ctx, parts[1])
+			reply(w, v, err)
+		case r.Method == http.MethodGet && len(parts) == 3 && parts[0] == "schemas":
+			id, _ := strconv.Atoi(parts[2])
+			s, err := backend.Schema(ctx, id)
+			reply(w, map[string]string{"schema": s}, err)
+		case r.Method == http.MethodGet && len(parts) == 1 && parts[0] == "config":
+			reply(w, map[string]Compatibility{"compatibilityLevel": DefaultCompatibility}, nil)
+		case r.Method == http.MethodGet && len(parts) == 2 && parts[0] == "config":
+			level, ok := levels[parts[1]]
+			if !ok {
+				reply(w, nil, ErrNotFound)
+				return
+			}
+			reply(w, map[string]Compatibility{"compatibilityLevel": level}, nil)
+		case r.Method == http.MethodPut && len(parts) == 2 && parts[0] == "config":
+			var req struct{ Compatibility Compatibility }
+			json.NewDecoder(r.Body).Decode(&req)
+			levels[parts[1]] = req.Compatibility
+			reply(w, req, nil)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestHTTPClient(t *testing.T) {
+	srv := fakeRegistry(t)
+	testClient(t, NewHTTPClient(srv.Client(), srv.URL))
+}
+
+func TestRegisterDefaults(t *testing.T) {
+	ctx := context.Background()
+	client := NewFileClient(filepath.Join(t.TempDir(), "schemas.json"))
+	reg := codec.NewRegistry()
+	if err := RegisterDefaults(ctx, client, reg, "currencies", "currently-playing"); err != nil {
+		t.Fatal(err)
+	}
+
+	// produced rates are in wire format and consumers decode them by schema headers
+	value, headers, err := reg.Encode(codec.SchemaRate, RateWireVersion, rate.Rate{Base: "EUR", Rate: 1.18, Date: "2020-09-04"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest, err := client.Latest(ctx, Subject("currencies"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, _, err := DecodeWire(value); err != nil || id != latest.ID {
+		t.Fatalf("expected schema id %d, got %d (%v)", latest.ID, id, err)
+	}
+	v, _, err := reg.Decode(kafka.Message{Value: value, Headers: headers})
+	if err != nil || v.(*rate.Rate).Rate != 1.18 {
+		t.Fatalf("unexpected decoded %+v (%v)", v, err)
+	}
+	if _, err := client.Latest(ctx, Subject("currently-playing")); err != nil {
+		t.Fatal(err)
+	}
+
+	if c, err := NewClient(http.DefaultClient, "", ""); c != nil || err != nil {
+		t.Fatalf("expected disabled schema registry, got %v (%v)", c, err)
+	}
+	if _, err := NewClient(http.DefaultClient, "http://localhost:8081", "schemas.json"); err == nil {
+		t.Fatal("expected error of url and file")
+	}
+}
@@ -0,0 +1,36 @@
+package schemaregistry
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// magicByte starts every message in Confluent wire format:
+// | 0x0 | 4 bytes big endian schema id | payload |
+const (
+	magicByte  = 0x0
+	headerSize = 5
+)
+
+var ErrNotWireFormat = errors.New("data is not in confluent wire format")
+
+// EncodeWire prefixes payload with magic byte and schema id
+func EncodeWire(id int, payload []byte) []byte {
+	b := make([]byte, headerSize, headerSize+len(payload))
+	b[0] = magicByte
+	binary.BigEndian.PutUint32(b[1:headerSize], uint32(id))
+	return append(b, payload...)
+}
+
+// DecodeWire returns schema id and payload of data in Confluent wire format
+func DecodeWire(data []byte) (int, []byte, error) {
+	if len(data) < headerSize || data[0] != magicByte {
+		return 0, nil, ErrNotWireFormat
+	}
+	id := binary.BigEndian.Uint32(data[1:headerSize])
+	if id == 0 {
+		return 0, nil, fmt.Errorf("%w: schema id 0", ErrNotWireFormat)
+	}
+	return int(id), data[headerSize:], nil
+}
@@ -0,0 +1,27 @@
+package codec
+
+import (
+	"kafka-tryout/src/rate"
+	"kafka-tryout/src/spotify_generator"
+)
+
+// names of the schemas used by producers and consumers of this repository
+const (
+	SchemaRate             = "rate"
+	SchemaProposition      = "proposition"
+	SchemaCurrentlyPlaying = "currently-playing"
+)
+
+// Default registry contains every schema of this repository, versions must never be changed
+// once they are used, new version should be registered instead
+var Default = NewRegistry()
+
+func init() {
+	Default.MustRegister(
+		Schema{Name: SchemaRate, Version: 1, Codec: NewJSONCodec(rate.Rate{})},
+		Schema{Name: SchemaRate, Version: 2, Codec: NewRateProtoCodec()},
+		Schema{Name: SchemaProposition, Version: 1, Codec: NewJSONCodec(spotify_generator.Proposition{})},
+		Schema{Name: SchemaCurrentlyPlaying, Version: 1, Codec: NewJSONCodec(spotify_generator.CurrentlyPlaying{})},
+		Schema{Name: SchemaCurrentlyPlaying, Version: 2, Codec: NewCurrentlyPlayingProtoCodec()},
+	)
+}
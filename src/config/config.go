@@ -0,0 +1,259 @@
+// Package config loads settings shared by all commands, values come from defaults, YAML file,
+// environment variables and flags, each one overriding the previous
+package config
+
+import (
+	"errors"
+	"fmt"
+	"kafka-tryout/src/kafka_server"
+	"kafka-tryout/src/rate"
+	"kafka-tryout/src/utils"
+	"strings"
+	"time"
+)
+
+const (
+	BackendKafka  = "kafka"
+	BackendFile   = "file"
+	BackendStdout = "stdout"
+
+	SASLPlain       = "plain"
+	SASLScramSHA256 = "scram-sha-256"
+	SASLScramSHA512 = "scram-sha-512"
+)
+
+type Config struct {
+	Brokers         []string       `yaml:"brokers"`
+	Backend         Backend        `yaml:"backend"`
+	TLS             TLS            `yaml:"tls"`
+	SASL            SASL           `yaml:"sasl"`
+	ShutdownTimeout time.Duration  `yaml:"shutdown_timeout"`
+	Topics          Topics         `yaml:"topics"`
+	Rates           Rates          `yaml:"rates"`
+	Producer        Producer       `yaml:"producer"`
+	Consumer        Consumer       `yaml:"consumer"`
+	Spotify         Spotify        `yaml:"spotify"`
+	Elastic         Elastic        `yaml:"elastic"`
+	SchemaRegistry  SchemaRegistry `yaml:"schema_registry"`
+}
+
+// Backend selects log the commands use, Kafka, local file log in Dir or stdout which only prints written messages,
+// RateLimit limits messages written per second by every writer, 0 disables it
+type Backend struct {
+	Kind      string `yaml:"kind"`
+	Dir       string `yaml:"dir"`
+	RateLimit int    `yaml:"rate_limit"`
+}
+
+type TLS struct {
+	Enabled bool `yaml:"enabled"`
+	// CAFile verifies brokers, system roots are used when it's empty
+	CAFile string `yaml:"ca_file"`
+	// CertFile and KeyFile authenticate client, both or none have to be set
+	CertFile           string `yaml:"cert_file"`
+	KeyFile            string `yaml:"key_file"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+type SASL struct {
+	// Mechanism is one of SASLPlain, SASLScramSHA256, SASLScramSHA512, empty disables SASL
+	Mechanism string `yaml:"mechanism"`
+	Username  string `yaml:"username"`
+	Password  string `yaml:"password"`
+}
+
+type Topics struct {
+	Currencies       string `yaml:"currencies"`
+	CrossRates       string `yaml:"cross_rates"`
+	Spotify          string `yaml:"spotify"`
+	CurrentlyPlaying string `yaml:"currently_playing"`
+	// Partitions and ReplicationFactor are used when topics are created
+	Partitions        int `yaml:"partitions"`
+	ReplicationFactor int `yaml:"replication_factor"`
+}
+
+// Rates configure rate sources, see Rates.NewSources
+type Rates struct {
+	// Sources is comma separated list of sources, next ones are used when previous fail
+	Sources string `yaml:"sources"`
+	// URLs and HistoryURLs are keyed by source, e.g. ecb or json, sources without them use their defaults
+	URLs        map[string]string `yaml:"urls"`
+	HistoryURLs map[string]string `yaml:"history_urls"`
+	File        string            `yaml:"file"`
+	JSONBase    string            `yaml:"json_base"`
+	JSONDate    string            `yaml:"json_date"`
+	JSONRates   string            `yaml:"json_rates"`
+	// JSONFixedBase is the base of sources which don't return it
+	JSONFixedBase string `yaml:"json_fixed_base"`
+}
+
+type Producer struct {
+	Goroutines int           `yaml:"goroutines"`
+	Interval   time.Duration `yaml:"interval"`
+	// ChangeThreshold is relative change of rate required to publish it, e.g. 0.001 = 0.1%
+	ChangeThreshold float64 `yaml:"change_threshold"`
+	// StateFile keeps published rates between restarts
+	StateFile string `yaml:"state_file"`
+	// CrossPairs enables cross rates, "all" publishes full matrix, otherwise it's comma separated list of pairs
+	CrossPairs string `yaml:"cross_pairs"`
+}
+
+type Consumer struct {
+	GroupID string `yaml:"group_id"`
+	Workers int    `yaml:"workers"`
+	// DispatchBy is "key" or "partition"
+	DispatchBy string `yaml:"dispatch_by"`
+	// CommitStrategy is "message", "batch:N" or "interval:D"
+	CommitStrategy string `yaml:"commit_strategy"`
+	RetryAttempts  int    `yaml:"retry_attempts"`
+	DedupSize      int    `yaml:"dedup_size"`
+	// DedupFile keeps handled ids between restarts
+	DedupFile string `yaml:"dedup_file"`
+}
+
+type Spotify struct {
+	Goroutines  int           `yaml:"goroutines"`
+	BatchSize   int           `yaml:"batch_size"`
+	BatchBytes  int           `yaml:"batch_bytes"`
+	BatchLinger time.Duration `yaml:"batch_linger"`
+}
+
+// Elastic configures Elasticsearch sink of consumer, it's disabled when Addresses are empty
+type Elastic struct {
+	Addresses []string `yaml:"addresses"`
+	Username  string   `yaml:"username"`
+	Password  string   `yaml:"password"`
+	// ID is "offset", "natural" or "auto", see consumer.IDMode
+	ID            string        `yaml:"id"`
+	BulkSize      int           `yaml:"bulk_size"`
+	FlushInterval time.Duration `yaml:"flush_interval"`
+	// MaxRetries is how many times documents rejected with 429 are sent again
+	MaxRetries int `yaml:"max_retries"`
+	// Templates puts index templates of daily indices on start
+	Templates bool `yaml:"templates"`
+}
+
+// SchemaRegistry switches rates and currently playing tracks to Confluent wire format,
+// it's disabled when both URL and File are empty
+type SchemaRegistry struct {
+	URL string `yaml:"url"`
+	// File keeps schemas locally instead of registry at URL
+	File string `yaml:"file"`
+}
+
+// Default returns configuration used when nothing overrides it
+func Default() Config {
+	return Config{
+		Brokers:         []string{kafka_server.Address},
+		Backend:         Backend{Kind: BackendKafka, Dir: "log"},
+		ShutdownTimeout: utils.DefaultShutdownTimeout,
+		Topics: Topics{
+			Currencies:        "currencies",
+			CrossRates:        "currency-pairs",
+			Spotify:           "spotify",
+			CurrentlyPlaying:  "currently-playing",
+			Partitions:        3,
+			ReplicationFactor: 1,
+		},
+		Rates:    Rates{Sources: "ecb"},
+		Producer: Producer{Goroutines: 10, Interval: 10 * time.Second},
+		Consumer: Consumer{
+			GroupID:        "consumer-group",
+			Workers:        5,
+			DispatchBy:     "key",
+			CommitStrategy: "interval:1s",
+			RetryAttempts:  3,
+			DedupSize:      100000,
+		},
+		Spotify: Spotify{Goroutines: 1, BatchSize: 100, BatchBytes: 1 << 20, BatchLinger: time.Second},
+		Elastic: Elastic{ID: "offset", BulkSize: 500, FlushInterval: time.Second, MaxRetries: 5, Templates: true},
+	}
+}
+
+// Validate returns all problems of configuration joined in single error
+func (c Config) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	switch c.Backend.Kind {
+	case BackendKafka:
+		check(len(c.Brokers) > 0, "brokers are required by kafka backend")
+	case BackendFile:
+		check(c.Backend.Dir != "", "backend.dir is required by file backend")
+	case BackendStdout:
+	default:
+		check(false, "unknown backend.kind %q", c.Backend.Kind)
+	}
+	check(c.Backend.RateLimit >= 0, "backend.rate_limit can't be negative")
+	check(c.ShutdownTimeout > 0, "shutdown_timeout must be positive")
+
+	check(c.TLS.Enabled || c.TLS.CAFile == "" && c.TLS.CertFile == "" && c.TLS.KeyFile == "",
+		"tls files are set but tls is disabled")
+	check((c.TLS.CertFile == "") == (c.TLS.KeyFile == ""), "tls.cert_file and tls.key_file must be set together")
+	switch c.SASL.Mechanism {
+	case "":
+	case SASLPlain, SASLScramSHA256, SASLScramSHA512:
+		check(c.SASL.Username != "", "sasl.username is required by %s", c.SASL.Mechanism)
+	default:
+		check(false, "unknown sasl.mechanism %q", c.SASL.Mechanism)
+	}
+
+	check(c.Topics.Currencies != "" && c.Topics.CrossRates != "" && c.Topics.Spotify != "" && c.Topics.CurrentlyPlaying != "",
+		"topic names can't be empty")
+	check(c.Topics.Partitions > 0, "topics.partitions must be positive")
+	check(c.Topics.ReplicationFactor > 0, "topics.replication_factor must be positive")
+
+	for _, urls := range []map[string]string{c.Rates.URLs, c.Rates.HistoryURLs} {
+		for source := range urls {
+			check(source == rate.SourceECB || source == rate.SourceJSON, "rate source URLs are keyed by ecb or json, got %q", source)
+		}
+	}
+
+	check(c.Producer.Goroutines > 0, "producer.goroutines must be positive")
+	check(c.Producer.Interval > 0, "producer.interval must be positive")
+	check(c.Producer.ChangeThreshold >= 0, "producer.change_threshold can't be negative")
+
+	check(c.Consumer.GroupID != "", "consumer.group_id is required")
+	check(c.Consumer.Workers > 0, "consumer.workers must be positive")
+	check(c.Consumer.DispatchBy == "key" || c.Consumer.DispatchBy == "partition",
+		"consumer.dispatch_by must be key or partition, got %q", c.Consumer.DispatchBy)
+	check(validCommitStrategy(c.Consumer.CommitStrategy), "invalid consumer.commit_strategy %q", c.Consumer.CommitStrategy)
+	check(c.Consumer.RetryAttempts >= 0, "consumer.retry_attempts can't be negative")
+	check(c.Consumer.DedupSize > 0, "consumer.dedup_size must be positive")
+
+	check(c.Spotify.Goroutines > 0, "spotify.goroutines must be positive")
+	check(c.Spotify.BatchSize >= 0 && c.Spotify.BatchBytes >= 0 && c.Spotify.BatchLinger >= 0,
+		"spotify batch limits can't be negative")
+
+	check(c.Elastic.ID == "offset" || c.Elastic.ID == "natural" || c.Elastic.ID == "auto",
+		"elastic.id must be offset, natural or auto, got %q", c.Elastic.ID)
+	check(c.Elastic.BulkSize > 0, "elastic.bulk_size must be positive")
+	check(c.Elastic.FlushInterval > 0, "elastic.flush_interval must be positive")
+	check(c.Elastic.MaxRetries >= 0, "elastic.max_retries can't be negative")
+
+	check(c.SchemaRegistry.URL == "" || c.SchemaRegistry.File == "", "schema_registry.url and schema_registry.file can't be used together")
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// validCommitStrategy checks only the form of strategy, consumer.ParseCommitStrategy parses it
+func validCommitStrategy(s string) bool {
+	kind, arg := s, ""
+	if idx := strings.Index(s, ":"); idx >= 0 {
+		kind, arg = s[:idx], s[idx+1:]
+	}
+	switch kind {
+	case "message":
+		return arg == ""
+	case "batch", "interval":
+		return arg != ""
+	}
+	return false
+}
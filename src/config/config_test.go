@@ -0,0 +1,82 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func load(t *testing.T, args ...string) (Config, error) {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(new(strings.Builder))
+	return Load(fs, args)
+}
+
+func setEnv(t *testing.T, key, value string) {
+	t.Helper()
+	os.Setenv(key, value)
+	t.Cleanup(func() { os.Unsetenv(key) })
+}
+
+func TestLoadDefaults(t *testing.T) {
+	c, err := load(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c, Default()) {
+		t.Fatalf("expected defaults, got %+v", c)
+	}
+}
+
+func TestLoadOverrides(t *testing.T) {
+	setEnv(t, "GROUP_ID", "env-group")
+	setEnv(t, "PARTITIONS", "12")
+	c, err := load(t, "-config", "testdata/config.yaml", "-partitions", "24", "-tls-insecure-skip-verify",
+		"-rate-source-url", "ECB=https://ecb.example.com/daily.xml, json=https://api.example.com/latest?base=EUR", "extra")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// file overrides defaults
+	if !reflect.DeepEqual(c.Brokers, []string{"kafka-1:9093", "kafka-2:9093"}) || c.Topics.Currencies != "rates" ||
+		c.ShutdownTimeout != 30*time.Second || c.Spotify.BatchLinger != 500*time.Millisecond || c.SASL.Username != "rates" {
+		t.Fatalf("file wasn't applied, got %+v", c)
+	}
+	// fields missing in file keep defaults
+	if c.Topics.Spotify != "spotify" || c.Spotify.BatchSize != 100 {
+		t.Fatalf("defaults were lost, got %+v", c)
+	}
+	// every rate source has its own URLs
+	if c.Rates.URLs["ecb"] != "https://ecb.example.com/daily.xml" || c.Rates.URLs["json"] != "https://api.example.com/latest?base=EUR" ||
+		c.Rates.HistoryURLs["ecb"] != "https://ecb.example.com/hist.xml" {
+		t.Fatalf("rate source URLs weren't applied, got %+v", c.Rates)
+	}
+	// environment overrides file and flags override environment
+	if c.Consumer.GroupID != "env-group" || c.Topics.Partitions != 24 || !c.TLS.InsecureSkipVerify {
+		t.Fatalf("overrides weren't applied, got %+v", c)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	for name, args := range map[string][]string{
+		"unknown backend":       {"-backend", "memory"},
+		"negative rate limit":   {"-write-rate-limit", "-1"},
+		"not a number":          {"-partitions", "many"},
+		"cert without key":      {"-tls", "-tls-cert", "client.pem"},
+		"sasl without user":     {"-sasl-mechanism", "plain"},
+		"unknown commit":        {"-commit-strategy", "sometimes"},
+		"unknown elastic id":    {"-elastic-id", "uuid"},
+		"two schema registries": {"-schema-registry-url", "http://localhost:8081", "-schema-registry-file", "schemas.json"},
+		"rate url without key":  {"-rate-source-url", "https://api.example.com/latest"},
+		"url of file source":    {"-rate-source-url", "file=rates.json"},
+		"missing config file":   {"-config", "testdata/missing.yaml"},
+	} {
+		if _, err := load(t, args...); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
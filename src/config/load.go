@@ -0,0 +1,231 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"kafka-tryout/src/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// setting binds a field of Config to environment variable and flag
+type setting struct {
+	env   string
+	flag  string
+	usage string
+	set   func(string) error
+	// isBool settings are enabled by flag without value, e.g. -tls
+	isBool bool
+}
+
+// flagValue keeps flag value until file and environment are applied, so flags override them
+type flagValue struct {
+	raw    string
+	isSet  bool
+	isBool bool
+}
+
+func (v *flagValue) String() string {
+	return v.raw
+}
+
+func (v *flagValue) IsBoolFlag() bool {
+	return v.isBool
+}
+
+func (v *flagValue) Set(s string) error {
+	v.raw, v.isSet = s, true
+	return nil
+}
+
+// Load registers flags of all settings and -config in fs, parses args and returns validated configuration,
+// file is taken from -config flag or CONFIG_FILE variable, arguments left after flags are in fs.Args()
+func Load(fs *flag.FlagSet, args []string) (Config, error) {
+	c := Default()
+	settings := c.settings()
+
+	path := fs.String("config", utils.EnvOrDefault("CONFIG_FILE", ""), "YAML configuration file, CONFIG_FILE")
+	values := make([]*flagValue, len(settings))
+	for i, s := range settings {
+		values[i] = &flagValue{isBool: s.isBool}
+		fs.Var(values[i], s.flag, s.usage+", "+s.env)
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	if *path != "" {
+		if err := c.loadFile(*path); err != nil {
+			return Config{}, err
+		}
+	}
+	for _, s := range settings {
+		if val := utils.EnvOrDefault(s.env, ""); val != "" {
+			if err := s.set(val); err != nil {
+				return Config{}, fmt.Errorf("invalid %s, %w", s.env, err)
+			}
+		}
+	}
+	for i, s := range settings {
+		if values[i].isSet {
+			if err := s.set(values[i].raw); err != nil {
+				return Config{}, fmt.Errorf("invalid -%s, %w", s.flag, err)
+			}
+		}
+	}
+
+	if err := c.Validate(); err != nil {
+		return Config{}, err
+	}
+	return c, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file, %w", err)
+	}
+	if err := yaml.UnmarshalStrict(b, c); err != nil {
+		return fmt.Errorf("failed to decode config file %s, %w", path, err)
+	}
+	return nil
+}
+
+// settings lists fields configurable with environment variables and flags, variables used
+// before the config package existed keep their names
+func (c *Config) settings() []setting {
+	return []setting{
+		listSetting("BROKERS", "brokers", "comma separated broker addresses", &c.Brokers),
+		stringSetting("BACKEND", "backend", "log backend, kafka, file or stdout", &c.Backend.Kind),
+		stringSetting("LOG_DIR", "log-dir", "directory of file backend", &c.Backend.Dir),
+		intSetting("WRITE_RATE_LIMIT", "write-rate-limit", "messages written per second by every writer, 0 is unlimited", &c.Backend.RateLimit),
+		durationSetting("SHUTDOWN_TIMEOUT", "shutdown-timeout", "time given to finish in-flight work", &c.ShutdownTimeout),
+
+		boolSetting("TLS_ENABLED", "tls", "connect to brokers with TLS", &c.TLS.Enabled),
+		stringSetting("TLS_CA_FILE", "tls-ca", "CA certificate verifying brokers", &c.TLS.CAFile),
+		stringSetting("TLS_CERT_FILE", "tls-cert", "client certificate", &c.TLS.CertFile),
+		stringSetting("TLS_KEY_FILE", "tls-key", "client key", &c.TLS.KeyFile),
+		boolSetting("TLS_INSECURE_SKIP_VERIFY", "tls-insecure-skip-verify", "don't verify brokers", &c.TLS.InsecureSkipVerify),
+		stringSetting("SASL_MECHANISM", "sasl-mechanism", "plain, scram-sha-256 or scram-sha-512", &c.SASL.Mechanism),
+		stringSetting("SASL_USERNAME", "sasl-username", "SASL user", &c.SASL.Username),
+		stringSetting("SASL_PASSWORD", "sasl-password", "SASL password", &c.SASL.Password),
+
+		stringSetting("TOPIC", "topic", "currencies topic", &c.Topics.Currencies),
+		stringSetting("CROSS_TOPIC", "cross-topic", "cross rates topic", &c.Topics.CrossRates),
+		stringSetting("SPOTIFY_TOPIC", "spotify-topic", "spotify propositions topic", &c.Topics.Spotify),
+		stringSetting("CURRENTLY_PLAYING_TOPIC", "currently-playing-topic", "currently playing topic", &c.Topics.CurrentlyPlaying),
+		intSetting("PARTITIONS", "partitions", "partitions of created topics", &c.Topics.Partitions),
+		intSetting("REPLICATION_FACTOR", "replication-factor", "replication factor of created topics", &c.Topics.ReplicationFactor),
+
+		stringSetting("RATE_SOURCE", "rate-source", "comma separated rate sources, ecb, json or file", &c.Rates.Sources),
+		mapSetting("RATE_SOURCE_URL", "rate-source-url", "rate source URLs, e.g. ecb=URL,json=URL", &c.Rates.URLs),
+		mapSetting("RATE_HISTORY_URL", "rate-history-url", "historical rates URLs, e.g. ecb=URL,json=URL", &c.Rates.HistoryURLs),
+		stringSetting("RATE_SOURCE_FILE", "rate-source-file", "rate fixture file", &c.Rates.File),
+		stringSetting("RATE_JSON_BASE", "rate-json-base", "base field of JSON source", &c.Rates.JSONBase),
+		stringSetting("RATE_JSON_DATE", "rate-json-date", "date field of JSON source", &c.Rates.JSONDate),
+		stringSetting("RATE_JSON_RATES", "rate-json-rates", "rates field of JSON source", &c.Rates.JSONRates),
+		stringSetting("RATE_JSON_FIXED_BASE", "rate-json-fixed-base", "base of JSON source without base field", &c.Rates.JSONFixedBase),
+
+		intSetting("PRODUCER_GOROUTINES", "producer-goroutines", "goroutines producing rates", &c.Producer.Goroutines),
+		durationSetting("PRODUCER_INTERVAL", "producer-interval", "sleep between produced snapshots", &c.Producer.Interval),
+		floatSetting("RATE_CHANGE_THRESHOLD", "rate-change-threshold", "relative rate change required to publish it", &c.Producer.ChangeThreshold),
+		stringSetting("RATE_STATE_FILE", "rate-state-file", "file keeping published rates", &c.Producer.StateFile),
+		stringSetting("CROSS_PAIRS", "cross-pairs", "all or comma separated cross rate pairs, e.g. USD/PLN", &c.Producer.CrossPairs),
+
+		stringSetting("GROUP_ID", "group-id", "consumer group", &c.Consumer.GroupID),
+		intSetting("CONSUMER_WORKERS", "consumer-workers", "goroutines handling messages", &c.Consumer.Workers),
+		stringSetting("DISPATCH_BY", "dispatch-by", "key or partition", &c.Consumer.DispatchBy),
+		stringSetting("COMMIT_STRATEGY", "commit-strategy", "message, batch:N or interval:D", &c.Consumer.CommitStrategy),
+		intSetting("RETRY_ATTEMPTS", "retry-attempts", "retries before dead letter topic", &c.Consumer.RetryAttempts),
+		intSetting("DEDUP_SIZE", "dedup-size", "remembered message ids", &c.Consumer.DedupSize),
+		stringSetting("DEDUP_FILE", "dedup-file", "file keeping handled message ids", &c.Consumer.DedupFile),
+
+		intSetting("SPOTIFY_GOROUTINES", "spotify-goroutines", "goroutines polling spotify", &c.Spotify.Goroutines),
+		intSetting("BATCH_SIZE", "batch-size", "messages in spotify batch", &c.Spotify.BatchSize),
+		intSetting("BATCH_BYTES", "batch-bytes", "bytes in spotify batch", &c.Spotify.BatchBytes),
+		durationSetting("BATCH_LINGER", "batch-linger", "time spotify batch waits for more messages", &c.Spotify.BatchLinger),
+
+		listSetting("ELASTIC_ADDRESSES", "elastic-addresses", "comma separated Elasticsearch URLs, empty disables sink", &c.Elastic.Addresses),
+		stringSetting("ELASTIC_USERNAME", "elastic-username", "Elasticsearch user", &c.Elastic.Username),
+		stringSetting("ELASTIC_PASSWORD", "elastic-password", "Elasticsearch password", &c.Elastic.Password),
+		stringSetting("ELASTIC_ID", "elastic-id", "document ids, offset, natural or auto", &c.Elastic.ID),
+		intSetting("ELASTIC_BULK_SIZE", "elastic-bulk-size", "documents in bulk request", &c.Elastic.BulkSize),
+		durationSetting("ELASTIC_FLUSH_INTERVAL", "elastic-flush-interval", "time documents wait for full bulk", &c.Elastic.FlushInterval),
+		intSetting("ELASTIC_MAX_RETRIES", "elastic-max-retries", "retries of documents rejected with 429", &c.Elastic.MaxRetries),
+		boolSetting("ELASTIC_TEMPLATES", "elastic-templates", "put index templates on start", &c.Elastic.Templates),
+
+		stringSetting("SCHEMA_REGISTRY_URL", "schema-registry-url", "schema registry URL, empty disables wire format", &c.SchemaRegistry.URL),
+		stringSetting("SCHEMA_REGISTRY_FILE", "schema-registry-file", "file keeping schemas instead of schema registry", &c.SchemaRegistry.File),
+	}
+}
+
+func stringSetting(env, name, usage string, p *string) setting {
+	return setting{env: env, flag: name, usage: usage, set: func(s string) error {
+		*p = s
+		return nil
+	}}
+}
+
+func listSetting(env, name, usage string, p *[]string) setting {
+	return setting{env: env, flag: name, usage: usage, set: func(s string) error {
+		var list []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		*p = list
+		return nil
+	}}
+}
+
+// mapSetting parses comma separated key=value pairs, keys are lower cased
+func mapSetting(env, name, usage string, p *map[string]string) setting {
+	return setting{env: env, flag: name, usage: usage, set: func(s string) error {
+		m := make(map[string]string)
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			kv := strings.SplitN(item, "=", 2)
+			if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+				return fmt.Errorf("expected key=value, got %q", item)
+			}
+			m[strings.ToLower(strings.TrimSpace(kv[0]))] = strings.TrimSpace(kv[1])
+		}
+		*p = m
+		return nil
+	}}
+}
+
+func intSetting(env, name, usage string, p *int) setting {
+	return setting{env: env, flag: name, usage: usage, set: func(s string) (err error) {
+		*p, err = strconv.Atoi(s)
+		return err
+	}}
+}
+
+func floatSetting(env, name, usage string, p *float64) setting {
+	return setting{env: env, flag: name, usage: usage, set: func(s string) (err error) {
+		*p, err = strconv.ParseFloat(s, 64)
+		return err
+	}}
+}
+
+func boolSetting(env, name, usage string, p *bool) setting {
+	return setting{env: env, flag: name, usage: usage, isBool: true, set: func(s string) (err error) {
+		*p, err = strconv.ParseBool(s)
+		return err
+	}}
+}
+
+func durationSetting(env, name, usage string, p *time.Duration) setting {
+	return setting{env: env, flag: name, usage: usage, set: func(s string) (err error) {
+		*p, err = time.ParseDuration(s)
+		return err
+	}}
+}
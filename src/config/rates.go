@@ -0,0 +1,26 @@
+package config
+
+import (
+	"kafka-tryout/src/rate"
+
+	"github.com/sirupsen/logrus"
+)
+
+// NewSources builds rate.RateSource from rates configuration, next sources are used when previous fail,
+// every source gets its own URLs
+func (r Rates) NewSources(log logrus.FieldLogger) (rate.RateSource, error) {
+	return rate.NewSources(r.Sources, func(kind string) rate.SourceConfig {
+		return rate.SourceConfig{
+			Log:        log,
+			URL:        r.URLs[kind],
+			HistoryURL: r.HistoryURLs[kind],
+			Path:       r.File,
+			Mapping: rate.JSONMapping{
+				Base:      r.JSONBase,
+				Date:      r.JSONDate,
+				Rates:     r.JSONRates,
+				FixedBase: r.JSONFixedBase,
+			},
+		}
+	})
+}
@@ -0,0 +1,197 @@
+package console
+
+import (
+	"bytes"
+	"encoding/json"
+	"kafka-tryout/src/admin"
+	"kafka-tryout/src/codec"
+	"kafka-tryout/src/rate"
+	"kafka-tryout/src/spotify_generator"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestParse(t *testing.T) {
+	defaults := Parser{Key: "EUR", Headers: map[string]string{"source": "console"}, Partition: -1}
+
+	lines := defaults
+	lines.Format = FormatLines
+	m, err := lines.Parse("hello")
+	if err != nil || string(m.Key) != "EUR" || string(m.Value) != "hello" || m.Partition != -1 ||
+		len(m.Headers) != 1 || m.Headers[0].Key != "source" {
+		t.Fatalf("unexpected line message %+v, %v", m, err)
+	}
+
+	kv := defaults
+	kv.Format = FormatKeyValue
+	m, err = kv.Parse(`USD={"Rate":1.18}`)
+	if err != nil || string(m.Key) != "USD" || string(m.Value) != `{"Rate":1.18}` {
+		t.Fatalf("unexpected key=value message %+v, %v", m, err)
+	}
+	if _, err := kv.Parse("no separator"); err == nil {
+		t.Fatal("expected error of line without =")
+	}
+
+	js := defaults
+	js.Format = FormatJSON
+	m, err = js.Parse(`{"key":"USD","value":{"Rate":1.18},"headers":{"schema":"rate"},"partition":2}`)
+	if err != nil || string(m.Key) != "USD" || string(m.Value) != `{"Rate":1.18}` || m.Partition != 2 {
+		t.Fatalf("unexpected JSON message %+v, %v", m, err)
+	}
+	if len(m.Headers) != 2 || m.Headers[0].Key != "schema" || m.Headers[1].Key != "source" {
+		t.Fatalf("expected merged sorted headers, got %+v", m.Headers)
+	}
+	m, err = js.Parse(`{"value":"plain text"}`)
+	if err != nil || string(m.Key) != "EUR" || string(m.Value) != "plain text" || m.Partition != -1 {
+		t.Fatalf("unexpected JSON string message %+v, %v", m, err)
+	}
+	if _, err := js.Parse(`{"key":"USD"}`); err == nil {
+		t.Fatal("expected error of JSON line without value")
+	}
+
+	if _, err := (Parser{Format: "xml"}).Parse("x"); err == nil {
+		t.Fatal("expected error of unknown format")
+	}
+}
+
+func TestHeaderFlags(t *testing.T) {
+	var h HeaderFlags
+	if err := h.Set("schema=rate"); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Set("expr=a=b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Set("=value"); err == nil {
+		t.Fatal("expected error of header without key")
+	}
+	if m := h.Map(); len(m) != 2 || m["schema"] != "rate" || m["expr"] != "a=b" {
+		t.Fatalf("unexpected headers %v", m)
+	}
+}
+
+func TestExplicitBalancer(t *testing.T) {
+	b := ExplicitBalancer{Fallback: &kafka.Hash{}}
+	if p := b.Balance(kafka.Message{Partition: 2}, 0, 1, 2); p != 2 {
+		t.Fatalf("expected explicit partition 2, got %d", p)
+	}
+	m := kafka.Message{Key: []byte("USD"), Partition: -1}
+	if p, expected := b.Balance(m, 0, 1, 2), (&kafka.Hash{}).Balance(m, 0, 1, 2); p != expected {
+		t.Fatalf("expected hashed partition %d, got %d", expected, p)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	m := kafka.Message{Key: []byte("USD"), Headers: []kafka.Header{{Key: "schema", Value: []byte("rate")}}}
+	for _, c := range []struct {
+		filter Filter
+		match  bool
+	}{
+		{Filter{}, true},
+		{Filter{Key: "USD"}, true},
+		{Filter{Key: "GBP"}, false},
+		{Filter{Headers: map[string]string{"schema": "rate"}}, true},
+		{Filter{Key: "USD", Headers: map[string]string{"schema": "proposition"}}, false},
+		{Filter{Headers: map[string]string{"source": "console"}}, false},
+	} {
+		if c.filter.Match(m) != c.match {
+			t.Errorf("expected %+v to match %t", c.filter, c.match)
+		}
+	}
+}
+
+func TestPrinter(t *testing.T) {
+	value, headers, err := codec.Default.Encode(codec.SchemaRate, 2, &rate.Rate{Base: "EUR", Rate: 1.18, Date: "2020-09-04"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2020, 9, 4, 10, 0, 0, 0, time.UTC)
+	m := kafka.Message{Topic: "currencies", Partition: 1, Offset: 7, Key: []byte("USD"), Value: value, Headers: headers, Time: at}
+
+	var buf bytes.Buffer
+	p, _ := NewPrinter(&buf, OutputText, nil)
+	if err := p.Print(m); err != nil {
+		t.Fatal(err)
+	}
+	expected := "currencies[1]@7 2020-09-04T10:00:00Z key=USD schema=rate schema-version=2 | 1 EUR = 1.18 USD on 2020-09-04\n"
+	if buf.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, buf.String())
+	}
+
+	// legacy rates without schema headers are decoded with fallback schema
+	legacy := kafka.Message{Topic: "currencies", Key: []byte("USD"), Value: []byte(`{"Base":"EUR","Rate":1.18,"Date":"2020-09-04"}`), Time: at}
+	fallback, _ := codec.Default.Lookup(codec.SchemaRate, 1)
+	buf.Reset()
+	p, _ = NewPrinter(&buf, OutputText, &fallback)
+	if err := p.Print(legacy); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(buf.String(), "| 1 EUR = 1.18 USD on 2020-09-04\n") {
+		t.Fatalf("expected decoded legacy rate, got %q", buf.String())
+	}
+
+	buf.Reset()
+	p, _ = NewPrinter(&buf, OutputJSON, nil)
+	if err := p.Print(m); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Print(kafka.Message{Topic: "test", Value: []byte{0xff}, Time: at}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	var printed struct {
+		Schema string
+		Value  interface{}
+		Key    string
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &printed); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := printed.Value.(map[string]interface{}); !ok || printed.Schema != "rate/v2" || v["Base"] != "EUR" {
+		t.Fatalf("unexpected JSON line %s", lines[0])
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &printed); err != nil || printed.Value != `"\xff"` {
+		t.Fatalf("expected quoted invalid UTF-8 value, got %s", lines[1])
+	}
+
+	if _, err := NewPrinter(&buf, "table", nil); err == nil {
+		t.Fatal("expected error of unknown output")
+	}
+}
+
+func TestPrettyCurrentlyPlaying(t *testing.T) {
+	cp := &spotify_generator.CurrentlyPlaying{
+		PlayedAt:   time.Date(2020, 11, 1, 10, 0, 0, 0, time.UTC),
+		Artists:    []spotify_generator.Artist{{Name: "Daft Punk"}, {Name: "Pharrell Williams"}},
+		TrackName:  "Get Lucky",
+		DurationMs: 248413,
+	}
+	expected := "Daft Punk, Pharrell Williams - Get Lucky (4m8s) played at 2020-11-01T10:00:00Z"
+	if s := Pretty("", cp); s != expected {
+		t.Fatalf("expected %q, got %q", expected, s)
+	}
+}
+
+func TestSkip(t *testing.T) {
+	at := time.Date(2020, 11, 1, 10, 0, 0, 0, time.UTC)
+	m := kafka.Message{Partition: 1, Offset: 5, Time: at}
+	for _, c := range []struct {
+		partition int
+		from      admin.ResetTarget
+		skip      bool
+	}{
+		{-1, admin.ResetTarget{Position: admin.ResetEarliest}, false},
+		{0, admin.ResetTarget{Position: admin.ResetEarliest}, true},
+		{1, admin.ResetTarget{Position: admin.ResetOffset, Offset: 5}, false},
+		{-1, admin.ResetTarget{Position: admin.ResetOffset, Offset: 6}, true},
+		{-1, admin.ResetTarget{Position: admin.ResetTimestamp, Time: at}, false},
+		{-1, admin.ResetTarget{Position: admin.ResetTimestamp, Time: at.Add(time.Second)}, true},
+	} {
+		if skip(m, c.partition, c.from) != c.skip {
+			t.Errorf("expected skip %t of partition %d from %+v", c.skip, c.partition, c.from)
+		}
+	}
+}
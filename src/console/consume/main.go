@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"kafka-tryout/src/admin"
+	"kafka-tryout/src/backend"
+	"kafka-tryout/src/codec"
+	"kafka-tryout/src/codec/schemaregistry"
+	"kafka-tryout/src/config"
+	"kafka-tryout/src/console"
+	"kafka-tryout/src/utils"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const usage = `usage: consume [flags]
+
+prints messages of -topic until interrupt or -max messages, nothing is committed, e.g.
+  consume -topic spotify -from 2020-11-01T10:00:00Z -key 37i9dQZF1DXcBWIGoYBM5M
+
+flags:
+`
+
+func main() {
+	log := logrus.New()
+	logger := log.WithField("application", "Consume")
+
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	from := flag.String("from", admin.ResetLatest, "earliest, latest, RFC3339 time, e.g. 2020-11-01T10:00:00Z, or offset")
+	partition := flag.Int("partition", -1, "partition to read, -1 reads all")
+	key := flag.String("key", "", "prints only messages with key")
+	var headers console.HeaderFlags
+	flag.Var(&headers, "header", "prints only messages with header key=value, can be repeated")
+	output := flag.String("output", console.OutputText, "output format, text or json")
+	schema := flag.String("schema", "", "schema of messages without schema headers as name/version, e.g. rate/v1")
+	max := flag.Int("max", 0, "stops after printing max messages, 0 doesn't stop")
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		logger.WithError(err).Fatal("invalid configuration")
+	}
+	// with schema registry configured, messages in Confluent wire format are printed too
+	registry, err := schemaregistry.NewClient(http.DefaultClient, cfg.SchemaRegistry.URL, cfg.SchemaRegistry.File)
+	if err != nil {
+		logger.WithError(err).Fatal("failed to create schema registry client")
+	}
+	if registry != nil {
+		if err := schemaregistry.RegisterDefaults(context.Background(), registry, codec.Default, cfg.Topics.Currencies, cfg.Topics.CurrentlyPlaying); err != nil {
+			logger.WithError(err).Fatal("failed to register schemas")
+		}
+	}
+
+	target, err := admin.ParseResetTarget(*from)
+	if err != nil {
+		logger.WithError(err).Fatal("invalid -from")
+	}
+	filter := console.Filter{Key: *key, Headers: headers.Map()}
+	var fallback *codec.Schema
+	if *schema != "" {
+		s, err := parseSchema(*schema)
+		if err != nil {
+			logger.WithError(err).Fatal("invalid schema")
+		}
+		fallback = &s
+	}
+	printer, err := console.NewPrinter(os.Stdout, *output, fallback)
+	if err != nil {
+		logger.WithError(err).Fatal("invalid output")
+	}
+
+	b, err := backend.FromConfig(cfg)
+	if err != nil {
+		logger.WithError(err).Fatal("invalid backend")
+	}
+	ctx, cancel := utils.SignalContext()
+	defer cancel()
+	topic := cfg.Topics.Currencies
+	source, err := console.OpenSource(ctx, b, topic, *partition, target)
+	if err != nil {
+		logger.WithError(err).Fatal("failed to open " + topic)
+	}
+	defer func() {
+		if err := utils.CloseWithin(source, cfg.ShutdownTimeout); err != nil {
+			logger.WithError(err).Error("failed to close reader")
+		}
+	}()
+
+	printed := 0
+	for *max == 0 || printed < *max {
+		m, err := source.FetchMessage(ctx)
+		if errors.Is(err, context.Canceled) {
+			break
+		}
+		if err != nil {
+			logger.WithError(err).Error("failed to fetch message")
+			return
+		}
+		if !filter.Match(m) {
+			continue
+		}
+		if err := printer.Print(m); err != nil {
+			logger.WithError(err).Error("failed to print message")
+			return
+		}
+		printed++
+	}
+	logger.WithField("topic", topic).Infof("%d messages printed", printed)
+}
+
+// parseSchema parses schema given as name/version and looks it up in the default registry
+func parseSchema(s string) (codec.Schema, error) {
+	i := strings.LastIndex(s, "/")
+	if i <= 0 {
+		return codec.Schema{}, fmt.Errorf("expected schema as name/version, got %q", s)
+	}
+	version, err := strconv.Atoi(strings.TrimPrefix(s[i+1:], "v"))
+	if err != nil {
+		return codec.Schema{}, fmt.Errorf("invalid version of schema %q, %w", s, err)
+	}
+	return codec.Default.Lookup(s[:i], version)
+}
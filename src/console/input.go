@@ -0,0 +1,142 @@
+// Package console reads messages for produce command from text input and prints messages read by consume command
+package console
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// input formats of produce command
+const (
+	// FormatLines writes every line as message value
+	FormatLines = "lines"
+	// FormatJSON reads JSON lines with key, value, headers and partition, e.g.
+	// {"key": "USD", "value": {"Base": "EUR", "Rate": 1.18}, "headers": {"source": "manual"}, "partition": 2}
+	FormatJSON = "json"
+	// FormatKeyValue splits lines to key and value at the first "=", e.g. USD={"Base": "EUR", "Rate": 1.18}
+	FormatKeyValue = "kv"
+)
+
+// jsonRecord is a line of JSON input, value which is JSON string is written as its text, other values as JSON
+type jsonRecord struct {
+	Key       string            `json:"key"`
+	Value     json.RawMessage   `json:"value"`
+	Headers   map[string]string `json:"headers"`
+	Partition *int              `json:"partition"`
+}
+
+// Parser turns input lines into messages, Key, Headers and Partition apply to messages which don't set them,
+// Partition -1 leaves partition to balancer
+type Parser struct {
+	Format    string
+	Key       string
+	Headers   map[string]string
+	Partition int
+}
+
+// Validate checks format of parser
+func (p Parser) Validate() error {
+	switch p.Format {
+	case FormatLines, FormatJSON, FormatKeyValue:
+		return nil
+	}
+	return fmt.Errorf("unknown format %q, expected %s, %s or %s", p.Format, FormatLines, FormatJSON, FormatKeyValue)
+}
+
+func (p Parser) Parse(line string) (kafka.Message, error) {
+	if err := p.Validate(); err != nil {
+		return kafka.Message{}, err
+	}
+
+	m := kafka.Message{Key: []byte(p.Key), Partition: p.Partition}
+	headers := p.Headers
+
+	switch p.Format {
+	case FormatLines:
+		m.Value = []byte(line)
+	case FormatKeyValue:
+		i := strings.Index(line, "=")
+		if i < 0 {
+			return m, fmt.Errorf("expected key=value, got %q", line)
+		}
+		m.Key, m.Value = []byte(line[:i]), []byte(line[i+1:])
+	case FormatJSON:
+		var r jsonRecord
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			return m, fmt.Errorf("invalid JSON line, %w", err)
+		}
+		if len(r.Value) == 0 {
+			return m, errors.New("JSON line has no value")
+		}
+		if r.Key != "" {
+			m.Key = []byte(r.Key)
+		}
+		m.Value = r.Value
+		var s string
+		if json.Unmarshal(r.Value, &s) == nil {
+			m.Value = []byte(s)
+		}
+		if r.Partition != nil {
+			m.Partition = *r.Partition
+		}
+		if len(r.Headers) > 0 {
+			headers = make(map[string]string, len(p.Headers)+len(r.Headers))
+			for k, v := range p.Headers {
+				headers[k] = v
+			}
+			for k, v := range r.Headers {
+				headers[k] = v
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(headers))
+	for k := range headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		m.Headers = append(m.Headers, kafka.Header{Key: k, Value: []byte(headers[k])})
+	}
+	return m, nil
+}
+
+// ExplicitBalancer writes messages to their Partition, messages with negative Partition are balanced by Fallback
+type ExplicitBalancer struct {
+	Fallback kafka.Balancer
+}
+
+func (b ExplicitBalancer) Balance(m kafka.Message, partitions ...int) int {
+	if m.Partition >= 0 {
+		return m.Partition
+	}
+	return b.Fallback.Balance(m, partitions...)
+}
+
+// HeaderFlags is repeated flag of key=value headers
+type HeaderFlags []string
+
+func (h *HeaderFlags) String() string { return strings.Join(*h, ",") }
+
+func (h *HeaderFlags) Set(v string) error {
+	if i := strings.Index(v, "="); i <= 0 {
+		return fmt.Errorf("expected header as key=value, got %q", v)
+	}
+	*h = append(*h, v)
+	return nil
+}
+
+// Map returns headers by key, the last value of repeated key wins
+func (h HeaderFlags) Map() map[string]string {
+	headers := make(map[string]string, len(h))
+	for _, pair := range h {
+		i := strings.Index(pair, "=")
+		headers[pair[:i]] = pair[i+1:]
+	}
+	return headers
+}
@@ -0,0 +1,160 @@
+package console
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"kafka-tryout/src/codec"
+	"kafka-tryout/src/rate"
+	"kafka-tryout/src/spotify_generator"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// output formats of consume command
+const (
+	// OutputText prints message per line with decoded rates and tracks in human readable form
+	OutputText = "text"
+	// OutputJSON prints JSON line per message with decoded value
+	OutputJSON = "json"
+)
+
+// Filter selects messages with Key and all Headers, empty filter matches every message
+type Filter struct {
+	Key     string
+	Headers map[string]string
+}
+
+func (f Filter) Match(m kafka.Message) bool {
+	if f.Key != "" && string(m.Key) != f.Key {
+		return false
+	}
+	for k, v := range f.Headers {
+		if value, ok := codec.Header(m, k); !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
+
+// printedMessage is the JSON line of consumed message, Value is decoded value, JSON value or text
+type printedMessage struct {
+	Topic     string            `json:"topic"`
+	Partition int               `json:"partition"`
+	Offset    int64             `json:"offset"`
+	Time      time.Time         `json:"time"`
+	Key       string            `json:"key,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Schema    string            `json:"schema,omitempty"`
+	Value     interface{}       `json:"value"`
+}
+
+// Printer prints consumed messages, values are decoded by schema in their headers,
+// values without schema headers are decoded with fallback schema if it's given
+type Printer struct {
+	out      io.Writer
+	format   string
+	registry *codec.Registry
+	fallback *codec.Schema
+}
+
+func NewPrinter(out io.Writer, format string, fallback *codec.Schema) (*Printer, error) {
+	if format != OutputText && format != OutputJSON {
+		return nil, fmt.Errorf("unknown output %q, expected %s or %s", format, OutputText, OutputJSON)
+	}
+	return &Printer{out: out, format: format, registry: codec.Default, fallback: fallback}, nil
+}
+
+func (p *Printer) Print(m kafka.Message) error {
+	v, schema, decoded := p.decode(m)
+	if p.format == OutputJSON {
+		return p.printJSON(m, v, schema, decoded)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s[%d]@%d %s", m.Topic, m.Partition, m.Offset, m.Time.UTC().Format(time.RFC3339))
+	if len(m.Key) > 0 {
+		fmt.Fprintf(&b, " key=%s", m.Key)
+	}
+	for _, h := range m.Headers {
+		fmt.Fprintf(&b, " %s=%s", h.Key, h.Value)
+	}
+	b.WriteString(" | ")
+	if decoded {
+		b.WriteString(Pretty(string(m.Key), v))
+	} else {
+		b.WriteString(text(m.Value))
+	}
+	_, err := fmt.Fprintln(p.out, b.String())
+	return err
+}
+
+func (p *Printer) printJSON(m kafka.Message, v interface{}, schema codec.Schema, decoded bool) error {
+	pm := printedMessage{Topic: m.Topic, Partition: m.Partition, Offset: m.Offset, Time: m.Time, Key: string(m.Key)}
+	if len(m.Headers) > 0 {
+		pm.Headers = make(map[string]string, len(m.Headers))
+		for _, h := range m.Headers {
+			pm.Headers[h.Key] = string(h.Value)
+		}
+	}
+	switch {
+	case decoded:
+		pm.Value, pm.Schema = v, schema.String()
+	case json.Valid(m.Value):
+		pm.Value = json.RawMessage(m.Value)
+	default:
+		pm.Value = text(m.Value)
+	}
+	return json.NewEncoder(p.out).Encode(pm)
+}
+
+// decode returns decoded value, decoded is false if message has no known schema
+func (p *Printer) decode(m kafka.Message) (interface{}, codec.Schema, bool) {
+	var (
+		v      interface{}
+		schema codec.Schema
+		err    error
+	)
+	if p.fallback != nil {
+		v, schema, err = p.registry.DecodeOr(m, p.fallback.Name, p.fallback.Version)
+	} else {
+		v, schema, err = p.registry.Decode(m)
+	}
+	return v, schema, err == nil
+}
+
+// Pretty formats decoded rates and tracks in human readable form, other values as JSON,
+// key is the currency name of rates
+func Pretty(key string, v interface{}) string {
+	switch v := v.(type) {
+	case *rate.Rate:
+		if key == "" {
+			return fmt.Sprintf("%s rate %g on %s", v.Base, v.Rate, v.Date)
+		}
+		return fmt.Sprintf("1 %s = %g %s on %s", v.Base, v.Rate, key, v.Date)
+	case *spotify_generator.CurrentlyPlaying:
+		artists := make([]string, len(v.Artists))
+		for i, a := range v.Artists {
+			artists[i] = a.Name
+		}
+		duration := time.Duration(v.DurationMs) * time.Millisecond
+		return fmt.Sprintf("%s - %s (%s) played at %s", strings.Join(artists, ", "), v.TrackName,
+			duration.Round(time.Second), v.PlayedAt.UTC().Format(time.RFC3339))
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%+v", v)
+	}
+	return string(data)
+}
+
+// text returns value as text, or quoted if it isn't valid UTF-8
+func text(value []byte) string {
+	if utf8.Valid(value) {
+		return string(value)
+	}
+	return fmt.Sprintf("%q", value)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"kafka-tryout/src/backend"
+	"kafka-tryout/src/config"
+	"kafka-tryout/src/console"
+	"kafka-tryout/src/stream"
+	"kafka-tryout/src/utils"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+const usage = `usage: produce [flags]
+
+writes lines of stdin to -topic until EOF or interrupt, e.g.
+  echo 'USD={"Base":"EUR","Rate":1.18,"Date":"2020-09-04"}' | produce -format kv -header schema=rate -header schema-version=1
+
+flags:
+`
+
+const (
+	// batchSize is the maximum number of messages written at once
+	batchSize = 100
+	// idle is how long input has to be idle to write pending messages
+	idle = 10 * time.Millisecond
+	// maxLine is the longest accepted input line
+	maxLine = 1 << 20
+)
+
+func main() {
+	log := logrus.New()
+	logger := log.WithField("application", "Produce")
+
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	format := flag.String("format", console.FormatLines, "input format, lines, json or kv")
+	key := flag.String("key", "", "key of messages which don't set it")
+	partition := flag.Int("partition", -1, "partition of messages which don't set it, -1 hashes keys")
+	var headers console.HeaderFlags
+	flag.Var(&headers, "header", "header as key=value added to every message, can be repeated")
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		logger.WithError(err).Fatal("invalid configuration")
+	}
+
+	parser := console.Parser{Format: *format, Key: *key, Headers: headers.Map(), Partition: *partition}
+	if err := parser.Validate(); err != nil {
+		logger.WithError(err).Fatal("invalid format")
+	}
+
+	b, err := backend.FromConfig(cfg)
+	if err != nil {
+		logger.WithError(err).Fatal("invalid backend")
+	}
+	// batches are written by produce itself, so messages don't wait for the writer batch timeout
+	b.BatchTimeout = idle
+	topic := cfg.Topics.Currencies
+	w, err := b.Writer(topic, console.ExplicitBalancer{Fallback: &kafka.Hash{}})
+	if err != nil {
+		logger.WithError(err).Fatal("failed to create writer")
+	}
+
+	ctx, cancel := utils.SignalContext()
+	defer cancel()
+	// pending messages are still written after interrupt
+	writeCtx, cancelWrite := utils.WithShutdownDeadline(ctx, cfg.ShutdownTimeout)
+	defer cancelWrite()
+	in := bufio.NewScanner(os.Stdin)
+	in.Buffer(make([]byte, 64*1024), maxLine)
+	n, err := produce(ctx, writeCtx, logger, in, parser, w)
+	if closeErr := utils.CloseWithin(w, cfg.ShutdownTimeout); closeErr != nil {
+		logger.WithError(closeErr).Error("failed to close writer")
+	}
+	if err != nil {
+		logger.WithError(err).Fatalf("failed to produce to %s", topic)
+	}
+	logger.WithField("topic", topic).Infof("%d messages produced", n)
+}
+
+// produce writes messages parsed from lines in batches, the batch is written once it's full or input is idle,
+// invalid lines are logged and skipped, reading stops at the end of input or when ctx is done
+func produce(ctx, writeCtx context.Context, log logrus.FieldLogger, in *bufio.Scanner, p console.Parser, w stream.MessageWriter) (int, error) {
+	lines := make(chan string)
+	scanErr := make(chan error, 1)
+	go func() {
+		defer close(lines)
+		for in.Scan() {
+			select {
+			case lines <- in.Text():
+			case <-ctx.Done():
+				return
+			}
+		}
+		scanErr <- in.Err()
+	}()
+
+	var (
+		batch   []kafka.Message
+		written int
+	)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := w.WriteMessages(writeCtx, batch...); err != nil {
+			return err
+		}
+		written += len(batch)
+		batch = nil
+		return nil
+	}
+
+	ticker := time.NewTicker(idle)
+	defer ticker.Stop()
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				if err := flush(); err != nil {
+					return written, err
+				}
+				select {
+				case err := <-scanErr:
+					return written, err
+				default:
+					return written, nil
+				}
+			}
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			m, err := p.Parse(line)
+			if err != nil {
+				log.WithError(err).Warn("skipping invalid line")
+				continue
+			}
+			batch = append(batch, m)
+			if len(batch) >= batchSize {
+				if err := flush(); err != nil {
+					return written, err
+				}
+			}
+		case <-ticker.C:
+			if err := flush(); err != nil {
+				return written, err
+			}
+		case <-ctx.Done():
+			return written, flush()
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package console
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kafka-tryout/src/admin"
+	"kafka-tryout/src/backend"
+	"kafka-tryout/src/filelog"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Source reads messages of topic from the given position without consumer group, so nothing is committed
+type Source interface {
+	// FetchMessage blocks until message is available or ctx is done
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	Close() error
+}
+
+// OpenSource opens topic of backend at from, partition -1 reads all partitions
+func OpenSource(ctx context.Context, b backend.Config, topic string, partition int, from admin.ResetTarget) (Source, error) {
+	switch b.Kind {
+	case backend.File:
+		return openFileSource(b, topic, partition, from)
+	case backend.Stdout:
+		return nil, errors.New("stdout backend can't be read")
+	}
+	return openKafkaSource(ctx, b, topic, partition, from)
+}
+
+type fetched struct {
+	m   kafka.Message
+	err error
+}
+
+// kafkaSource reads partitions with a reader per partition, readers without group can seek to any offset
+type kafkaSource struct {
+	readers  []*kafka.Reader
+	messages chan fetched
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+func openKafkaSource(ctx context.Context, b backend.Config, topic string, partition int, from admin.ResetTarget) (*kafkaSource, error) {
+	if len(b.Brokers) == 0 {
+		return nil, errors.New("no brokers configured")
+	}
+	dialer := b.Security.Dialer()
+	partitions, err := dialer.LookupPartitions(ctx, "tcp", b.Brokers[0], topic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up partitions of %s, %w", topic, err)
+	}
+	if len(partitions) == 0 {
+		return nil, fmt.Errorf("topic %s doesn't exist", topic)
+	}
+
+	readCtx, cancel := context.WithCancel(context.Background())
+	s := &kafkaSource{messages: make(chan fetched), cancel: cancel}
+	for _, p := range partitions {
+		if partition >= 0 && p.ID != partition {
+			continue
+		}
+		r := kafka.NewReader(kafka.ReaderConfig{
+			Brokers:   b.Brokers,
+			Topic:     topic,
+			Partition: p.ID,
+			MinBytes:  1,
+			MaxBytes:  10e6, // 10MB
+			Dialer:    dialer,
+		})
+		s.readers = append(s.readers, r)
+		if err := seek(ctx, r, from); err != nil {
+			s.Close()
+			return nil, fmt.Errorf("failed to seek %s[%d], %w", topic, p.ID, err)
+		}
+	}
+	if len(s.readers) == 0 {
+		s.Close()
+		return nil, fmt.Errorf("topic %s has no partition %d", topic, partition)
+	}
+
+	for _, r := range s.readers {
+		s.wg.Add(1)
+		go s.read(readCtx, r)
+	}
+	return s, nil
+}
+
+func seek(ctx context.Context, r *kafka.Reader, from admin.ResetTarget) error {
+	switch from.Position {
+	case admin.ResetEarliest:
+		return r.SetOffset(kafka.FirstOffset)
+	case admin.ResetLatest:
+		return r.SetOffset(kafka.LastOffset)
+	case admin.ResetOffset:
+		return r.SetOffset(from.Offset)
+	case admin.ResetTimestamp:
+		return r.SetOffsetAt(ctx, from.Time)
+	}
+	return fmt.Errorf("unknown position %q", from.Position)
+}
+
+// read passes messages of partition to FetchMessage until reading fails or source is closed
+func (s *kafkaSource) read(ctx context.Context, r *kafka.Reader) {
+	defer s.wg.Done()
+	for {
+		m, err := r.FetchMessage(ctx)
+		select {
+		case s.messages <- fetched{m: m, err: err}:
+		case <-ctx.Done():
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *kafkaSource) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	select {
+	case f := <-s.messages:
+		return f.m, f.err
+	case <-ctx.Done():
+		return kafka.Message{}, ctx.Err()
+	}
+}
+
+func (s *kafkaSource) Close() error {
+	s.cancel()
+	s.wg.Wait()
+	var err error
+	for _, r := range s.readers {
+		if closeErr := r.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// fileSource reads file log from the first offset and skips messages before from, latest reads messages
+// written after the source is opened
+type fileSource struct {
+	*filelog.Reader
+	partition int
+	from      admin.ResetTarget
+}
+
+func openFileSource(b backend.Config, topic string, partition int, from admin.ResetTarget) (*fileSource, error) {
+	if from.Position == admin.ResetLatest {
+		from = admin.ResetTarget{Position: admin.ResetTimestamp, Time: time.Now()}
+	}
+	r, err := filelog.NewReader(b.Dir, topic, "", b.Partitions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file log reader, %w", err)
+	}
+	return &fileSource{Reader: r, partition: partition, from: from}, nil
+}
+
+func (s *fileSource) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	for {
+		m, err := s.Reader.FetchMessage(ctx)
+		if err != nil || !skip(m, s.partition, s.from) {
+			return m, err
+		}
+	}
+}
+
+// skip tells if message is out of partition or before from
+func skip(m kafka.Message, partition int, from admin.ResetTarget) bool {
+	if partition >= 0 && m.Partition != partition {
+		return true
+	}
+	switch from.Position {
+	case admin.ResetOffset:
+		return m.Offset < from.Offset
+	case admin.ResetTimestamp:
+		return m.Time.Before(from.Time)
+	}
+	return false
+}
@@ -0,0 +1,28 @@
+package consumer
+
+import "time"
+
+// Backoff is the delay between failed reads, it grows with every consecutive failure up to Max
+type Backoff struct {
+	Min    time.Duration
+	Max    time.Duration
+	Factor float64
+}
+
+// DefaultBackoff waits 100ms after the first failure, up to 30s when broker stays unavailable
+var DefaultBackoff = Backoff{Min: 100 * time.Millisecond, Max: 30 * time.Second, Factor: 2}
+
+// delay returns how long to wait after given number of consecutive failures
+func (b Backoff) delay(failures int) time.Duration {
+	if failures < 1 {
+		return 0
+	}
+	d := float64(b.Min)
+	for i := 1; i < failures && d < float64(b.Max); i++ {
+		d *= b.Factor
+	}
+	if d > float64(b.Max) {
+		return b.Max
+	}
+	return time.Duration(d)
+}
@@ -0,0 +1,16 @@
+package consumer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	b := Backoff{Min: 100 * time.Millisecond, Max: time.Second, Factor: 2}
+	expected := []time.Duration{0, 100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second}
+	for failures, d := range expected {
+		if got := b.delay(failures); got != d {
+			t.Errorf("%d failures: expected %s, got %s", failures, d, got)
+		}
+	}
+}
@@ -1,12 +1,20 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"kafka-tryout/src/backend"
+	"kafka-tryout/src/codec"
+	"kafka-tryout/src/codec/schemaregistry"
+	"kafka-tryout/src/config"
 	"kafka-tryout/src/consumer"
-	"kafka-tryout/src/kafka_server"
+	"kafka-tryout/src/stream"
 	"kafka-tryout/src/utils"
+	"net/http"
+	"os"
 	"sync"
-	"time"
 
+	"github.com/elastic/go-elasticsearch/v8"
 	"github.com/segmentio/kafka-go"
 	"github.com/sirupsen/logrus"
 )
@@ -16,23 +24,148 @@ func main() {
 	log.SetLevel(logrus.DebugLevel)
 	logger := log.WithField("application", "Consumer")
 	wg := &sync.WaitGroup{}
-	finish := make(chan struct{})
-
-	r := kafka.NewReader(kafka.ReaderConfig{
-		Brokers: []string{kafka_server.Address},
-		Topic:   utils.EnvOrDefault("TOPIC", "currencies"),
-		// groupID reads from all partitions of given topic
-		GroupID: utils.EnvOrDefault("GROUP_ID", "consumer-group"),
-		//Logger:      log,
-		//ErrorLogger: log,
-		MinBytes:    1,    // 10KB
-		MaxBytes:    10e6, // 10MB
-		StartOffset: kafka.FirstOffset,
-	})
 
-	cli := consumer.NewConsumer(logger, r, time.Second, finish, wg, 5, consumer.ConsumeCurrenciesFn)
-	cli.Run()
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		logger.WithError(err).Fatal("invalid configuration")
+	}
+	ctx, cancel := utils.SignalContext()
+	defer cancel()
+
+	// with schema registry configured, messages in Confluent wire format are decoded too
+	registry, err := schemaregistry.NewClient(http.DefaultClient, cfg.SchemaRegistry.URL, cfg.SchemaRegistry.File)
+	if err != nil {
+		logger.WithError(err).Fatal("failed to create schema registry client")
+	}
+	if registry != nil {
+		if err := schemaregistry.RegisterDefaults(ctx, registry, codec.Default, cfg.Topics.Currencies, cfg.Topics.CurrentlyPlaying); err != nil {
+			logger.WithError(err).Fatal("failed to register schemas")
+		}
+	}
+
+	// backend kind "file" reads local file log instead of Kafka
+	logBackend, err := backend.FromConfig(cfg)
+	if err != nil {
+		logger.WithError(err).Fatal("failed to configure backend")
+	}
+	newReader := func(topic string) stream.MessageReader {
+		r, err := logBackend.Reader(topic, cfg.Consumer.GroupID)
+		if err != nil {
+			logger.WithError(err).Fatal("failed to create reader")
+		}
+		return r
+	}
+
+	// failed messages go through retry topics to dead letter topic
+	policy := consumer.DefaultRetryPolicy(cfg.Topics.Currencies)
+	policy.MaxRetries = cfg.Consumer.RetryAttempts
+	// offsets are committed only after messages are handled, e.g. "message", "batch:100" or "interval:5s"
+	commit, err := consumer.ParseCommitStrategy(cfg.Consumer.CommitStrategy)
+	if err != nil {
+		logger.WithError(err).Fatal("invalid commit strategy")
+	}
+	// messages of the same currency are handled in order by the same worker
+	by, err := consumer.ParseDispatchMode(cfg.Consumer.DispatchBy)
+	if err != nil {
+		logger.WithError(err).Fatal("invalid dispatch mode")
+	}
+	// replayed messages, e.g. backfilled rates, are skipped, dedup file keeps handled ids between restarts
+	dedup := consumer.NewMemoryDedupStore(cfg.Consumer.DedupSize)
+	if path := cfg.Consumer.DedupFile; path != "" {
+		if dedup, err = consumer.NewFileDedupStore(path, cfg.Consumer.DedupSize); err != nil {
+			logger.WithError(err).Fatal("failed to create dedup store")
+		}
+	}
+	// keep messages of the same key on the same partition, as in original topic
+	pub := consumer.NewWritersPublisher(logBackend.WriterFunc(&kafka.Hash{}))
+	handle := consumer.HandleCurrencies(consumer.ConsumeCurrency)
+	middlewares := []consumer.Middleware{consumer.Retry(policy, pub), consumer.Dedup(dedup), consumer.Recover(), consumer.Logging()}
+
+	// with elasticsearch configured, rates and currently playing tracks are indexed into daily indices
+	var (
+		sink consumer.ElasticSink
+		ids  consumer.IDMode
+	)
+	if len(cfg.Elastic.Addresses) > 0 {
+		if ids, err = consumer.ParseIDMode(cfg.Elastic.ID); err != nil {
+			logger.WithError(err).Fatal("invalid elasticsearch id mode")
+		}
+		sink = newElasticSink(ctx, logger, cfg)
+		handle = consumer.HandleCurrencies(consumer.IndexCurrencies(sink, cfg.Topics.Currencies, ids))
+		// bulk is sent as soon as every handled message waits for it, so the innermost handler is tracked
+		middlewares = append(middlewares, sink.Track())
+	}
+
+	clis := []consumer.Consumer{
+		consumer.NewConsumer(logger, newReader(cfg.Topics.Currencies),
+			consumer.Dispatch{Workers: cfg.Consumer.Workers, By: by, QueueSize: consumer.DefaultQueueSize}, consumer.DefaultBackoff, commit,
+			cfg.ShutdownTimeout, handle, middlewares...),
+	}
+	for _, tier := range policy.Tiers {
+		// retried messages wait for their delay, so single worker keeps them in order
+		clis = append(clis, consumer.NewConsumer(logger.WithField("retry", tier.Topic), newReader(tier.Topic),
+			consumer.Dispatch{Workers: 1}, consumer.DefaultBackoff, commit,
+			cfg.ShutdownTimeout, handle, append([]consumer.Middleware{consumer.WaitRetryDelay()}, middlewares...)...))
+	}
+	if sink != nil {
+		// tracks which failed to be indexed stop the consumer, so they're read again after restart
+		clis = append(clis, consumer.NewConsumer(logger.WithField("topic", cfg.Topics.CurrentlyPlaying), newReader(cfg.Topics.CurrentlyPlaying),
+			consumer.Dispatch{Workers: cfg.Consumer.Workers, By: by, QueueSize: consumer.DefaultQueueSize}, consumer.DefaultBackoff, commit,
+			cfg.ShutdownTimeout, consumer.HandleCurrentlyPlaying(consumer.IndexCurrentlyPlaying(sink, cfg.Topics.CurrentlyPlaying, ids)),
+			consumer.Recover(), consumer.Logging(), sink.Track()))
+	}
+	// failed message stops every consumer, so the process exits and the message is read again after restart
+	errs := make(chan error, len(clis))
+	for _, cli := range clis {
+		wg.Add(1)
+		go func(cli consumer.Consumer) {
+			defer wg.Done()
+			if err := cli.Run(ctx); err != nil {
+				errs <- err
+				cancel()
+			}
+		}(cli)
+	}
 
 	wg.Wait()
-	logger.Info("closing")
+	close(errs)
+	// retried messages are written by now, so closing only flushes writers
+	if err := utils.CloseWithin(pub, cfg.ShutdownTimeout); err != nil {
+		logger.WithError(err).Error("failed to close retry writers")
+	}
+	if err := dedup.Close(); err != nil {
+		logger.WithError(err).Error("failed to close dedup store")
+	}
+	if sink != nil {
+		if err := utils.CloseWithin(sink, cfg.ShutdownTimeout); err != nil {
+			logger.WithError(err).Error("failed to close elasticsearch sink")
+		}
+	}
+	if err := <-errs; err != nil {
+		logger.WithError(err).Fatal("consumer failed")
+	}
+	logger.WithFields(logBackend.MetricsFields()).Info("closing")
+}
+
+// newElasticSink creates sink of configured cluster and puts templates of daily indices
+func newElasticSink(ctx context.Context, log logrus.FieldLogger, cfg config.Config) consumer.ElasticSink {
+	es, err := elasticsearch.NewClient(elasticsearch.Config{
+		Addresses: cfg.Elastic.Addresses,
+		Username:  cfg.Elastic.Username,
+		Password:  cfg.Elastic.Password,
+	})
+	if err != nil {
+		log.WithError(err).Fatal("failed to create elasticsearch client")
+	}
+	sinkCfg := consumer.DefaultElasticConfig
+	sinkCfg.BulkSize, sinkCfg.FlushInterval, sinkCfg.MaxRetries = cfg.Elastic.BulkSize, cfg.Elastic.FlushInterval, cfg.Elastic.MaxRetries
+	sink := consumer.NewElasticSink(log.WithField("sink", "elasticsearch"), es, sinkCfg)
+	if cfg.Elastic.Templates {
+		err := sink.PutTemplates(ctx,
+			consumer.CurrencyTemplate(cfg.Topics.Currencies), consumer.CurrentlyPlayingTemplate(cfg.Topics.CurrentlyPlaying))
+		if err != nil {
+			log.WithError(err).Fatal("failed to put index templates")
+		}
+	}
+	return sink
 }
@@ -0,0 +1,192 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// CommitStrategy tells when offsets of handled messages are committed,
+// commit happens when BatchSize messages became committable or Interval passed since the last one
+type CommitStrategy struct {
+	BatchSize int
+	Interval  time.Duration
+}
+
+// CommitPerMessage commits offset of every handled message
+func CommitPerMessage() CommitStrategy {
+	return CommitStrategy{BatchSize: 1}
+}
+
+// CommitBatch commits offsets once n messages are handled
+func CommitBatch(n int) CommitStrategy {
+	return CommitStrategy{BatchSize: n}
+}
+
+// CommitInterval commits offsets of handled messages every d
+func CommitInterval(d time.Duration) CommitStrategy {
+	return CommitStrategy{Interval: d}
+}
+
+// ParseCommitStrategy parses strategy in format "message", "batch:<n>" or "interval:<duration>"
+func ParseCommitStrategy(s string) (CommitStrategy, error) {
+	kind, arg := s, ""
+	if idx := strings.Index(s, ":"); idx >= 0 {
+		kind, arg = s[:idx], s[idx+1:]
+	}
+	switch strings.ToLower(strings.TrimSpace(kind)) {
+	case "message":
+		return CommitPerMessage(), nil
+	case "batch":
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 {
+			return CommitStrategy{}, fmt.Errorf("invalid commit batch size %q", arg)
+		}
+		return CommitBatch(n), nil
+	case "interval":
+		d, err := time.ParseDuration(arg)
+		if err != nil || d <= 0 {
+			return CommitStrategy{}, fmt.Errorf("invalid commit interval %q", arg)
+		}
+		return CommitInterval(d), nil
+	}
+	return CommitStrategy{}, fmt.Errorf("unknown commit strategy %q", s)
+}
+
+// commitFn commits offsets of given messages, e.g. kafka.Reader.CommitMessages
+type commitFn func(ctx context.Context, msgs ...kafka.Message) error
+
+// committer commits offsets according to strategy, only offsets below which
+// every fetched message of the partition was handled are committed, so crash never skips a message
+type committer struct {
+	commit   commitFn
+	strategy CommitStrategy
+
+	// commitMu serializes commits, so older offsets never overwrite newer ones
+	commitMu sync.Mutex
+
+	mu         sync.Mutex
+	partitions map[partitionKey]*partitionOffsets
+	// committable holds the highest committable message of each partition
+	committable  map[partitionKey]kafka.Message
+	handledCount int
+	lastCommit   time.Time
+}
+
+type partitionKey struct {
+	topic     string
+	partition int
+}
+
+// partitionOffsets keeps fetched but not yet committable messages of a partition, ordered by offset
+type partitionOffsets struct {
+	pending []int64
+	done    map[int64]kafka.Message
+}
+
+func newCommitter(commit commitFn, strategy CommitStrategy) *committer {
+	return &committer{
+		commit:      commit,
+		strategy:    strategy,
+		partitions:  make(map[partitionKey]*partitionOffsets),
+		committable: make(map[partitionKey]kafka.Message),
+		lastCommit:  time.Now(),
+	}
+}
+
+// fetched registers message before it's handled, messages of a partition have to be registered in fetch order
+func (c *committer) fetched(m kafka.Message) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := partitionKey{m.Topic, m.Partition}
+	p, ok := c.partitions[key]
+	if !ok {
+		p = &partitionOffsets{done: make(map[int64]kafka.Message)}
+		c.partitions[key] = p
+	}
+	// partition may be reassigned and read again from the last commit
+	i := sort.Search(len(p.pending), func(i int) bool { return p.pending[i] >= m.Offset })
+	if i < len(p.pending) && p.pending[i] == m.Offset {
+		return
+	}
+	p.pending = append(p.pending, 0)
+	copy(p.pending[i+1:], p.pending[i:])
+	p.pending[i] = m.Offset
+}
+
+// handled marks message as handled and commits offsets if strategy says so
+func (c *committer) handled(ctx context.Context, m kafka.Message) error {
+	c.mu.Lock()
+	key := partitionKey{m.Topic, m.Partition}
+	p, ok := c.partitions[key]
+	if !ok {
+		c.mu.Unlock()
+		return fmt.Errorf("message %s[%d]@%d was not fetched", m.Topic, m.Partition, m.Offset)
+	}
+	p.done[m.Offset] = m
+	for len(p.pending) > 0 {
+		done, ok := p.done[p.pending[0]]
+		if !ok {
+			break
+		}
+		delete(p.done, p.pending[0])
+		p.pending = p.pending[1:]
+		c.committable[key] = done
+	}
+	c.handledCount++
+	due := c.due()
+	c.mu.Unlock()
+
+	if !due {
+		return nil
+	}
+	return c.flush(ctx)
+}
+
+// due tells whether strategy requires commit, it's called with mu locked
+func (c *committer) due() bool {
+	if c.strategy.BatchSize > 0 && c.handledCount >= c.strategy.BatchSize {
+		return true
+	}
+	return c.strategy.Interval > 0 && time.Since(c.lastCommit) >= c.strategy.Interval
+}
+
+// flush commits all committable offsets
+func (c *committer) flush(ctx context.Context) error {
+	c.commitMu.Lock()
+	defer c.commitMu.Unlock()
+
+	c.mu.Lock()
+	msgs := make([]kafka.Message, 0, len(c.committable))
+	for key, m := range c.committable {
+		msgs = append(msgs, m)
+		delete(c.committable, key)
+	}
+	c.handledCount = 0
+	c.lastCommit = time.Now()
+	c.mu.Unlock()
+
+	if len(msgs) == 0 {
+		return nil
+	}
+	if err := c.commit(ctx, msgs...); err != nil {
+		// offsets are committed again with the next flush unless newer ones became committable
+		c.mu.Lock()
+		for _, m := range msgs {
+			key := partitionKey{m.Topic, m.Partition}
+			if newer, ok := c.committable[key]; !ok || newer.Offset < m.Offset {
+				c.committable[key] = m
+			}
+		}
+		c.mu.Unlock()
+		return fmt.Errorf("failed to commit offsets, %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package consumer
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestParseCommitStrategy(t *testing.T) {
+	tests := map[string]CommitStrategy{
+		"message":     CommitPerMessage(),
+		"batch:100":   CommitBatch(100),
+		"interval:5s": CommitInterval(5 * time.Second),
+	}
+	for s, expected := range tests {
+		got, err := ParseCommitStrategy(s)
+		if err != nil || got != expected {
+			t.Errorf("%s: expected %+v, got %+v (%v)", s, expected, got, err)
+		}
+	}
+	for _, s := range []string{"", "batch:0", "interval:soon", "never"} {
+		if _, err := ParseCommitStrategy(s); err == nil {
+			t.Errorf("%q should be rejected", s)
+		}
+	}
+}
+
+func TestCommitterContiguousOffsets(t *testing.T) {
+	var commits [][]kafka.Message
+	c := newCommitter(func(_ context.Context, msgs ...kafka.Message) error {
+		commits = append(commits, msgs)
+		return nil
+	}, CommitPerMessage())
+	ctx := context.Background()
+
+	msg := func(partition int, offset int64) kafka.Message {
+		return kafka.Message{Topic: "currencies", Partition: partition, Offset: offset}
+	}
+	for _, m := range []kafka.Message{msg(0, 10), msg(0, 11), msg(0, 12), msg(1, 5)} {
+		c.fetched(m)
+	}
+
+	// 11 and 12 are handled before 10, so nothing of partition 0 may be committed yet
+	for _, m := range []kafka.Message{msg(0, 12), msg(0, 11)} {
+		if err := c.handled(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(commits) != 0 {
+		t.Fatalf("offsets committed over unhandled message: %v", commits)
+	}
+
+	if err := c.handled(ctx, msg(0, 10)); err != nil {
+		t.Fatal(err)
+	}
+	if len(commits) != 1 || len(commits[0]) != 1 || commits[0][0].Offset != 12 {
+		t.Fatalf("expected commit of offset 12, got %v", commits)
+	}
+
+	if err := c.handled(ctx, msg(1, 5)); err != nil {
+		t.Fatal(err)
+	}
+	if len(commits) != 2 || commits[1][0].Partition != 1 || commits[1][0].Offset != 5 {
+		t.Fatalf("expected commit of partition 1, got %v", commits)
+	}
+}
+
+func TestCommitterBatch(t *testing.T) {
+	var committed []kafka.Message
+	c := newCommitter(func(_ context.Context, msgs ...kafka.Message) error {
+		committed = append(committed, msgs...)
+		return nil
+	}, CommitBatch(3))
+	ctx := context.Background()
+
+	for offset := int64(0); offset < 5; offset++ {
+		m := kafka.Message{Topic: "currencies", Offset: offset}
+		c.fetched(m)
+		if err := c.handled(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(committed) != 1 || committed[0].Offset != 2 {
+		t.Fatalf("expected single commit of offset 2, got %v", committed)
+	}
+	if err := c.flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(committed) != 2 || committed[1].Offset != 4 {
+		t.Fatalf("flush should commit offset 4, got %v", committed)
+	}
+}
+
+func TestCommitterOrderedCommits(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		last int64 = -1
+	)
+	c := newCommitter(func(_ context.Context, msgs ...kafka.Message) error {
+		// slow commit lets concurrent flushes overtake each other if they weren't serialized
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		for _, m := range msgs {
+			if m.Offset < last {
+				t.Errorf("offset %d committed after %d", m.Offset, last)
+			}
+			last = m.Offset
+		}
+		return nil
+	}, CommitPerMessage())
+
+	msgs := make([]kafka.Message, 50)
+	for i := range msgs {
+		msgs[i] = kafka.Message{Topic: "currencies", Offset: int64(i)}
+		c.fetched(msgs[i])
+	}
+	var wg sync.WaitGroup
+	for _, m := range msgs {
+		wg.Add(1)
+		go func(m kafka.Message) {
+			defer wg.Done()
+			if err := c.handled(context.Background(), m); err != nil {
+				t.Error(err)
+			}
+		}(m)
+	}
+	wg.Wait()
+	if last != 49 {
+		t.Fatalf("expected offset 49 committed last, got %d", last)
+	}
+}
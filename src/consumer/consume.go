@@ -1,24 +1,16 @@
 package consumer
 
 import (
-	"encoding/json"
-	"fmt"
+	"context"
 	"kafka-tryout/src/rate"
 
-	"github.com/segmentio/kafka-go"
 	"github.com/sirupsen/logrus"
 )
 
-type consumeFn func(kafka.Message, logrus.FieldLogger) error
-
-func ConsumeCurrenciesFn(m kafka.Message, log logrus.FieldLogger) error {
-	curr := rate.SingleCurrency{
-		Name: string(m.Key),
-	}
-	if err := json.Unmarshal(m.Value, &curr.Rate); err != nil {
-		return fmt.Errorf("failed to unmarshal rate, %w", err)
-	}
-	log.Debugf("%+v from goroutine: %s", curr, m.Headers[0].Value)
+// ConsumeCurrency handles decoded currency, it's meant to be wrapped with HandleCurrencies
+func ConsumeCurrency(_ context.Context, c rate.SingleCurrency, meta Metadata, log logrus.FieldLogger) error {
+	goroutine, _ := meta.Header("goroutine")
+	log.Debugf("%+v (%s) from goroutine: %s", c, meta.Schema, goroutine)
 	// handle message(currency) here
 	return nil
 }
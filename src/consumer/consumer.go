@@ -2,6 +2,10 @@ package consumer
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"kafka-tryout/src/stream"
+	"kafka-tryout/src/utils"
 	"sync"
 	"time"
 
@@ -10,57 +14,157 @@ import (
 )
 
 type Consumer interface {
-	Run()
+	// Run consumes messages until ctx is done or a message fails, then it handles already read messages,
+	// commits their offsets and closes the reader, failed message is returned as error, its offset
+	// is never committed, so it's read again after restart
+	Run(ctx context.Context) error
 }
 
 type handler struct {
-	r      *kafka.Reader
-	log    logrus.FieldLogger
-	sleep  time.Duration
-	finish chan struct{}
-	wg     *sync.WaitGroup
+	r   stream.MessageReader
+	log logrus.FieldLogger
 
-	goroutines int
+	dispatch        Dispatch
+	backoff         Backoff
+	shutdownTimeout time.Duration
 
-	consumeMsgFn consumeFn
+	handle  Handler
+	commits *committer
+
+	// stop ends fetching once a message fails, failure is its error
+	stop    context.CancelFunc
+	mu      sync.Mutex
+	failure error
 }
 
-func NewConsumer(log logrus.FieldLogger, r *kafka.Reader, sleep time.Duration,
-	finish chan struct{}, wg *sync.WaitGroup, goroutinesCount int, fn consumeFn) Consumer {
+// NewConsumer creates Consumer handling messages with h wrapped with given middlewares,
+// messages are read once and dispatched to workers, offsets are committed with given strategy once messages are handled,
+// reading is retried with backoff when broker returns errors
+func NewConsumer(log logrus.FieldLogger, r stream.MessageReader, dispatch Dispatch, backoff Backoff, commit CommitStrategy,
+	shutdownTimeout time.Duration, h Handler, middlewares ...Middleware) Consumer {
 	return &handler{
-		r:            r,
-		log:          log,
-		sleep:        sleep,
-		finish:       finish,
-		wg:           wg,
-		goroutines:   goroutinesCount,
-		consumeMsgFn: fn,
+		r:               r,
+		log:             log,
+		dispatch:        dispatch,
+		backoff:         backoff,
+		shutdownTimeout: shutdownTimeout,
+		handle:          Chain(h, middlewares...),
+		commits:         newCommitter(r.CommitMessages, commit),
+	}
+}
+
+func (h *handler) Run(ctx context.Context) error {
+	ctx, h.stop = context.WithCancel(ctx)
+	defer h.stop()
+	// handlers and commits get shutdown timeout to finish after ctx is done,
+	// handlers waiting for something, e.g. retry delay, give up as soon as ctx is done
+	drain, cancel := utils.WithShutdownDeadline(ctx, h.shutdownTimeout)
+	defer cancel()
+	handleCtx := withShutdown(drain, ctx.Done())
+
+	if h.commits.strategy.Interval > 0 {
+		go h.commitPeriodically(ctx)
+	}
+
+	disp := newDispatcher(h.dispatch, func(worker int, m kafka.Message) {
+		h.handleMessage(handleCtx, worker, m)
+	})
+	h.fetch(ctx, disp)
+
+	h.log.Info("graceful shutdown")
+	disp.close()
+	if err := h.commits.flush(drain); err != nil {
+		h.log.WithError(err).Error("failed to commit offsets")
+	}
+	if err := h.r.Close(); err != nil {
+		h.log.WithError(err).Error("failed to close reader")
+	}
+	return h.failed()
+}
+
+// fetch reads messages and dispatches them to workers until ctx is done
+func (h *handler) fetch(ctx context.Context, disp *dispatcher) {
+	failures := 0
+	for {
+		m, err := h.r.FetchMessage(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			failures++
+			delay := h.backoff.delay(failures)
+			h.log.WithError(err).WithField("retry_in", delay).Error("failed to read message")
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+			continue
+		}
+		failures = 0
+
+		// messages are registered in fetch order, before any worker could handle them
+		h.commits.fetched(m)
+		// blocks reading when worker can't keep up
+		select {
+		case disp.queue(m) <- m:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (h *handler) handleMessage(ctx context.Context, worker int, m kafka.Message) {
+	if ctx.Err() != nil || h.failed() != nil {
+		// shutdown timeout passed or a message failed, queued messages are read again after restart
+		return
+	}
+	log := h.log.WithField("worker", worker)
+	// consume message
+	if err := h.handle(ctx, m, log); err != nil {
+		// offset is not committed, so message is read again after restart
+		if errors.Is(err, ErrShutdown) || ctx.Err() != nil {
+			log.WithField("offset", m.Offset).Info("message left for restart")
+			return
+		}
+		log.WithError(err).Error("failed to consume message")
+		// committing later offsets of the partition would skip failed message, so consumer stops
+		h.fail(fmt.Errorf("failed to consume %s[%d]@%d, %w", m.Topic, m.Partition, m.Offset, err))
+		return
 	}
+	if err := h.commits.handled(ctx, m); err != nil {
+		log.WithError(err).Error("failed to commit offsets")
+	}
+}
+
+// fail records the first failed message and stops fetching
+func (h *handler) fail(err error) {
+	h.mu.Lock()
+	if h.failure == nil {
+		h.failure = err
+	}
+	h.mu.Unlock()
+	h.stop()
+}
+
+func (h *handler) failed() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.failure
 }
 
-func (h *handler) Run() {
-	for i := 0; i < h.goroutines; i++ {
-		h.wg.Add(1)
-		log := h.log.WithField("goroutine", i)
-		go func() {
-			for {
-				select {
-				case <-h.finish:
-					h.r.Close()
-					h.wg.Done()
-				case <-time.After(h.sleep):
-					log.Infof("reading message")
-					m, err := h.r.ReadMessage(context.Background())
-					if err != nil {
-						log.WithError(err).Error("failed to read message")
-					} else {
-						// consume message
-						if err := h.consumeMsgFn(m, log); err != nil {
-							log.WithError(err).Error("failed to consume message")
-						}
-					}
-				}
+// commitPeriodically commits offsets every interval, even if no message was handled since the last commit
+func (h *handler) commitPeriodically(ctx context.Context) {
+	ticker := time.NewTicker(h.commits.strategy.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := h.commits.flush(ctx); err != nil {
+				h.log.WithError(err).Error("failed to commit offsets")
 			}
-		}()
+		}
 	}
 }
@@ -0,0 +1,79 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"kafka-tryout/src/codec"
+	"kafka-tryout/src/rate"
+	"kafka-tryout/src/spotify_generator"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+type (
+	// DecodedHandler receives value decoded with codec registry, e.g. *rate.Rate
+	DecodedHandler func(ctx context.Context, v interface{}, meta Metadata, log logrus.FieldLogger) error
+	// CurrencyHandler receives decoded currency rate, currency name is the message key
+	CurrencyHandler func(ctx context.Context, c rate.SingleCurrency, meta Metadata, log logrus.FieldLogger) error
+	// CurrentlyPlayingHandler receives decoded currently playing track
+	CurrentlyPlayingHandler func(ctx context.Context, c spotify_generator.CurrentlyPlaying, meta Metadata, log logrus.FieldLogger) error
+	// PropositionHandler receives decoded proposition
+	PropositionHandler func(ctx context.Context, p spotify_generator.Proposition, meta Metadata, log logrus.FieldLogger) error
+)
+
+// Decode returns Handler decoding messages with the schema stamped in their headers,
+// messages without schema headers are decoded with fallback schema if it's given
+func Decode(reg *codec.Registry, fallback *codec.Schema, h DecodedHandler) Handler {
+	return func(ctx context.Context, m kafka.Message, log logrus.FieldLogger) error {
+		var (
+			v      interface{}
+			schema codec.Schema
+			err    error
+		)
+		if fallback != nil {
+			v, schema, err = reg.DecodeOr(m, fallback.Name, fallback.Version)
+		} else {
+			v, schema, err = reg.Decode(m)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to decode message, %w", err)
+		}
+		return h(ctx, v, metadataOf(m, schema), log)
+	}
+}
+
+// HandleCurrencies returns Handler decoding rates, messages produced before schemas
+// were introduced are decoded as JSON encoded rates
+func HandleCurrencies(h CurrencyHandler) Handler {
+	fallback := &codec.Schema{Name: codec.SchemaRate, Version: 1}
+	return Decode(codec.Default, fallback, func(ctx context.Context, v interface{}, meta Metadata, log logrus.FieldLogger) error {
+		r, ok := v.(*rate.Rate)
+		if !ok {
+			return fmt.Errorf("unexpected schema %s", meta.Schema)
+		}
+		return h(ctx, rate.SingleCurrency{Name: meta.Key, Rate: *r}, meta, log)
+	})
+}
+
+// HandleCurrentlyPlaying returns Handler decoding currently playing tracks
+func HandleCurrentlyPlaying(h CurrentlyPlayingHandler) Handler {
+	return Decode(codec.Default, nil, func(ctx context.Context, v interface{}, meta Metadata, log logrus.FieldLogger) error {
+		c, ok := v.(*spotify_generator.CurrentlyPlaying)
+		if !ok {
+			return fmt.Errorf("unexpected schema %s", meta.Schema)
+		}
+		return h(ctx, *c, meta, log)
+	})
+}
+
+// HandlePropositions returns Handler decoding propositions
+func HandlePropositions(h PropositionHandler) Handler {
+	return Decode(codec.Default, nil, func(ctx context.Context, v interface{}, meta Metadata, log logrus.FieldLogger) error {
+		p, ok := v.(*spotify_generator.Proposition)
+		if !ok {
+			return fmt.Errorf("unexpected schema %s", meta.Schema)
+		}
+		return h(ctx, *p, meta, log)
+	})
+}
@@ -0,0 +1,275 @@
+package consumer
+
+import (
+	"bufio"
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"kafka-tryout/src/codec"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultDedupSize is the number of message ids remembered by deduplication store
+const DefaultDedupSize = 100000
+
+// DedupStore remembers ids of handled messages, ids are claimed before messages are handled,
+// so concurrent duplicates aren't handled twice
+type DedupStore interface {
+	// Claim reserves id for message being handled, it returns false if id is already handled or reserved
+	Claim(id string) (bool, error)
+	// Mark remembers claimed id as handled
+	Mark(id string) error
+	// Release drops claim of message which failed, so its redelivery is handled
+	Release(id string)
+	Close() error
+}
+
+// lru keeps the most recently marked ids
+type lru struct {
+	size  int
+	order *list.List
+	ids   map[string]*list.Element
+	// claimed are ids of messages being handled
+	claimed map[string]struct{}
+}
+
+func newLRU(size int) *lru {
+	if size < 1 {
+		size = DefaultDedupSize
+	}
+	return &lru{size: size, order: list.New(), ids: make(map[string]*list.Element), claimed: make(map[string]struct{})}
+}
+
+func (l *lru) claim(id string) bool {
+	if _, ok := l.ids[id]; ok {
+		return false
+	}
+	if _, ok := l.claimed[id]; ok {
+		return false
+	}
+	l.claimed[id] = struct{}{}
+	return true
+}
+
+func (l *lru) release(id string) {
+	delete(l.claimed, id)
+}
+
+func (l *lru) mark(id string) {
+	delete(l.claimed, id)
+	if e, ok := l.ids[id]; ok {
+		l.order.MoveToFront(e)
+		return
+	}
+	l.ids[id] = l.order.PushFront(id)
+	if l.order.Len() > l.size {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.ids, oldest.Value.(string))
+	}
+}
+
+type memoryDedupStore struct {
+	mu  sync.Mutex
+	ids *lru
+}
+
+// NewMemoryDedupStore returns DedupStore remembering up to size the most recent ids
+func NewMemoryDedupStore(size int) DedupStore {
+	return &memoryDedupStore{ids: newLRU(size)}
+}
+
+func (s *memoryDedupStore) Claim(id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ids.claim(id), nil
+}
+
+func (s *memoryDedupStore) Mark(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ids.mark(id)
+	return nil
+}
+
+func (s *memoryDedupStore) Release(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ids.release(id)
+}
+
+func (s *memoryDedupStore) Close() error {
+	return nil
+}
+
+type fileDedupStore struct {
+	mu   sync.Mutex
+	ids  *lru
+	path string
+	file *os.File
+	// lines is the number of ids in the file, it's compacted when it grows twice the store size
+	lines int
+}
+
+// NewFileDedupStore returns DedupStore remembering up to size the most recent ids,
+// ids are appended to the file at path, so they survive restarts
+func NewFileDedupStore(path string, size int) (DedupStore, error) {
+	s := &fileDedupStore{ids: newLRU(size), path: path}
+
+	f, err := os.Open(path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, fmt.Errorf("failed to open dedup file, %w", err)
+	default:
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if id := strings.TrimSpace(scanner.Text()); id != "" {
+				s.ids.mark(id)
+				s.lines++
+			}
+		}
+		f.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read dedup file, %w", err)
+		}
+	}
+
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *fileDedupStore) Claim(id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ids.claim(id), nil
+}
+
+// Mark appends id to the file and syncs it, so handled message is never handled again after crash
+func (s *fileDedupStore) Mark(id string) error {
+	if strings.ContainsAny(id, "\r\n") {
+		return fmt.Errorf("invalid message id %q", id)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return errors.New("dedup store is closed")
+	}
+	if _, err := s.file.WriteString(id + "\n"); err != nil {
+		return fmt.Errorf("failed to write dedup file, %w", err)
+	}
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync dedup file, %w", err)
+	}
+	s.ids.mark(id)
+	s.lines++
+	if s.lines > 2*s.ids.size {
+		return s.compact()
+	}
+	return nil
+}
+
+func (s *fileDedupStore) Release(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ids.release(id)
+}
+
+func (s *fileDedupStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	if err != nil {
+		return fmt.Errorf("failed to close dedup file, %w", err)
+	}
+	return nil
+}
+
+// compact rewrites file with remembered ids only, it's written to temporary file, synced
+// and renamed, so crash never leaves the store empty
+func (s *fileDedupStore) compact() error {
+	var b strings.Builder
+	for e := s.ids.order.Back(); e != nil; e = e.Prev() {
+		b.WriteString(e.Value.(string))
+		b.WriteByte('\n')
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), "."+filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create dedup file, %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(b.String()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write dedup file, %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync dedup file, %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write dedup file, %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to replace dedup file, %w", err)
+	}
+
+	if s.file != nil {
+		s.file.Close()
+	}
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open dedup file, %w", err)
+	}
+	s.file = f
+	s.lines = s.ids.order.Len()
+	return nil
+}
+
+// Dedup skips messages whose codec.HeaderMessageID was already handled, messages without id are always handled,
+// id is claimed before next handlers, so concurrent duplicates are skipped, and marked only when they succeed,
+// so it has to wrap handlers whose failure means message will be redelivered
+// (i.e. it goes after Retry, otherwise routed message would be marked and its retries skipped)
+func Dedup(store DedupStore) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, m kafka.Message, log logrus.FieldLogger) error {
+			id, ok := codec.Header(m, codec.HeaderMessageID)
+			if !ok || id == "" {
+				return next(ctx, m, log)
+			}
+			claimed, err := store.Claim(id)
+			if err != nil {
+				return fmt.Errorf("failed to check message %s, %w", id, err)
+			}
+			if !claimed {
+				log.WithField("id", id).Debug("duplicated message skipped")
+				return nil
+			}
+			if err := next(ctx, m, log); err != nil {
+				store.Release(id)
+				return err
+			}
+			if err := store.Mark(id); err != nil {
+				// message is handled, at worst it's handled again after redelivery
+				log.WithError(err).WithField("id", id).Error("failed to mark message as handled")
+			}
+			return nil
+		}
+	}
+}
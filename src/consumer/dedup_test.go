@@ -0,0 +1,138 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"kafka-tryout/src/codec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+func TestMemoryDedupStoreEvictsOldest(t *testing.T) {
+	s := NewMemoryDedupStore(2)
+	for _, id := range []string{"a", "b", "a", "c"} {
+		if err := s.Mark(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// "a" was marked again, so "b" is the least recently marked one
+	for id, expected := range map[string]bool{"a": false, "b": true, "c": false} {
+		if claimed, _ := s.Claim(id); claimed != expected {
+			t.Errorf("%s: expected claimed %v", id, expected)
+		}
+	}
+	// claimed id is reserved until it's released
+	if claimed, _ := s.Claim("b"); claimed {
+		t.Error("b is already claimed")
+	}
+	s.Release("b")
+	if claimed, _ := s.Claim("b"); !claimed {
+		t.Error("released b should be claimed again")
+	}
+}
+
+func TestFileDedupStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dedup")
+	s, err := NewFileDedupStore(path, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// enough ids to compact the file a few times
+	for i := 0; i < 20; i++ {
+		if err := s.Mark(strconv.Itoa(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Mark("20"); err == nil {
+		t.Fatal("expected error of closed store")
+	}
+
+	reopened, err := NewFileDedupStore(path, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	for i := 0; i < 20; i++ {
+		expected := i < 17
+		if claimed, _ := reopened.Claim(strconv.Itoa(i)); claimed != expected {
+			t.Errorf("%d: expected claimed %v after restart", i, expected)
+		}
+	}
+}
+
+func TestDedup(t *testing.T) {
+	handled := 0
+	fail := true
+	h := Chain(func(context.Context, kafka.Message, logrus.FieldLogger) error {
+		handled++
+		if fail {
+			return errors.New("temporary failure")
+		}
+		return nil
+	}, Dedup(NewMemoryDedupStore(10)))
+
+	m := kafka.Message{Headers: []kafka.Header{{Key: codec.HeaderMessageID, Value: []byte("EUR:USD:2020-09-04:1.18")}}}
+	if err := h(context.Background(), m, testLogger()); err == nil {
+		t.Fatal("expected error")
+	}
+	// failed message is not marked, so its redelivery is handled
+	fail = false
+	for i := 0; i < 3; i++ {
+		if err := h(context.Background(), m, testLogger()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if handled != 2 {
+		t.Fatalf("expected message handled twice, got %d", handled)
+	}
+
+	// messages without id are never skipped
+	for i := 0; i < 2; i++ {
+		if err := h(context.Background(), kafka.Message{}, testLogger()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if handled != 4 {
+		t.Fatalf("messages without id should be handled, got %d", handled)
+	}
+}
+
+func TestDedupConcurrentDuplicates(t *testing.T) {
+	var handled int32
+	release := make(chan struct{})
+	h := Chain(func(context.Context, kafka.Message, logrus.FieldLogger) error {
+		atomic.AddInt32(&handled, 1)
+		<-release
+		return nil
+	}, Dedup(NewMemoryDedupStore(10)))
+
+	// the same rate read from two partitions, e.g. replayed by backfill, is handled once
+	m := kafka.Message{Headers: []kafka.Header{{Key: codec.HeaderMessageID, Value: []byte("EUR:USD:2020-09-04:1.18")}}}
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := h(context.Background(), m, testLogger()); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if handled != 1 {
+		t.Fatalf("expected message handled once, got %d", handled)
+	}
+}
@@ -0,0 +1,96 @@
+package consumer
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// DispatchMode tells how messages are assigned to workers
+type DispatchMode string
+
+const (
+	// DispatchByKey keeps order of messages with the same key, messages without key are dispatched by partition
+	DispatchByKey DispatchMode = "key"
+	// DispatchByPartition keeps order of messages within partition
+	DispatchByPartition DispatchMode = "partition"
+)
+
+// DefaultQueueSize is the number of messages waiting for a worker before reading is blocked
+const DefaultQueueSize = 100
+
+// Dispatch configures workers handling messages read by consumer
+type Dispatch struct {
+	Workers   int
+	By        DispatchMode
+	QueueSize int
+}
+
+// ParseDispatchMode parses "key" or "partition"
+func ParseDispatchMode(s string) (DispatchMode, error) {
+	switch m := DispatchMode(strings.ToLower(strings.TrimSpace(s))); m {
+	case DispatchByKey, DispatchByPartition:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown dispatch mode %q", s)
+}
+
+// dispatcher fans messages out to workers, each worker has its own bounded queue,
+// so messages assigned to the same worker are handled in order and full queue blocks reading
+type dispatcher struct {
+	by     DispatchMode
+	queues []chan kafka.Message
+	wg     sync.WaitGroup
+}
+
+func newDispatcher(d Dispatch, handle func(worker int, m kafka.Message)) *dispatcher {
+	workers := d.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	size := d.QueueSize
+	if size < 1 {
+		size = DefaultQueueSize
+	}
+	by := d.By
+	if by == "" {
+		by = DispatchByKey
+	}
+
+	disp := &dispatcher{by: by, queues: make([]chan kafka.Message, workers)}
+	for i := range disp.queues {
+		queue := make(chan kafka.Message, size)
+		disp.queues[i] = queue
+		disp.wg.Add(1)
+		go func(worker int) {
+			defer disp.wg.Done()
+			for m := range queue {
+				handle(worker, m)
+			}
+		}(i)
+	}
+	return disp
+}
+
+// queue returns queue of the worker message is assigned to
+func (d *dispatcher) queue(m kafka.Message) chan<- kafka.Message {
+	n := uint32(len(d.queues))
+	if d.by == DispatchByKey && len(m.Key) > 0 {
+		// the same hash as kafka.Hash balancer, so keys of a partition spread the same way across workers
+		h := fnv.New32a()
+		_, _ = h.Write(m.Key)
+		return d.queues[h.Sum32()%n]
+	}
+	return d.queues[uint32(m.Partition)%n]
+}
+
+// close stops accepting messages and waits until workers handle queued ones
+func (d *dispatcher) close() {
+	for _, q := range d.queues {
+		close(q)
+	}
+	d.wg.Wait()
+}
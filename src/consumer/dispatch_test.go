@@ -0,0 +1,68 @@
+package consumer
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestDispatcherKeepsKeyOrder(t *testing.T) {
+	var mu sync.Mutex
+	handled := make(map[string][]int64)
+	workers := make(map[string]map[int]bool)
+	disp := newDispatcher(Dispatch{Workers: 4, By: DispatchByKey, QueueSize: 2}, func(worker int, m kafka.Message) {
+		// workers are slower than reading, so full queues block dispatching
+		time.Sleep(time.Duration(rand.Intn(100)) * time.Microsecond)
+		mu.Lock()
+		defer mu.Unlock()
+		key := string(m.Key)
+		handled[key] = append(handled[key], m.Offset)
+		if workers[key] == nil {
+			workers[key] = make(map[int]bool)
+		}
+		workers[key][worker] = true
+	})
+
+	keys := []string{"USD", "PLN", "GBP", "JPY", "CHF", "SEK"}
+	for offset := int64(0); offset < 600; offset++ {
+		m := kafka.Message{Key: []byte(keys[offset%int64(len(keys))]), Offset: offset}
+		disp.queue(m) <- m
+	}
+	disp.close()
+
+	used := make(map[int]bool)
+	for _, key := range keys {
+		offsets := handled[key]
+		if len(offsets) != 100 {
+			t.Fatalf("%s: expected 100 messages, got %d", key, len(offsets))
+		}
+		for i := 1; i < len(offsets); i++ {
+			if offsets[i] < offsets[i-1] {
+				t.Fatalf("%s: offset %d handled after %d", key, offsets[i], offsets[i-1])
+			}
+		}
+		if len(workers[key]) != 1 {
+			t.Fatalf("%s: handled by many workers %v", key, workers[key])
+		}
+		for w := range workers[key] {
+			used[w] = true
+		}
+	}
+	if len(used) < 2 {
+		t.Fatalf("keys should be spread across workers, used %v", used)
+	}
+}
+
+func TestDispatcherByPartition(t *testing.T) {
+	disp := newDispatcher(Dispatch{Workers: 3, By: DispatchByPartition}, func(int, kafka.Message) {})
+	defer disp.close()
+
+	a := disp.queue(kafka.Message{Partition: 1, Key: []byte("USD")})
+	b := disp.queue(kafka.Message{Partition: 1, Key: []byte("PLN")})
+	if a != b {
+		t.Fatal("messages of the same partition should go to the same worker")
+	}
+}
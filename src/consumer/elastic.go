@@ -0,0 +1,484 @@
+package consumer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"kafka-tryout/src/rate"
+	"kafka-tryout/src/spotify_generator"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+// IDMode tells how ids of indexed documents are derived
+type IDMode string
+
+const (
+	// IDOffset derives id from topic, partition and offset, so redelivered messages overwrite their documents,
+	// messages read from retry topics keep ids of their original messages
+	IDOffset IDMode = "offset"
+	// IDNatural derives id from the event, currency with rate date or track with time it was played,
+	// so republished events overwrite their documents too
+	IDNatural IDMode = "natural"
+	// IDAuto lets Elasticsearch generate ids, redelivered messages are indexed again
+	IDAuto IDMode = "auto"
+)
+
+// ParseIDMode parses "offset", "natural" or "auto"
+func ParseIDMode(s string) (IDMode, error) {
+	switch m := IDMode(strings.ToLower(strings.TrimSpace(s))); m {
+	case IDOffset, IDNatural, IDAuto:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown id mode %q", s)
+}
+
+// indexDateLayout is the suffix of daily indices, e.g. currencies-2020.09.04
+const indexDateLayout = "2006.01.02"
+
+// Document is indexed into daily index of Index by Time, empty ID lets Elasticsearch generate it
+type Document struct {
+	Index string
+	Time  time.Time
+	ID    string
+	Body  interface{}
+}
+
+// DailyIndex returns name of the index document belongs to
+func (d Document) DailyIndex() string {
+	return d.Index + "-" + d.Time.UTC().Format(indexDateLayout)
+}
+
+// ElasticConfig configures ElasticSink, Backoff is the delay before documents rejected with 429 are sent again,
+// zero BulkSize and FlushInterval are taken from DefaultElasticConfig
+type ElasticConfig struct {
+	BulkSize      int
+	FlushInterval time.Duration
+	MaxRetries    int
+	Backoff       Backoff
+}
+
+// DefaultElasticConfig flushes 500 documents or every second, rejected documents are retried 5 times
+var DefaultElasticConfig = ElasticConfig{
+	BulkSize:      500,
+	FlushInterval: time.Second,
+	MaxRetries:    5,
+	Backoff:       Backoff{Min: 100 * time.Millisecond, Max: 5 * time.Second, Factor: 2},
+}
+
+// ErrSinkClosed is returned by documents indexed after ElasticSink was closed
+var ErrSinkClosed = errors.New("elasticsearch sink is closed")
+
+// ElasticSink bulk-indexes documents into Elasticsearch
+type ElasticSink interface {
+	// Index adds document to the pending bulk and blocks until the bulk is indexed, so offset of message
+	// is committed only once its document is stored, bulk is sent when it's full, when every message
+	// tracked by Track waits for it or when FlushInterval passes
+	Index(ctx context.Context, doc Document) error
+	// Track returns Middleware counting messages being handled, so pending bulk is sent as soon as
+	// all of them wait in Index instead of waiting for FlushInterval, it should wrap only handlers calling Index
+	Track() Middleware
+	// PutTemplates creates or replaces index templates, so daily indices get mappings once they're created
+	PutTemplates(ctx context.Context, templates ...IndexTemplate) error
+	// Close sends pending documents, waits for bulks being sent and stops the sink
+	Close() error
+}
+
+type pendingDocument struct {
+	doc  Document
+	done chan error
+}
+
+type elasticSink struct {
+	es  *elasticsearch.Client
+	cfg ElasticConfig
+	log logrus.FieldLogger
+
+	mu      sync.Mutex
+	pending []pendingDocument
+	// inFlight counts messages tracked by Track, waiting counts callers of Index
+	inFlight int
+	waiting  int
+	closed   bool
+
+	// ready wakes up sender, stop makes it send pending documents and return
+	ready chan struct{}
+	stop  chan struct{}
+	// ctx of bulks is cancelled only after Close waits for them
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewElasticSink creates ElasticSink sending bulks with es from single goroutine
+func NewElasticSink(log logrus.FieldLogger, es *elasticsearch.Client, cfg ElasticConfig) ElasticSink {
+	if cfg.BulkSize <= 0 {
+		cfg.BulkSize = DefaultElasticConfig.BulkSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = DefaultElasticConfig.FlushInterval
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &elasticSink{es: es, cfg: cfg, log: log, ready: make(chan struct{}, 1), stop: make(chan struct{}), ctx: ctx, cancel: cancel}
+	s.wg.Add(1)
+	go s.run()
+	return s
+}
+
+func (s *elasticSink) Index(ctx context.Context, doc Document) error {
+	p := pendingDocument{doc: doc, done: make(chan error, 1)}
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrSinkClosed
+	}
+	s.pending = append(s.pending, p)
+	s.waiting++
+	s.notifyLocked()
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		s.waiting--
+		s.mu.Unlock()
+	}()
+	select {
+	case err := <-p.done:
+		return err
+	case <-ctx.Done():
+		// document may still be indexed, ids make the next delivery overwrite it
+		return ctx.Err()
+	}
+}
+
+func (s *elasticSink) Track() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, m kafka.Message, log logrus.FieldLogger) error {
+			s.mu.Lock()
+			s.inFlight++
+			s.mu.Unlock()
+			defer func() {
+				s.mu.Lock()
+				s.inFlight--
+				s.notifyLocked()
+				s.mu.Unlock()
+			}()
+			return next(ctx, m, log)
+		}
+	}
+}
+
+// notifyLocked wakes up sender if pending bulk is full or every tracked message waits for it, it's called with mu locked
+func (s *elasticSink) notifyLocked() {
+	if len(s.pending) == 0 {
+		return
+	}
+	if len(s.pending) < s.cfg.BulkSize && (s.inFlight == 0 || s.waiting < s.inFlight) {
+		return
+	}
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+}
+
+// run sends pending documents when sender is woken up or every FlushInterval, until sink is closed
+func (s *elasticSink) run() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.cfg.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			s.flush()
+			return
+		case <-s.ready:
+		case <-ticker.C:
+		}
+		s.flush()
+	}
+}
+
+// flush sends all pending documents in bulks of at most BulkSize, documents added meanwhile go to the next bulks
+func (s *elasticSink) flush() {
+	for {
+		s.mu.Lock()
+		n := len(s.pending)
+		if n > s.cfg.BulkSize {
+			n = s.cfg.BulkSize
+		}
+		bulk := s.pending[:n:n]
+		s.pending = s.pending[n:]
+		s.mu.Unlock()
+		if n == 0 {
+			return
+		}
+		s.send(s.ctx, bulk)
+	}
+}
+
+func (s *elasticSink) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	close(s.stop)
+	s.wg.Wait()
+	s.cancel()
+	return nil
+}
+
+// send indexes bulk and resolves its documents, documents rejected with 429 are sent again with backoff
+func (s *elasticSink) send(ctx context.Context, bulk []pendingDocument) {
+	for attempt := 1; ; attempt++ {
+		rejected, err := s.bulk(ctx, bulk)
+		if err != nil {
+			for _, p := range bulk {
+				p.done <- err
+			}
+			return
+		}
+		if len(rejected) == 0 {
+			return
+		}
+		if attempt > s.cfg.MaxRetries {
+			for _, p := range rejected {
+				p.done <- fmt.Errorf("document rejected %d times, Elasticsearch is overloaded", attempt)
+			}
+			return
+		}
+
+		delay := s.cfg.Backoff.delay(attempt)
+		s.log.WithField("retry_in", delay).Warnf("%d documents rejected, Elasticsearch is overloaded", len(rejected))
+		select {
+		case <-ctx.Done():
+			for _, p := range rejected {
+				p.done <- ctx.Err()
+			}
+			return
+		case <-time.After(delay):
+		}
+		bulk = rejected
+	}
+}
+
+// bulkResponse is the part of bulk API response telling result of every action
+type bulkResponse struct {
+	Items []map[string]struct {
+		Status int `json:"status"`
+		Error  *struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	} `json:"items"`
+}
+
+// bulk sends single bulk request, resolves indexed and failed documents and returns rejected ones,
+// error is returned if the whole request failed
+func (s *elasticSink) bulk(ctx context.Context, bulk []pendingDocument) ([]pendingDocument, error) {
+	body, err := bulkBody(bulk)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.es.Bulk(bytes.NewReader(body), s.es.Bulk.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to send bulk, %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusTooManyRequests {
+		return bulk, nil
+	}
+	if res.IsError() {
+		return nil, fmt.Errorf("bulk failed, %s", res.String())
+	}
+
+	var r bulkResponse
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return nil, fmt.Errorf("failed to decode bulk response, %w", err)
+	}
+	if len(r.Items) != len(bulk) {
+		return nil, fmt.Errorf("bulk response has %d items, expected %d", len(r.Items), len(bulk))
+	}
+
+	var rejected []pendingDocument
+	for i, item := range r.Items {
+		for _, result := range item {
+			switch {
+			case result.Status == http.StatusTooManyRequests:
+				rejected = append(rejected, bulk[i])
+			case result.Error != nil:
+				bulk[i].done <- fmt.Errorf("failed to index document into %s, %s: %s",
+					bulk[i].doc.DailyIndex(), result.Error.Type, result.Error.Reason)
+			case result.Status >= 300:
+				bulk[i].done <- fmt.Errorf("failed to index document into %s, status %d", bulk[i].doc.DailyIndex(), result.Status)
+			default:
+				bulk[i].done <- nil
+			}
+		}
+	}
+	return rejected, nil
+}
+
+// bulkBody encodes index actions with documents as newline delimited JSON
+func bulkBody(bulk []pendingDocument) ([]byte, error) {
+	type action struct {
+		Index string `json:"_index"`
+		ID    string `json:"_id,omitempty"`
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, p := range bulk {
+		if err := enc.Encode(map[string]action{"index": {Index: p.doc.DailyIndex(), ID: p.doc.ID}}); err != nil {
+			return nil, fmt.Errorf("failed to encode bulk action, %w", err)
+		}
+		if err := enc.Encode(p.doc.Body); err != nil {
+			return nil, fmt.Errorf("failed to encode document, %w", err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// IndexTemplate is applied to indices matching its pattern
+type IndexTemplate struct {
+	Name     string
+	Patterns []string
+	Mappings map[string]interface{}
+}
+
+func (s *elasticSink) PutTemplates(ctx context.Context, templates ...IndexTemplate) error {
+	for _, t := range templates {
+		body, err := json.Marshal(map[string]interface{}{
+			"index_patterns": t.Patterns,
+			"template":       map[string]interface{}{"mappings": t.Mappings},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to encode template %s, %w", t.Name, err)
+		}
+		res, err := s.es.Indices.PutIndexTemplate(t.Name, bytes.NewReader(body), s.es.Indices.PutIndexTemplate.WithContext(ctx))
+		if err != nil {
+			return fmt.Errorf("failed to put template %s, %w", t.Name, err)
+		}
+		res.Body.Close()
+		if res.IsError() {
+			return fmt.Errorf("failed to put template %s, %s", t.Name, res.Status())
+		}
+	}
+	return nil
+}
+
+// currencyDocument is indexed currency rate
+type currencyDocument struct {
+	Currency string    `json:"currency"`
+	Base     string    `json:"base"`
+	Rate     float64   `json:"rate"`
+	Date     string    `json:"date"`
+	Time     time.Time `json:"time"`
+}
+
+// currentlyPlayingDocument is indexed currently playing track
+type currentlyPlayingDocument struct {
+	TrackName  string    `json:"track_name"`
+	Artists    []string  `json:"artists"`
+	DurationMs int       `json:"duration_ms"`
+	PlayedAt   time.Time `json:"played_at"`
+	Time       time.Time `json:"time"`
+}
+
+// CurrencyTemplate maps daily indices of currency rates
+func CurrencyTemplate(index string) IndexTemplate {
+	return IndexTemplate{Name: index, Patterns: []string{index + "-*"}, Mappings: map[string]interface{}{
+		"properties": map[string]interface{}{
+			"currency": map[string]string{"type": "keyword"},
+			"base":     map[string]string{"type": "keyword"},
+			"rate":     map[string]string{"type": "double"},
+			"date":     map[string]string{"type": "date", "format": "yyyy-MM-dd"},
+			"time":     map[string]string{"type": "date"},
+		},
+	}}
+}
+
+// CurrentlyPlayingTemplate maps daily indices of currently playing tracks
+func CurrentlyPlayingTemplate(index string) IndexTemplate {
+	return IndexTemplate{Name: index, Patterns: []string{index + "-*"}, Mappings: map[string]interface{}{
+		"properties": map[string]interface{}{
+			"track_name": map[string]interface{}{
+				"type":   "text",
+				"fields": map[string]interface{}{"keyword": map[string]string{"type": "keyword"}},
+			},
+			"artists":     map[string]string{"type": "keyword"},
+			"duration_ms": map[string]string{"type": "long"},
+			"played_at":   map[string]string{"type": "date"},
+			"time":        map[string]string{"type": "date"},
+		},
+	}}
+}
+
+// IndexCurrencies returns CurrencyHandler indexing rates into daily indices of index by rate date,
+// rates with invalid date go to index of the message time
+func IndexCurrencies(sink ElasticSink, index string, ids IDMode) CurrencyHandler {
+	return func(ctx context.Context, c rate.SingleCurrency, meta Metadata, _ logrus.FieldLogger) error {
+		day, err := time.Parse(rate.DateLayout, c.Rate.Date)
+		if err != nil {
+			day = meta.Time
+		}
+		doc := Document{Index: index, Time: day, Body: currencyDocument{
+			Currency: c.Name,
+			Base:     c.Rate.Base,
+			Rate:     c.Rate.Rate,
+			Date:     c.Rate.Date,
+			Time:     meta.Time,
+		}}
+		doc.ID = documentID(ids, meta, c.Rate.Base+"-"+c.Name+"-"+c.Rate.Date)
+		return sink.Index(ctx, doc)
+	}
+}
+
+// IndexCurrentlyPlaying returns CurrentlyPlayingHandler indexing tracks into daily indices of index by time they were played
+func IndexCurrentlyPlaying(sink ElasticSink, index string, ids IDMode) CurrentlyPlayingHandler {
+	return func(ctx context.Context, c spotify_generator.CurrentlyPlaying, meta Metadata, _ logrus.FieldLogger) error {
+		artists := make([]string, len(c.Artists))
+		for i, a := range c.Artists {
+			artists[i] = a.Name
+		}
+		doc := Document{Index: index, Time: c.PlayedAt, Body: currentlyPlayingDocument{
+			TrackName:  c.TrackName,
+			Artists:    artists,
+			DurationMs: c.DurationMs,
+			PlayedAt:   c.PlayedAt,
+			Time:       meta.Time,
+		}}
+		doc.ID = documentID(ids, meta, strconv.FormatInt(c.PlayedAt.UnixNano()/int64(time.Millisecond), 10)+"-"+c.TrackName)
+		return sink.Index(ctx, doc)
+	}
+}
+
+// documentID returns id of document by mode, natural is the id derived from event
+func documentID(mode IDMode, meta Metadata, natural string) string {
+	switch mode {
+	case IDOffset:
+		topic, partition, offset := meta.Topic, strconv.Itoa(meta.Partition), strconv.FormatInt(meta.Offset, 10)
+		// retried messages carry coordinates of the original message, so they overwrite its document
+		if original, ok := meta.Header(HeaderOriginalTopic); ok {
+			originalPartition, _ := meta.Header(HeaderOriginalPartition)
+			originalOffset, _ := meta.Header(HeaderOriginalOffset)
+			topic, partition, offset = original, originalPartition, originalOffset
+		}
+		return topic + "-" + partition + "-" + offset
+	case IDNatural:
+		return natural
+	}
+	return ""
+}
@@ -0,0 +1,364 @@
+package consumer
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"kafka-tryout/src/rate"
+	"kafka-tryout/src/spotify_generator"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+// fakeElastic is bulk and index template endpoint keeping indexed documents by index and id,
+// the first rejectRequests bulks are rejected with 429, items of the next rejectItems bulks too,
+// every bulk takes delay
+type fakeElastic struct {
+	delay          time.Duration
+	mu             sync.Mutex
+	rejectRequests int
+	rejectItems    int
+	failIDs        map[string]bool
+	bulks          int
+	docs           map[string]map[string]json.RawMessage
+	templates      map[string]json.RawMessage
+}
+
+func newFakeElastic(t *testing.T) (*fakeElastic, *elasticsearch.Client) {
+	t.Helper()
+	f := &fakeElastic{
+		failIDs:   make(map[string]bool),
+		docs:      make(map[string]map[string]json.RawMessage),
+		templates: make(map[string]json.RawMessage),
+	}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	es, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{srv.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f, es
+}
+
+func (f *fakeElastic) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	time.Sleep(f.delay)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+
+	if strings.HasPrefix(r.URL.Path, "/_index_template/") {
+		var body json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.templates[strings.TrimPrefix(r.URL.Path, "/_index_template/")] = body
+		w.Write([]byte(`{"acknowledged":true}`))
+		return
+	}
+	if r.URL.Path != "/_bulk" {
+		http.NotFound(w, r)
+		return
+	}
+
+	f.bulks++
+	if f.rejectRequests > 0 {
+		f.rejectRequests--
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error":{"type":"es_rejected_execution_exception"},"status":429}`))
+		return
+	}
+	rejectItems := f.rejectItems > 0
+	if rejectItems {
+		f.rejectItems--
+	}
+
+	type result struct {
+		Index  string      `json:"_index"`
+		ID     string      `json:"_id"`
+		Status int         `json:"status"`
+		Error  interface{} `json:"error,omitempty"`
+	}
+	var items []map[string]result
+	lines := bufio.NewScanner(r.Body)
+	for lines.Scan() {
+		var action map[string]struct {
+			Index string `json:"_index"`
+			ID    string `json:"_id"`
+		}
+		if err := json.Unmarshal(lines.Bytes(), &action); err != nil || !lines.Scan() {
+			http.Error(w, "invalid bulk body", http.StatusBadRequest)
+			return
+		}
+		a := action["index"]
+		res := result{Index: a.Index, ID: a.ID, Status: http.StatusCreated}
+		switch {
+		case rejectItems:
+			res.Status, res.Error = http.StatusTooManyRequests, map[string]string{"type": "es_rejected_execution_exception"}
+		case f.failIDs[a.ID]:
+			res.Status, res.Error = http.StatusBadRequest, map[string]string{"type": "mapper_parsing_exception", "reason": "failed to parse field [rate]"}
+		default:
+			if f.docs[a.Index] == nil {
+				f.docs[a.Index] = make(map[string]json.RawMessage)
+			}
+			f.docs[a.Index][a.ID] = append(json.RawMessage(nil), lines.Bytes()...)
+		}
+		items = append(items, map[string]result{"index": res})
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"errors": rejectItems, "items": items})
+}
+
+func (f *fakeElastic) indexed(index string) map[string]json.RawMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	docs := make(map[string]json.RawMessage, len(f.docs[index]))
+	for id, doc := range f.docs[index] {
+		docs[id] = doc
+	}
+	return docs
+}
+
+func (f *fakeElastic) bulkCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.bulks
+}
+
+// pendingCount returns number of documents waiting for the next bulk
+func (s *elasticSink) pendingCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pending)
+}
+
+func testElasticConfig(bulkSize int, flushInterval time.Duration) ElasticConfig {
+	return ElasticConfig{BulkSize: bulkSize, FlushInterval: flushInterval, MaxRetries: 3,
+		Backoff: Backoff{Min: time.Millisecond, Max: time.Millisecond, Factor: 1}}
+}
+
+func TestElasticSinkBulk(t *testing.T) {
+	fake, es := newFakeElastic(t)
+	sink := NewElasticSink(testLogger(), es, testElasticConfig(2, time.Hour))
+	defer sink.Close()
+	h := IndexCurrencies(sink, "currencies", IDOffset)
+
+	index := func(offsets ...int64) {
+		var wg sync.WaitGroup
+		for _, offset := range offsets {
+			wg.Add(1)
+			go func(offset int64) {
+				defer wg.Done()
+				c := rate.SingleCurrency{Name: "USD", Rate: rate.Rate{Base: "EUR", Rate: 1.18, Date: "2020-09-04"}}
+				meta := Metadata{Topic: "currencies", Partition: 1, Offset: offset, Key: "USD", Time: time.Now()}
+				if err := h(context.Background(), c, meta, testLogger()); err != nil {
+					t.Error(err)
+				}
+			}(offset)
+		}
+		wg.Wait()
+	}
+
+	// both handlers wait until the full bulk is sent
+	index(1, 2)
+	docs := fake.indexed("currencies-2020.09.04")
+	if len(docs) != 2 || fake.bulkCount() != 1 {
+		t.Fatalf("expected 2 documents in 1 bulk, got %d in %d", len(docs), fake.bulkCount())
+	}
+	var doc currencyDocument
+	if err := json.Unmarshal(docs["currencies-1-2"], &doc); err != nil || doc.Currency != "USD" || doc.Rate != 1.18 {
+		t.Fatalf("unexpected document %s, %v", docs["currencies-1-2"], err)
+	}
+
+	// redelivered messages overwrite their documents
+	index(1, 2)
+	if docs := fake.indexed("currencies-2020.09.04"); len(docs) != 2 {
+		t.Fatalf("expected redelivered documents to be overwritten, got %d documents", len(docs))
+	}
+}
+
+func TestElasticSinkFlushInterval(t *testing.T) {
+	fake, es := newFakeElastic(t)
+	sink := NewElasticSink(testLogger(), es, testElasticConfig(100, 10*time.Millisecond))
+	defer sink.Close()
+
+	played := time.Date(2020, 11, 1, 23, 30, 0, 0, time.FixedZone("CET", 3600))
+	h := IndexCurrentlyPlaying(sink, "currently-playing", IDNatural)
+	cp := spotify_generator.CurrentlyPlaying{PlayedAt: played, TrackName: "Get Lucky", Artists: []spotify_generator.Artist{{Name: "Daft Punk"}}}
+	if err := h(context.Background(), cp, Metadata{Topic: "currently-playing"}, testLogger()); err != nil {
+		t.Fatal(err)
+	}
+	// daily indices are in UTC
+	docs := fake.indexed("currently-playing-2020.11.01")
+	if _, ok := docs["1604269800000-Get Lucky"]; !ok || len(docs) != 1 {
+		t.Fatalf("expected track with natural id, got %v", docs)
+	}
+}
+
+func TestElasticSinkRetry(t *testing.T) {
+	fake, es := newFakeElastic(t)
+	fake.rejectRequests, fake.rejectItems = 1, 1
+	fake.failIDs["invalid"] = true
+	sink := NewElasticSink(testLogger(), es, testElasticConfig(1, time.Hour))
+	defer sink.Close()
+
+	// rejected request and rejected items are sent again
+	doc := Document{Index: "currencies", Time: time.Now(), ID: "EUR-USD", Body: map[string]float64{"rate": 1.18}}
+	if err := sink.Index(context.Background(), doc); err != nil {
+		t.Fatal(err)
+	}
+	if fake.bulkCount() != 3 || len(fake.indexed(doc.DailyIndex())) != 1 {
+		t.Fatalf("expected document indexed by the third bulk, got %d bulks", fake.bulkCount())
+	}
+
+	// documents failed for other reasons aren't retried
+	doc.ID = "invalid"
+	if err := sink.Index(context.Background(), doc); err == nil || !strings.Contains(err.Error(), "mapper_parsing_exception") {
+		t.Fatalf("expected mapping error, got %v", err)
+	}
+
+	// rejections beyond MaxRetries fail documents
+	fake.mu.Lock()
+	fake.rejectRequests = 10
+	fake.mu.Unlock()
+	doc.ID = "EUR-GBP"
+	if err := sink.Index(context.Background(), doc); err == nil {
+		t.Fatal("expected error of document rejected too many times")
+	}
+}
+
+func TestElasticSinkTrack(t *testing.T) {
+	fake, es := newFakeElastic(t)
+	sink := NewElasticSink(testLogger(), es, testElasticConfig(100, time.Hour))
+	defer sink.Close()
+
+	var entered sync.WaitGroup
+	start := make(chan struct{})
+	h := sink.Track()(func(ctx context.Context, m kafka.Message, _ logrus.FieldLogger) error {
+		entered.Done()
+		<-start
+		return sink.Index(ctx, Document{Index: "currencies", Time: time.Now(), ID: string(m.Key), Body: map[string]string{}})
+	})
+
+	// bulk is sent once every tracked message waits for it, without waiting for FlushInterval
+	var wg sync.WaitGroup
+	for _, key := range []string{"USD", "GBP", "PLN"} {
+		entered.Add(1)
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			if err := h(context.Background(), kafka.Message{Key: []byte(key)}, testLogger()); err != nil {
+				t.Error(err)
+			}
+		}(key)
+	}
+	entered.Wait()
+	close(start)
+	wg.Wait()
+	if docs := fake.indexed("currencies-" + time.Now().UTC().Format("2006.01.02")); len(docs) != 3 || fake.bulkCount() != 1 {
+		t.Fatalf("expected 3 documents in 1 bulk, got %d in %d", len(docs), fake.bulkCount())
+	}
+}
+
+func TestElasticSinkClose(t *testing.T) {
+	fake, es := newFakeElastic(t)
+	fake.delay = 50 * time.Millisecond
+	// zero config doesn't leave documents pending forever
+	sink := NewElasticSink(testLogger(), es, ElasticConfig{})
+	if cfg := sink.(*elasticSink).cfg; cfg.BulkSize != DefaultElasticConfig.BulkSize || cfg.FlushInterval != DefaultElasticConfig.FlushInterval {
+		t.Fatalf("expected default bulk size and flush interval, got %+v", cfg)
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 2)
+	for _, c := range []struct {
+		ctx context.Context
+		id  string
+	}{{cancelled, "EUR-USD"}, {context.Background(), "EUR-GBP"}} {
+		go func(ctx context.Context, id string) {
+			errs <- sink.Index(ctx, Document{Index: "currencies", Time: time.Now(), ID: id, Body: map[string]float64{"rate": 1.18}})
+		}(c.ctx, c.id)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for sink.(*elasticSink).pendingCount() != 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout waiting for pending documents")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// cancelled caller doesn't fail documents of its bulk
+	cancel()
+	if err := <-errs; err != context.Canceled {
+		t.Fatalf("expected cancelled caller, got %v", err)
+	}
+
+	// pending documents are sent and close waits for the bulk
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("expected document indexed on close, got %v", err)
+	}
+	if docs := fake.indexed("currencies-" + time.Now().UTC().Format("2006.01.02")); len(docs) != 2 {
+		t.Fatalf("expected 2 documents indexed on close, got %d", len(docs))
+	}
+	if err := sink.Index(context.Background(), Document{Index: "currencies", Time: time.Now(), ID: "EUR-PLN"}); err != ErrSinkClosed {
+		t.Fatalf("expected closed sink error, got %v", err)
+	}
+}
+
+func TestPutTemplates(t *testing.T) {
+	fake, es := newFakeElastic(t)
+	sink := NewElasticSink(testLogger(), es, testElasticConfig(1, time.Hour))
+	defer sink.Close()
+
+	if err := sink.PutTemplates(context.Background(), CurrencyTemplate("currencies"), CurrentlyPlayingTemplate("currently-playing")); err != nil {
+		t.Fatal(err)
+	}
+	var template struct {
+		IndexPatterns []string `json:"index_patterns"`
+		Template      struct {
+			Mappings struct {
+				Properties map[string]struct {
+					Type string `json:"type"`
+				} `json:"properties"`
+			} `json:"mappings"`
+		} `json:"template"`
+	}
+	if err := json.Unmarshal(fake.templates["currencies"], &template); err != nil {
+		t.Fatal(err)
+	}
+	if len(template.IndexPatterns) != 1 || template.IndexPatterns[0] != "currencies-*" ||
+		template.Template.Mappings.Properties["currency"].Type != "keyword" || len(fake.templates) != 2 {
+		t.Fatalf("unexpected templates %s", fake.templates)
+	}
+}
+
+func TestParseIDMode(t *testing.T) {
+	if m, err := ParseIDMode(" Natural "); err != nil || m != IDNatural {
+		t.Fatalf("expected natural, got %q, %v", m, err)
+	}
+	if _, err := ParseIDMode("uuid"); err == nil {
+		t.Fatal("expected error of unknown mode")
+	}
+	if id := documentID(IDAuto, Metadata{Topic: "currencies"}, "EUR-USD"); id != "" {
+		t.Fatalf("expected generated id, got %q", id)
+	}
+
+	// message read from retry tier overwrites document of the original message
+	m := kafka.Message{Topic: "currencies", Partition: 2, Offset: 42, Key: []byte("USD")}
+	topic, routed := DefaultRetryPolicy("currencies").route(m, 1, errors.New("timeout"), time.Now())
+	routed.Topic, routed.Partition, routed.Offset = topic, 0, 7
+	original := documentID(IDOffset, Metadata{Topic: m.Topic, Partition: m.Partition, Offset: m.Offset}, "")
+	if id := documentID(IDOffset, Metadata{Topic: routed.Topic, Partition: routed.Partition, Offset: routed.Offset, Headers: routed.Headers}, ""); id != original || id != "currencies-2-42" {
+		t.Fatalf("expected id of original message %q, got %q", original, id)
+	}
+}
@@ -0,0 +1,76 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"kafka-tryout/src/codec"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+// Handler handles single message read from kafka
+type Handler func(ctx context.Context, m kafka.Message, log logrus.FieldLogger) error
+
+// Middleware wraps Handler with additional behaviour, e.g. logging or panic recovery
+type Middleware func(Handler) Handler
+
+// Chain wraps h with middlewares, the first middleware is the outermost one
+func Chain(h Handler, middlewares ...Middleware) Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// ErrShutdown is returned by handlers which gave up on message because consumer is shutting down,
+// the message isn't committed so it's read again after restart
+var ErrShutdown = errors.New("consumer is shutting down")
+
+type shutdownKey struct{}
+
+// withShutdown returns handlers' ctx carrying channel closed once graceful shutdown starts,
+// ctx itself is done only when shutdown timeout passes
+func withShutdown(ctx context.Context, shutdown <-chan struct{}) context.Context {
+	return context.WithValue(ctx, shutdownKey{}, shutdown)
+}
+
+// shuttingDown returns channel closed once graceful shutdown starts, nil channel outside of Consumer
+func shuttingDown(ctx context.Context) <-chan struct{} {
+	shutdown, _ := ctx.Value(shutdownKey{}).(<-chan struct{})
+	return shutdown
+}
+
+// Metadata describes consumed message, it's passed to typed handlers together with decoded value
+type Metadata struct {
+	Topic     string
+	Partition int
+	Offset    int64
+	Key       string
+	Time      time.Time
+	Headers   []kafka.Header
+	Schema    codec.Schema
+}
+
+func metadataOf(m kafka.Message, schema codec.Schema) Metadata {
+	return Metadata{
+		Topic:     m.Topic,
+		Partition: m.Partition,
+		Offset:    m.Offset,
+		Key:       string(m.Key),
+		Time:      m.Time,
+		Headers:   m.Headers,
+		Schema:    schema,
+	}
+}
+
+// Header returns value of the first header with given key
+func (m Metadata) Header(key string) (string, bool) {
+	for _, h := range m.Headers {
+		if h.Key == key {
+			return string(h.Value), true
+		}
+	}
+	return "", false
+}
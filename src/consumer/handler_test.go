@@ -0,0 +1,95 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"kafka-tryout/src/codec"
+	"kafka-tryout/src/rate"
+	"strings"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func testLogger() logrus.FieldLogger {
+	log, _ := test.NewNullLogger()
+	return log
+}
+
+func TestChain(t *testing.T) {
+	var calls []string
+	mw := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(ctx context.Context, m kafka.Message, log logrus.FieldLogger) error {
+				calls = append(calls, name)
+				return next(ctx, m, log)
+			}
+		}
+	}
+	h := Chain(func(context.Context, kafka.Message, logrus.FieldLogger) error {
+		calls = append(calls, "handler")
+		return nil
+	}, mw("first"), mw("second"))
+
+	if err := h(context.Background(), kafka.Message{}, testLogger()); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(calls, ",") != "first,second,handler" {
+		t.Fatalf("unexpected order of calls: %v", calls)
+	}
+}
+
+func TestRecoverAndMetrics(t *testing.T) {
+	metrics := &Metrics{}
+	h := Chain(func(_ context.Context, m kafka.Message, _ logrus.FieldLogger) error {
+		// the way old consumer read goroutine header
+		_ = m.Headers[0]
+		return nil
+	}, metrics.Middleware(), Recover(), Logging())
+
+	if err := h(context.Background(), kafka.Message{}, testLogger()); err == nil {
+		t.Fatal("panic should be turned into error")
+	}
+	if err := h(context.Background(), kafka.Message{Headers: []kafka.Header{{}}}, testLogger()); err != nil {
+		t.Fatal(err)
+	}
+	if s := metrics.Snapshot(); s.Handled != 2 || s.Failed != 1 {
+		t.Fatalf("unexpected metrics %+v", s)
+	}
+}
+
+func TestHandleCurrencies(t *testing.T) {
+	var got []rate.SingleCurrency
+	h := HandleCurrencies(func(_ context.Context, c rate.SingleCurrency, meta Metadata, _ logrus.FieldLogger) error {
+		got = append(got, c)
+		return nil
+	})
+
+	r := rate.Rate{Base: "EUR", Rate: 1.18, Date: "2020-09-04"}
+	value, headers, err := codec.Default.Encode(codec.SchemaRate, 2, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages := []kafka.Message{
+		{Key: []byte("USD"), Value: value, Headers: headers},
+		// produced before schemas were introduced, without headers at all
+		{Key: []byte("USD"), Value: []byte(`{"Base":"EUR","Rate":1.18,"Date":"2020-09-04"}`)},
+	}
+	for _, m := range messages {
+		if err := h(context.Background(), m, testLogger()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, c := range got {
+		if c.Name != "USD" || c.Rate != r {
+			t.Fatalf("unexpected currency %+v", c)
+		}
+	}
+
+	wrong := kafka.Message{Headers: []kafka.Header{{Key: codec.HeaderSchema, Value: []byte("unknown")}, {Key: codec.HeaderVersion, Value: []byte("1")}}}
+	if err := h(context.Background(), wrong, testLogger()); !errors.Is(err, codec.ErrUnknownSchema) {
+		t.Fatalf("expected ErrUnknownSchema, got %v", err)
+	}
+}
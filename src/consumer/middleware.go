@@ -0,0 +1,86 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+// Recover turns panics of the handler into errors, so one bad message doesn't kill the consumer
+func Recover() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, m kafka.Message, log logrus.FieldLogger) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					log.WithField("stack", string(debug.Stack())).Error("handler panicked")
+					err = fmt.Errorf("handler panicked: %v", r)
+				}
+			}()
+			return next(ctx, m, log)
+		}
+	}
+}
+
+// Logging adds message coordinates to the logger passed to next handlers and logs handled messages,
+// errors are left to the caller
+func Logging() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, m kafka.Message, log logrus.FieldLogger) error {
+			log = log.WithFields(logrus.Fields{
+				"topic":     m.Topic,
+				"partition": m.Partition,
+				"offset":    m.Offset,
+				"key":       string(m.Key),
+			})
+			start := time.Now()
+			if err := next(ctx, m, log); err != nil {
+				return fmt.Errorf("%s[%d]@%d: %w", m.Topic, m.Partition, m.Offset, err)
+			}
+			log.WithField("took", time.Since(start)).Debug("message handled")
+			return nil
+		}
+	}
+}
+
+// Metrics counts handled messages
+type Metrics struct {
+	handled  int64
+	failed   int64
+	duration int64
+}
+
+// MetricsSnapshot is a point in time copy of Metrics
+type MetricsSnapshot struct {
+	Handled  int64
+	Failed   int64
+	Duration time.Duration
+}
+
+func (m *Metrics) Snapshot() MetricsSnapshot {
+	return MetricsSnapshot{
+		Handled:  atomic.LoadInt64(&m.handled),
+		Failed:   atomic.LoadInt64(&m.failed),
+		Duration: time.Duration(atomic.LoadInt64(&m.duration)),
+	}
+}
+
+// Middleware returns Middleware recording handled messages in m
+func (m *Metrics) Middleware() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, msg kafka.Message, log logrus.FieldLogger) error {
+			start := time.Now()
+			err := next(ctx, msg, log)
+			atomic.AddInt64(&m.duration, int64(time.Since(start)))
+			atomic.AddInt64(&m.handled, 1)
+			if err != nil {
+				atomic.AddInt64(&m.failed, 1)
+			}
+			return err
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"kafka-tryout/src/stream"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+// headers used by retry and dead letter topics
+const (
+	HeaderRetryAttempt      = "retry-attempt"
+	HeaderRetryNotBefore    = "retry-not-before"
+	HeaderOriginalTopic     = "original-topic"
+	HeaderOriginalPartition = "original-partition"
+	HeaderOriginalOffset    = "original-offset"
+	HeaderError             = "error"
+)
+
+// RetryTier is a topic where failed messages wait given delay before being handled again
+type RetryTier struct {
+	Topic string
+	Delay time.Duration
+}
+
+// RetryPolicy tells where failed messages go, n-th retry goes to n-th tier (the last tier is reused
+// if there are more retries than tiers), messages failed more than MaxRetries times go to DLQTopic
+type RetryPolicy struct {
+	Tiers      []RetryTier
+	MaxRetries int
+	DLQTopic   string
+}
+
+// DefaultRetryPolicy returns policy with two tiers, e.g. currencies.retry.1m and currencies.retry.10m,
+// and currencies.dlq as the dead letter topic
+func DefaultRetryPolicy(topic string) RetryPolicy {
+	return RetryPolicy{
+		Tiers: []RetryTier{
+			{Topic: topic + ".retry.1m", Delay: time.Minute},
+			{Topic: topic + ".retry.10m", Delay: 10 * time.Minute},
+		},
+		MaxRetries: 3,
+		DLQTopic:   topic + ".dlq",
+	}
+}
+
+// Publisher writes messages to given topic
+type Publisher interface {
+	Publish(ctx context.Context, topic string, msgs ...kafka.Message) error
+	Close() error
+}
+
+type writersPublisher struct {
+	newWriter func(topic string) stream.MessageWriter
+
+	mu      sync.Mutex
+	writers map[string]stream.MessageWriter
+}
+
+// NewWritersPublisher returns Publisher creating writer with newWriter for every topic it writes to
+func NewWritersPublisher(newWriter func(topic string) stream.MessageWriter) Publisher {
+	return &writersPublisher{newWriter: newWriter, writers: make(map[string]stream.MessageWriter)}
+}
+
+func (p *writersPublisher) Publish(ctx context.Context, topic string, msgs ...kafka.Message) error {
+	p.mu.Lock()
+	w, ok := p.writers[topic]
+	if !ok {
+		w = p.newWriter(topic)
+		p.writers[topic] = w
+	}
+	p.mu.Unlock()
+	return w.WriteMessages(ctx, msgs...)
+}
+
+func (p *writersPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var firstErr error
+	for topic, w := range p.writers {
+		if err := w.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to close writer of %s, %w", topic, err)
+		}
+	}
+	return firstErr
+}
+
+// Retry routes messages failed by next handlers to retry tiers and finally to dead letter topic,
+// message is considered handled once it's routed, error is returned only if routing failed
+func Retry(policy RetryPolicy, pub Publisher) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, m kafka.Message, log logrus.FieldLogger) error {
+			handleErr := next(ctx, m, log)
+			if handleErr == nil {
+				return nil
+			}
+
+			attempt := retryAttempt(m) + 1
+			topic, routed := policy.route(m, attempt, handleErr, time.Now())
+			if err := pub.Publish(ctx, topic, routed); err != nil {
+				return fmt.Errorf("failed to route message to %s (%v), %w", topic, handleErr, err)
+			}
+			log.WithError(handleErr).WithFields(logrus.Fields{
+				"attempt": attempt,
+				"routed":  topic,
+			}).Warn("message failed, routed")
+			return nil
+		}
+	}
+}
+
+// WaitRetryDelay delays handling of messages read from retry tiers until their delay passes,
+// tiers have constant delays so messages of a tier are ordered by the time they are due,
+// waiting stops with ErrShutdown once consumer shuts down, so it doesn't use up shutdown timeout
+func WaitRetryDelay() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, m kafka.Message, log logrus.FieldLogger) error {
+			if notBefore, ok := retryNotBefore(m); ok {
+				if wait := time.Until(notBefore); wait > 0 {
+					log.Debugf("waiting %s for retry", wait)
+					timer := time.NewTimer(wait)
+					defer timer.Stop()
+					select {
+					case <-ctx.Done():
+						return ctx.Err()
+					case <-shuttingDown(ctx):
+						return ErrShutdown
+					case <-timer.C:
+					}
+				}
+			}
+			return next(ctx, m, log)
+		}
+	}
+}
+
+// route returns topic and message which failed attempt times with given error
+func (p RetryPolicy) route(m kafka.Message, attempt int, handleErr error, now time.Time) (string, kafka.Message) {
+	routed := kafka.Message{
+		Key:   m.Key,
+		Value: m.Value,
+		Time:  m.Time,
+	}
+	// original coordinates are kept once the message starts its way through retry tiers
+	for _, h := range m.Headers {
+		switch h.Key {
+		case HeaderRetryAttempt, HeaderRetryNotBefore, HeaderError:
+			continue
+		}
+		routed.Headers = append(routed.Headers, h)
+	}
+	if _, ok := headerOf(m, HeaderOriginalTopic); !ok {
+		routed.Headers = append(routed.Headers,
+			kafka.Header{Key: HeaderOriginalTopic, Value: []byte(m.Topic)},
+			kafka.Header{Key: HeaderOriginalPartition, Value: []byte(strconv.Itoa(m.Partition))},
+			kafka.Header{Key: HeaderOriginalOffset, Value: []byte(strconv.FormatInt(m.Offset, 10))},
+		)
+	}
+	routed.Headers = append(routed.Headers,
+		kafka.Header{Key: HeaderRetryAttempt, Value: []byte(strconv.Itoa(attempt))},
+		kafka.Header{Key: HeaderError, Value: []byte(handleErr.Error())},
+	)
+
+	if attempt > p.MaxRetries || len(p.Tiers) == 0 {
+		return p.DLQTopic, routed
+	}
+	tier := p.Tiers[len(p.Tiers)-1]
+	if attempt <= len(p.Tiers) {
+		tier = p.Tiers[attempt-1]
+	}
+	notBefore := strconv.FormatInt(now.Add(tier.Delay).UnixNano()/int64(time.Millisecond), 10)
+	routed.Headers = append(routed.Headers, kafka.Header{Key: HeaderRetryNotBefore, Value: []byte(notBefore)})
+	return tier.Topic, routed
+}
+
+// retryAttempt returns how many times message has already failed
+func retryAttempt(m kafka.Message) int {
+	v, ok := headerOf(m, HeaderRetryAttempt)
+	if !ok {
+		return 0
+	}
+	attempt, err := strconv.Atoi(v)
+	if err != nil {
+		return 0
+	}
+	return attempt
+}
+
+// retryNotBefore returns time, in unix milliseconds header, when message may be retried
+func retryNotBefore(m kafka.Message) (time.Time, bool) {
+	v, ok := headerOf(m, HeaderRetryNotBefore)
+	if !ok {
+		return time.Time{}, false
+	}
+	ms, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, ms*int64(time.Millisecond)), true
+}
+
+func headerOf(m kafka.Message, key string) (string, bool) {
+	return Metadata{Headers: m.Headers}.Header(key)
+}
@@ -0,0 +1,97 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+type recordingPublisher struct {
+	published map[string][]kafka.Message
+}
+
+func (p *recordingPublisher) Publish(_ context.Context, topic string, msgs ...kafka.Message) error {
+	if p.published == nil {
+		p.published = make(map[string][]kafka.Message)
+	}
+	p.published[topic] = append(p.published[topic], msgs...)
+	return nil
+}
+
+func (p *recordingPublisher) Close() error { return nil }
+
+func TestRetry(t *testing.T) {
+	policy := DefaultRetryPolicy("currencies")
+	pub := &recordingPublisher{}
+	h := Chain(func(context.Context, kafka.Message, logrus.FieldLogger) error {
+		return errors.New("rate rejected")
+	}, Retry(policy, pub))
+
+	m := kafka.Message{Topic: "currencies", Partition: 2, Offset: 42, Key: []byte("USD"), Value: []byte("{}")}
+	// every failure moves message one step further: 1m, 10m, 10m again and finally DLQ
+	topics := []string{"currencies.retry.1m", "currencies.retry.10m", "currencies.retry.10m", "currencies.dlq"}
+	for i, topic := range topics {
+		if err := h(context.Background(), m, testLogger()); err != nil {
+			t.Fatal(err)
+		}
+		msgs := pub.published[topic]
+		if len(msgs) == 0 {
+			t.Fatalf("attempt %d: expected message in %s, got %v", i+1, topic, pub.published)
+		}
+		routed := msgs[len(msgs)-1]
+		if v, _ := headerOf(routed, HeaderRetryAttempt); v != strconv.Itoa(i+1) {
+			t.Fatalf("attempt %d: unexpected retry attempt %q", i+1, v)
+		}
+		// message read from retry topic keeps original coordinates
+		routed.Topic, routed.Partition, routed.Offset = topic, 0, int64(i)
+		m = routed
+	}
+
+	dlq := pub.published["currencies.dlq"][0]
+	expected := map[string]string{
+		HeaderOriginalTopic:     "currencies",
+		HeaderOriginalPartition: "2",
+		HeaderOriginalOffset:    "42",
+		HeaderError:             "rate rejected",
+	}
+	for key, value := range expected {
+		if v, _ := headerOf(dlq, key); v != value {
+			t.Errorf("expected %s header %q, got %q", key, value, v)
+		}
+	}
+	if _, ok := headerOf(dlq, HeaderRetryNotBefore); ok {
+		t.Error("dead letter should not be delayed")
+	}
+	if len(dlq.Headers) != 5 {
+		t.Errorf("headers should not be duplicated: %v", dlq.Headers)
+	}
+}
+
+func TestWaitRetryDelay(t *testing.T) {
+	notBefore := time.Now().Add(time.Hour).UnixNano() / int64(time.Millisecond)
+	m := kafka.Message{Headers: []kafka.Header{{Key: HeaderRetryNotBefore, Value: []byte(strconv.FormatInt(notBefore, 10))}}}
+	h := Chain(func(context.Context, kafka.Message, logrus.FieldLogger) error {
+		t.Fatal("message should not be handled before its delay")
+		return nil
+	}, WaitRetryDelay())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := h(ctx, m, testLogger()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+
+	// shutdown stops waiting long before shutdown timeout passes
+	shutdown := make(chan struct{})
+	close(shutdown)
+	drain, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := h(withShutdown(drain, shutdown), m, testLogger()); !errors.Is(err, ErrShutdown) {
+		t.Fatalf("expected shutdown error, got %v", err)
+	}
+}
@@ -0,0 +1,192 @@
+package filelog
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func tempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "filelog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func fetchN(t *testing.T, r *Reader, n int) []kafka.Message {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var msgs []kafka.Message
+	for len(msgs) < n {
+		m, err := r.FetchMessage(ctx)
+		if err != nil {
+			t.Fatalf("failed to fetch message %d, %v", len(msgs), err)
+		}
+		msgs = append(msgs, m)
+	}
+	return msgs
+}
+
+func TestWriteReadCommit(t *testing.T) {
+	dir := tempDir(t)
+	w, err := NewWriter(dir, "currencies", 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// small segments make writes roll over several files
+	w.segmentSize = 2
+
+	var msgs []kafka.Message
+	for i := 0; i < 10; i++ {
+		msgs = append(msgs, kafka.Message{
+			Key:     []byte(fmt.Sprintf("key-%d", i%3)),
+			Value:   []byte(fmt.Sprintf("value-%d", i)),
+			Headers: []kafka.Header{{Key: "schema", Value: []byte("rate")}},
+		})
+	}
+	if err := w.WriteMessages(context.Background(), msgs...); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReader(dir, "currencies", "group", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read := fetchN(t, r, 10)
+	next := make(map[int]int64)
+	for _, m := range read {
+		if m.Offset != next[m.Partition] {
+			t.Fatalf("expected offset %d in partition %d, got %d", next[m.Partition], m.Partition, m.Offset)
+		}
+		next[m.Partition]++
+		if string(m.Headers[0].Value) != "rate" || m.Topic != "currencies" {
+			t.Fatalf("unexpected message %+v", m)
+		}
+	}
+	if err := r.CommitMessages(context.Background(), read[:6]...); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the group resumes after committed messages and sees messages written meanwhile
+	if err := w.WriteMessages(context.Background(), kafka.Message{Key: []byte("key-0"), Value: []byte("value-10")}); err != nil {
+		t.Fatal(err)
+	}
+	r, err = NewReader(dir, "currencies", "group", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	resumed := fetchN(t, r, 5)
+	seen := make(map[string]bool)
+	for _, m := range resumed {
+		seen[string(m.Value)] = true
+	}
+	for _, m := range read[:6] {
+		if seen[string(m.Value)] {
+			t.Fatalf("committed message %s was read again", m.Value)
+		}
+	}
+	if !seen["value-10"] {
+		t.Fatalf("message written after restart wasn't read, got %v", seen)
+	}
+}
+
+func TestFetchWaitsForWrites(t *testing.T) {
+	dir := tempDir(t)
+	r, err := NewReader(dir, "spotify", "", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := r.FetchMessage(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected fetch to wait for messages, got %v", err)
+	}
+	if err := r.CommitMessages(context.Background(), kafka.Message{Topic: "spotify"}); err == nil {
+		t.Fatal("reader without group shouldn't commit")
+	}
+
+	w, err := NewWriter(dir, "spotify", 3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteMessages(context.Background(), kafka.Message{Value: []byte("track")}); err != nil {
+		t.Fatal(err)
+	}
+	if m := fetchN(t, r, 1)[0]; string(m.Value) != "track" || m.Offset != 0 {
+		t.Fatalf("unexpected message %+v", m)
+	}
+}
+
+func TestSaveGroupConcurrently(t *testing.T) {
+	dir := tempDir(t)
+	errs := make(chan error, 10)
+	for i := 0; i < cap(errs); i++ {
+		go func(offset int64) {
+			errs <- saveGroup(dir, "currencies", "group", map[int]int64{0: offset})
+		}(int64(i))
+	}
+	for i := 0; i < cap(errs); i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// every save replaces the whole file and leaves no temporary files behind
+	if offsets, err := loadGroup(dir, "currencies", "group"); err != nil || len(offsets) != 1 {
+		t.Fatalf("expected offsets of one save, got %v, %v", offsets, err)
+	}
+	files, err := ioutil.ReadDir(filepath.Dir(groupPath(dir, "currencies", "group")))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("expected only offsets file, got %d files, %v", len(files), err)
+	}
+}
+
+func TestWritersShareLog(t *testing.T) {
+	dir := tempDir(t)
+	var writers []*Writer
+	for i := 0; i < 2; i++ {
+		w, err := NewWriter(dir, "logs", 1, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.segmentSize = 2
+		writers = append(writers, w)
+	}
+
+	// writers keep ends of partitions in memory, appends of the other writer are noticed
+	for i := 0; i < 9; i++ {
+		w := writers[i/3%2]
+		if err := w.WriteMessages(context.Background(), kafka.Message{Value: []byte(fmt.Sprintf("value-%d", i))}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r, err := NewReader(dir, "logs", "", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	for i, m := range fetchN(t, r, 9) {
+		if m.Offset != int64(i) || string(m.Value) != fmt.Sprintf("value-%d", i) {
+			t.Fatalf("expected value-%d at offset %d, got %s at %d", i, i, m.Value, m.Offset)
+		}
+	}
+	if bases, err := segments(dir, "logs", 0); err != nil || len(bases) != 5 {
+		t.Fatalf("expected 5 segments of 2 messages, got %v, %v", bases, err)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build windows
+// +build windows
+
+package filelog
+
+// lockFile is a no-op without flock, only a single writing process is supported
+func lockFile(string) (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,26 @@
+//go:build !windows
+// +build !windows
+
+package filelog
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// lockFile takes exclusive lock of path, so writers in other processes don't interleave appends
+func lockFile(path string) (func(), error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file, %w", err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to lock %s, %w", path, err)
+	}
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
@@ -0,0 +1,167 @@
+// Package filelog is an append-only partitioned log kept in local files, it implements stream contract
+// so pipelines run without broker, e.g. during offline development
+//
+// Layout of the log directory:
+//
+//	<dir>/<topic>/topic.json                   number of partitions
+//	<dir>/<topic>/<partition>/<offset>.log     segments, a JSON message per line, named by offset of the first message
+//	<dir>/<topic>/groups/<group>.json          offsets committed by consumer group
+package filelog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	// DefaultPartitions is the number of partitions of topics created by writers
+	DefaultPartitions = 3
+	// DefaultSegmentSize is the number of messages in a segment file before the next one is started
+	DefaultSegmentSize = 1000
+
+	segmentExt = ".log"
+)
+
+// record is a message as it's stored in segment file
+type record struct {
+	Key     []byte         `json:"key,omitempty"`
+	Value   []byte         `json:"value,omitempty"`
+	Headers []kafka.Header `json:"headers,omitempty"`
+	Time    time.Time      `json:"time"`
+}
+
+type topicMeta struct {
+	Partitions int `json:"partitions"`
+}
+
+// createTopic creates topic directory with given number of partitions, existing topic is left untouched
+func createTopic(dir, topic string, partitions int) (int, error) {
+	if existing, err := loadTopic(dir, topic); err == nil {
+		return existing, nil
+	} else if !os.IsNotExist(err) {
+		return 0, err
+	}
+	for p := 0; p < partitions; p++ {
+		if err := os.MkdirAll(partitionDir(dir, topic, p), 0755); err != nil {
+			return 0, fmt.Errorf("failed to create partition %d of %s, %w", p, topic, err)
+		}
+	}
+	b, err := json.Marshal(topicMeta{Partitions: partitions})
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode topic %s, %w", topic, err)
+	}
+	if err := writeAtomic(filepath.Join(dir, topic, "topic.json"), b); err != nil {
+		return 0, err
+	}
+	return partitions, nil
+}
+
+// loadTopic returns number of partitions of topic, error satisfies os.IsNotExist if topic doesn't exist
+func loadTopic(dir, topic string) (int, error) {
+	b, err := ioutil.ReadFile(filepath.Join(dir, topic, "topic.json"))
+	if err != nil {
+		return 0, err
+	}
+	var meta topicMeta
+	if err := json.Unmarshal(b, &meta); err != nil {
+		return 0, fmt.Errorf("failed to decode topic %s, %w", topic, err)
+	}
+	return meta.Partitions, nil
+}
+
+func partitionDir(dir, topic string, partition int) string {
+	return filepath.Join(dir, topic, strconv.Itoa(partition))
+}
+
+func segmentPath(dir, topic string, partition int, base int64) string {
+	return filepath.Join(partitionDir(dir, topic, partition), fmt.Sprintf("%020d%s", base, segmentExt))
+}
+
+// segments returns base offsets of partition segments in ascending order
+func segments(dir, topic string, partition int) ([]int64, error) {
+	files, err := ioutil.ReadDir(partitionDir(dir, topic, partition))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list segments of %s[%d], %w", topic, partition, err)
+	}
+	var bases []int64
+	for _, f := range files {
+		name := f.Name()
+		if !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		base, err := strconv.ParseInt(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		bases = append(bases, base)
+	}
+	sort.Slice(bases, func(i, j int) bool { return bases[i] < bases[j] })
+	return bases, nil
+}
+
+func groupPath(dir, topic, group string) string {
+	return filepath.Join(dir, topic, "groups", group+".json")
+}
+
+// loadGroup returns offsets committed by group, i.e. offsets of the next messages to read
+func loadGroup(dir, topic, group string) (map[int]int64, error) {
+	offsets := make(map[int]int64)
+	b, err := ioutil.ReadFile(groupPath(dir, topic, group))
+	if os.IsNotExist(err) {
+		return offsets, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read offsets of %s, %w", group, err)
+	}
+	if err := json.Unmarshal(b, &offsets); err != nil {
+		return nil, fmt.Errorf("failed to decode offsets of %s, %w", group, err)
+	}
+	return offsets, nil
+}
+
+func saveGroup(dir, topic, group string, offsets map[int]int64) error {
+	b, err := json.Marshal(offsets)
+	if err != nil {
+		return fmt.Errorf("failed to encode offsets of %s, %w", group, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(groupPath(dir, topic, group)), 0755); err != nil {
+		return fmt.Errorf("failed to create groups directory, %w", err)
+	}
+	return writeAtomic(groupPath(dir, topic, group), b)
+}
+
+// writeAtomic writes data to unique temporary file and renames it, so readers never see half written file
+// and concurrent writers don't overwrite each other's temporary files
+func writeAtomic(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file of %s, %w", path, err)
+	}
+	// removing fails once the file is renamed
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s, %w", path, err)
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s, %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s, %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace %s, %w", path, err)
+	}
+	return nil
+}
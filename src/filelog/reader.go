@@ -0,0 +1,257 @@
+package filelog
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"kafka-tryout/src/stream"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// DefaultPollInterval is how often readers check partitions for new messages once they're read to the end
+const DefaultPollInterval = 50 * time.Millisecond
+
+// cursor is the read position in partition
+type cursor struct {
+	base    int64 // first offset of the open segment
+	next    int64 // offset of the next message
+	file    *os.File
+	pending []byte // read bytes which don't end with new line yet
+}
+
+// Reader reads all partitions of topic as a member of consumer group, offsets committed by the group
+// are kept in the log directory, so the next reader of the group resumes where this one stopped
+type Reader struct {
+	dir   string
+	topic string
+	group string
+	poll  time.Duration
+
+	mu      sync.Mutex
+	cursors []*cursor
+	last    int // partition read most recently, partitions are read in turns
+	closed  chan struct{}
+
+	commitMu  sync.Mutex
+	committed map[int]int64
+}
+
+var _ stream.MessageReader = (*Reader)(nil)
+
+// NewReader opens topic in dir for group, topic is created with given number of partitions if it doesn't exist,
+// reader without group starts from the first offset and can't commit
+func NewReader(dir, topic, group string, partitions int) (*Reader, error) {
+	if partitions < 1 {
+		partitions = DefaultPartitions
+	}
+	n, err := createTopic(dir, topic, partitions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open topic %s, %w", topic, err)
+	}
+	committed := make(map[int]int64)
+	if group != "" {
+		if committed, err = loadGroup(dir, topic, group); err != nil {
+			return nil, err
+		}
+	}
+	r := &Reader{
+		dir:       dir,
+		topic:     topic,
+		group:     group,
+		poll:      DefaultPollInterval,
+		last:      n - 1,
+		closed:    make(chan struct{}),
+		committed: committed,
+	}
+	for p := 0; p < n; p++ {
+		c, err := r.seek(p, committed[p])
+		if err != nil {
+			r.closeFiles()
+			return nil, err
+		}
+		r.cursors = append(r.cursors, c)
+	}
+	return r, nil
+}
+
+// seek opens segment containing offset and skips messages before it
+func (r *Reader) seek(partition int, offset int64) (*cursor, error) {
+	bases, err := segments(r.dir, r.topic, partition)
+	if err != nil {
+		return nil, err
+	}
+	c := &cursor{next: offset}
+	for _, base := range bases {
+		if base <= offset {
+			c.base = base
+		}
+	}
+	f, err := os.Open(segmentPath(r.dir, r.topic, partition, c.base))
+	if os.IsNotExist(err) {
+		// partition is empty, the segment is opened once it's written
+		c.base, c.next = offset, offset
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open segment of %s[%d], %w", r.topic, partition, err)
+	}
+	c.file = f
+	br := bufio.NewReader(f)
+	var pos int64
+	for skip := offset - c.base; skip > 0; skip-- {
+		line, err := br.ReadBytes('\n')
+		if err != nil {
+			// committed offset is past the end of the log, reading continues from its end
+			c.next = c.base + (offset - c.base - skip)
+			break
+		}
+		pos += int64(len(line))
+	}
+	if _, err := f.Seek(pos, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to seek segment of %s[%d], %w", r.topic, partition, err)
+	}
+	return c, nil
+}
+
+// FetchMessage returns the next message of any partition, it blocks until a message is written or ctx is done
+func (r *Reader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	for {
+		m, ok, err := r.fetch()
+		if err != nil || ok {
+			return m, err
+		}
+		select {
+		case <-ctx.Done():
+			return kafka.Message{}, ctx.Err()
+		case <-r.closed:
+			return kafka.Message{}, ErrClosed
+		case <-time.After(r.poll):
+		}
+	}
+}
+
+// fetch reads partitions in turns and returns the first message found
+func (r *Reader) fetch() (kafka.Message, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	select {
+	case <-r.closed:
+		return kafka.Message{}, false, ErrClosed
+	default:
+	}
+	for i := 1; i <= len(r.cursors); i++ {
+		p := (r.last + i) % len(r.cursors)
+		m, ok, err := r.read(p)
+		if err != nil {
+			return kafka.Message{}, false, err
+		}
+		if ok {
+			r.last = p
+			return m, true, nil
+		}
+	}
+	return kafka.Message{}, false, nil
+}
+
+// read returns the next message of partition if it was written already
+func (r *Reader) read(partition int) (kafka.Message, bool, error) {
+	c := r.cursors[partition]
+	for {
+		if c.file == nil {
+			f, err := os.Open(segmentPath(r.dir, r.topic, partition, c.base))
+			if os.IsNotExist(err) {
+				return kafka.Message{}, false, nil
+			}
+			if err != nil {
+				return kafka.Message{}, false, fmt.Errorf("failed to open segment of %s[%d], %w", r.topic, partition, err)
+			}
+			c.file = f
+		}
+
+		if i := bytes.IndexByte(c.pending, '\n'); i >= 0 {
+			line := c.pending[:i]
+			c.pending = c.pending[i+1:]
+			var rec record
+			if err := json.Unmarshal(line, &rec); err != nil {
+				return kafka.Message{}, false, fmt.Errorf("failed to decode %s[%d] at offset %d, %w", r.topic, partition, c.next, err)
+			}
+			m := kafka.Message{
+				Topic:     r.topic,
+				Partition: partition,
+				Offset:    c.next,
+				Key:       rec.Key,
+				Value:     rec.Value,
+				Headers:   rec.Headers,
+				Time:      rec.Time,
+			}
+			c.next++
+			return m, true, nil
+		}
+
+		buf := make([]byte, 64*1024)
+		n, err := c.file.Read(buf)
+		if n > 0 {
+			c.pending = append(c.pending, buf[:n]...)
+			continue
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return kafka.Message{}, false, fmt.Errorf("failed to read segment of %s[%d], %w", r.topic, partition, err)
+		}
+		// the end of segment, the next one starts at the next offset once the writer rolls
+		if len(c.pending) > 0 {
+			return kafka.Message{}, false, nil
+		}
+		if _, err := os.Stat(segmentPath(r.dir, r.topic, partition, c.next)); err != nil || c.next == c.base {
+			return kafka.Message{}, false, nil
+		}
+		c.file.Close()
+		c.file, c.base = nil, c.next
+	}
+}
+
+// CommitMessages stores offsets following msgs in group offsets file
+func (r *Reader) CommitMessages(_ context.Context, msgs ...kafka.Message) error {
+	if r.group == "" {
+		return errors.New("unavailable when group is not set")
+	}
+	r.commitMu.Lock()
+	defer r.commitMu.Unlock()
+	for _, m := range msgs {
+		if m.Topic != r.topic {
+			continue
+		}
+		if m.Offset+1 > r.committed[m.Partition] {
+			r.committed[m.Partition] = m.Offset + 1
+		}
+	}
+	return saveGroup(r.dir, r.topic, r.group, r.committed)
+}
+
+func (r *Reader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	select {
+	case <-r.closed:
+		return nil
+	default:
+	}
+	close(r.closed)
+	r.closeFiles()
+	return nil
+}
+
+func (r *Reader) closeFiles() {
+	for _, c := range r.cursors {
+		if c.file != nil {
+			c.file.Close()
+		}
+	}
+}
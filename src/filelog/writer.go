@@ -0,0 +1,210 @@
+package filelog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"kafka-tryout/src/stream"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// ErrClosed is returned by writers and readers used after Close
+var ErrClosed = errors.New("file log closed")
+
+// Writer appends messages to topic partitions, it's safe for concurrent use and for several processes
+// writing the same log
+type Writer struct {
+	dir         string
+	topic       string
+	partitions  []int
+	balancer    kafka.Balancer
+	segmentSize int
+
+	mu     sync.Mutex
+	closed bool
+	// ends of partitions seen by the last appends, so segments aren't counted on every write
+	ends map[int]segmentEnd
+}
+
+// segmentEnd is the active segment of partition, its number of messages and size, size changed
+// by another process means the segment has to be counted again
+type segmentEnd struct {
+	base, count, size int64
+}
+
+var _ stream.MessageWriter = (*Writer)(nil)
+
+// NewWriter opens topic in dir, topic is created with given number of partitions if it doesn't exist,
+// balancer picks partition of every message, nil means hashing the key like kafka writers in this repo
+func NewWriter(dir, topic string, partitions int, balancer kafka.Balancer) (*Writer, error) {
+	if partitions < 1 {
+		partitions = DefaultPartitions
+	}
+	if balancer == nil {
+		balancer = &kafka.Hash{}
+	}
+	n, err := createTopic(dir, topic, partitions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open topic %s, %w", topic, err)
+	}
+	w := &Writer{dir: dir, topic: topic, balancer: balancer, segmentSize: DefaultSegmentSize, ends: make(map[int]segmentEnd)}
+	for p := 0; p < n; p++ {
+		w.partitions = append(w.partitions, p)
+	}
+	return w, nil
+}
+
+func (w *Writer) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return ErrClosed
+	}
+
+	byPartition := make(map[int][]kafka.Message)
+	for _, m := range msgs {
+		p := w.balancer.Balance(m, w.partitions...)
+		byPartition[p] = append(byPartition[p], m)
+	}
+	for p, batch := range byPartition {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := w.append(p, batch); err != nil {
+			return fmt.Errorf("failed to append to %s[%d], %w", w.topic, p, err)
+		}
+	}
+	return nil
+}
+
+// append writes messages at the end of partition while holding its lock file, w.mu is held by the caller
+func (w *Writer) append(partition int, msgs []kafka.Message) error {
+	dir := partitionDir(w.dir, w.topic, partition)
+	unlock, err := lockFile(filepath.Join(dir, ".lock"))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	end, ok := w.ends[partition]
+	if !ok || !w.unchanged(partition, end) {
+		if end, err = w.locateEnd(partition); err != nil {
+			return err
+		}
+	}
+	// failed append leaves the end unknown, so it's located again
+	delete(w.ends, partition)
+
+	for len(msgs) > 0 {
+		if end.count >= int64(w.segmentSize) {
+			end = segmentEnd{base: end.base + end.count}
+		}
+		n := int64(w.segmentSize) - end.count
+		if n > int64(len(msgs)) {
+			n = int64(len(msgs))
+		}
+		written, err := appendRecords(segmentPath(w.dir, w.topic, partition, end.base), msgs[:n])
+		if err != nil {
+			return err
+		}
+		end.count += n
+		end.size += written
+		msgs = msgs[n:]
+	}
+	w.ends[partition] = end
+	return nil
+}
+
+// unchanged tells whether end is still the end of partition, i.e. its segment isn't full
+// and no other process appended to it
+func (w *Writer) unchanged(partition int, end segmentEnd) bool {
+	if end.count >= int64(w.segmentSize) {
+		return false
+	}
+	info, err := os.Stat(segmentPath(w.dir, w.topic, partition, end.base))
+	if os.IsNotExist(err) {
+		return end.size == 0
+	}
+	return err == nil && info.Size() == end.size
+}
+
+// locateEnd counts messages of the last segment of partition
+func (w *Writer) locateEnd(partition int) (segmentEnd, error) {
+	bases, err := segments(w.dir, w.topic, partition)
+	if err != nil || len(bases) == 0 {
+		return segmentEnd{}, err
+	}
+	end := segmentEnd{base: bases[len(bases)-1]}
+	end.count, end.size, err = countRecords(segmentPath(w.dir, w.topic, partition, end.base))
+	return end, err
+}
+
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	return nil
+}
+
+// appendRecords appends messages to segment and returns number of written bytes
+func appendRecords(path string, msgs []kafka.Message) (int64, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open segment, %w", err)
+	}
+	var buf []byte
+	for _, m := range msgs {
+		r := record{Key: m.Key, Value: m.Value, Headers: m.Headers, Time: m.Time}
+		if r.Time.IsZero() {
+			r.Time = time.Now()
+		}
+		b, err := json.Marshal(r)
+		if err != nil {
+			f.Close()
+			return 0, fmt.Errorf("failed to encode message, %w", err)
+		}
+		buf = append(append(buf, b...), '\n')
+	}
+	// a single write keeps the batch whole for readers polling the segment
+	if _, err := f.Write(buf); err != nil {
+		f.Close()
+		return 0, fmt.Errorf("failed to write segment, %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return 0, fmt.Errorf("failed to close segment, %w", err)
+	}
+	return int64(len(buf)), nil
+}
+
+// countRecords returns number of complete messages in segment and its size
+func countRecords(path string) (count, size int64, err error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to open segment, %w", err)
+	}
+	defer f.Close()
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := f.Read(buf)
+		count += int64(bytes.Count(buf[:n], []byte{'\n'}))
+		size += int64(n)
+		if errors.Is(err, io.EOF) {
+			return count, size, nil
+		}
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to read segment, %w", err)
+		}
+	}
+}
@@ -4,9 +4,11 @@ go 1.14
 
 require (
 	github.com/davecgh/go-spew v1.1.1
+	github.com/elastic/go-elasticsearch/v8 v8.0.0-20200901131320-e21ad8e37e8d
 	github.com/segmentio/kafka-go v0.4.2
 	github.com/sirupsen/logrus v1.6.0
 	github.com/zmb3/spotify v0.0.0-20200814173021-9bec46940cc0
 	golang.org/x/oauth2 v0.0.0-20200902213428-5d25da1a8d43
-	github.com/elastic/go-elasticsearch/v8 v8.0.0-20200901131320-e21ad8e37e8d
+	google.golang.org/protobuf v1.25.0
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"kafka-tryout/src/admin"
+	"kafka-tryout/src/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+// tool runs commands of groups CLI against cluster
+type tool struct {
+	log    logrus.FieldLogger
+	groups admin.Groups
+	out    printer
+	cfg    config.Config
+}
+
+func (t *tool) run(ctx context.Context, command string, args []string) error {
+	commands := map[string]func(context.Context, []string) error{
+		"list":     t.list,
+		"describe": t.describe,
+		"lag":      t.lag,
+		"reset":    t.reset,
+	}
+	cmd, ok := commands[command]
+	if !ok {
+		return fmt.Errorf("unknown command %q", command)
+	}
+	return cmd(ctx, args)
+}
+
+func (t *tool) list(ctx context.Context, args []string) error {
+	if err := flag.NewFlagSet("list", flag.ContinueOnError).Parse(args); err != nil {
+		return err
+	}
+	groups, err := t.groups.ListGroups(ctx)
+	if err != nil {
+		return err
+	}
+	return t.out.Names(groups)
+}
+
+func (t *tool) describe(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("describe", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	info, err := t.groups.DescribeGroup(ctx, t.group(fs))
+	if err != nil {
+		return err
+	}
+	return t.out.Group(info)
+}
+
+func (t *tool) lag(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("lag", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var topics []string
+	if fs.NArg() > 1 {
+		topics = fs.Args()[1:]
+	}
+	offsets, err := t.groups.Offsets(ctx, t.group(fs), topics...)
+	if err != nil {
+		return err
+	}
+	return t.out.Offsets(offsets)
+}
+
+func (t *tool) reset(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("reset", flag.ContinueOnError)
+	topic := fs.String("topic", t.cfg.Topics.Currencies, "topic offsets are reset on, all its partitions are reset")
+	to := fs.String("to", "", "earliest, latest, RFC3339 time, e.g. 2020-11-01T10:00:00Z, or offset")
+	dryRun := fs.Bool("dry-run", false, "only print new offsets")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *to == "" {
+		return errors.New("-to is required")
+	}
+	target, err := admin.ParseResetTarget(*to)
+	if err != nil {
+		return err
+	}
+
+	group := t.group(fs)
+	resets, err := t.groups.ResetOffsets(ctx, group, *topic, target, *dryRun)
+	if err != nil {
+		return err
+	}
+	if err := t.out.Resets(resets); err != nil {
+		return err
+	}
+	if *dryRun {
+		t.log.WithField("group", group).Info("dry run, offsets weren't committed")
+	} else {
+		t.log.WithField("group", group).Infof("offsets of %d partitions committed", len(resets))
+	}
+	return nil
+}
+
+// group returns group given as the first argument, or the configured group id
+func (t *tool) group(fs *flag.FlagSet) string {
+	if fs.NArg() > 0 {
+		return fs.Arg(0)
+	}
+	return t.cfg.Consumer.GroupID
+}
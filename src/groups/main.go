@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"kafka-tryout/src/admin"
+	"kafka-tryout/src/config"
+	"kafka-tryout/src/security"
+	"kafka-tryout/src/utils"
+	"os"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+const usage = `usage: groups [flags] <command> [command flags] [group]
+
+group defaults to -group-id, e.g. consumer-group
+
+commands:
+  list      lists consumer groups
+  describe  describes state and members of group with their assigned partitions
+  lag       shows committed offset, high watermark and lag of group per partition, e.g. lag consumer-group currencies
+  reset     resets offsets of group on -topic to -to earliest, latest, RFC3339 time or offset, -dry-run only prints them
+
+flags:
+`
+
+func main() {
+	log := logrus.New()
+	logger := log.WithField("application", "Groups")
+
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	output := flag.String("output", outputTable, "output format, table or json")
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		logger.WithError(err).Fatal("invalid configuration")
+	}
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	p, err := newPrinter(*output, os.Stdout)
+	if err != nil {
+		logger.WithError(err).Fatal("invalid output")
+	}
+
+	sec, err := security.FromConfig(cfg)
+	if err != nil {
+		logger.WithError(err).Fatal("failed to configure connection security")
+	}
+	t := &tool{
+		log: logger,
+		groups: admin.NewGroups(&kafka.Client{
+			Addr:      kafka.TCP(cfg.Brokers...),
+			Timeout:   admin.DefaultTimeout,
+			Transport: sec.Transport(),
+		}),
+		out: p,
+		cfg: cfg,
+	}
+
+	ctx, cancel := utils.SignalContext()
+	defer cancel()
+	if err := t.run(ctx, flag.Arg(0), flag.Args()[1:]); err != nil {
+		logger.WithError(err).Fatal(flag.Arg(0) + " failed")
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"kafka-tryout/src/admin"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// printer writes results of groups commands
+type printer interface {
+	Names(names []string) error
+	Group(info admin.GroupInfo) error
+	Offsets(offsets []admin.PartitionOffset) error
+	Resets(resets []admin.OffsetReset) error
+}
+
+func newPrinter(format string, w io.Writer) (printer, error) {
+	switch format {
+	case outputTable:
+		return &tablePrinter{w: w}, nil
+	case outputJSON:
+		return &jsonPrinter{w: w}, nil
+	}
+	return nil, fmt.Errorf("unknown output %q, expected %s or %s", format, outputTable, outputJSON)
+}
+
+type tablePrinter struct {
+	w io.Writer
+}
+
+func (p *tablePrinter) Names(names []string) error {
+	for _, name := range names {
+		if _, err := fmt.Fprintln(p.w, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Group prints state of group and its members, e.g.
+// consumer-group Stable, protocol range
+// MEMBER      CLIENT  HOST       ASSIGNMENTS
+// consumer-1  rates   /10.0.0.1  currencies:0,1
+func (p *tablePrinter) Group(info admin.GroupInfo) error {
+	fmt.Fprintf(p.w, "%s %s, protocol %s\n", info.ID, info.State, info.Protocol)
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "MEMBER\tCLIENT\tHOST\tASSIGNMENTS")
+	for _, m := range info.Members {
+		topics := make([]string, 0, len(m.Assignments))
+		for topic := range m.Assignments {
+			topics = append(topics, topic)
+		}
+		sort.Strings(topics)
+		for i, topic := range topics {
+			topics[i] = topic + ":" + joinInts(m.Assignments[topic])
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", m.ID, m.ClientID, m.Host, strings.Join(topics, " "))
+	}
+	return tw.Flush()
+}
+
+// Offsets prints offsets per partition and total lag, "-" stands for partition without commit
+func (p *tablePrinter) Offsets(offsets []admin.PartitionOffset) error {
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TOPIC\tPARTITION\tCOMMITTED\tHIGH-WATERMARK\tLAG")
+	var total int64
+	for _, o := range offsets {
+		committed, lag := "-", "-"
+		if o.Committed >= 0 {
+			committed, lag = fmt.Sprint(o.Committed), fmt.Sprint(o.Lag)
+			total += o.Lag
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%d\t%s\n", o.Topic, o.Partition, committed, o.HighWatermark, lag)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(p.w, "total lag: %d\n", total)
+	return err
+}
+
+func (p *tablePrinter) Resets(resets []admin.OffsetReset) error {
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TOPIC\tPARTITION\tFROM\tTO")
+	for _, r := range resets {
+		from := "-"
+		if r.From >= 0 {
+			from = fmt.Sprint(r.From)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%d\n", r.Topic, r.Partition, from, r.To)
+	}
+	return tw.Flush()
+}
+
+type jsonPrinter struct {
+	w io.Writer
+}
+
+func (p *jsonPrinter) Names(names []string) error {
+	if names == nil {
+		names = []string{}
+	}
+	return p.encode(names)
+}
+
+func (p *jsonPrinter) Group(info admin.GroupInfo) error {
+	return p.encode(info)
+}
+
+func (p *jsonPrinter) Offsets(offsets []admin.PartitionOffset) error {
+	if offsets == nil {
+		offsets = []admin.PartitionOffset{}
+	}
+	return p.encode(offsets)
+}
+
+func (p *jsonPrinter) Resets(resets []admin.OffsetReset) error {
+	if resets == nil {
+		resets = []admin.OffsetReset{}
+	}
+	return p.encode(resets)
+}
+
+func (p *jsonPrinter) encode(v interface{}) error {
+	enc := json.NewEncoder(p.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func joinInts(ints []int) string {
+	s := make([]string, len(ints))
+	for i, n := range ints {
+		s[i] = fmt.Sprint(n)
+	}
+	return strings.Join(s, ",")
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"kafka-tryout/src/admin"
+	"strings"
+	"testing"
+)
+
+func TestTablePrinter(t *testing.T) {
+	var buf bytes.Buffer
+	p, _ := newPrinter(outputTable, &buf)
+	err := p.Offsets([]admin.PartitionOffset{
+		{Topic: "currencies", Partition: 0, Committed: 90, HighWatermark: 100, Lag: 10},
+		{Topic: "currencies", Partition: 1, Committed: 45, HighWatermark: 50, Lag: 5},
+		{Topic: "currencies", Partition: 2, Committed: -1, HighWatermark: 7, Lag: -1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 5 || strings.Join(strings.Fields(lines[3]), " ") != "currencies 2 - 7 -" || lines[4] != "total lag: 15" {
+		t.Fatalf("unexpected offsets:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	err = p.Group(admin.GroupInfo{ID: "consumer-group", State: "Stable", Protocol: "range", Members: []admin.MemberInfo{
+		{ID: "consumer-1", ClientID: "rates", Host: "/10.0.0.1", Assignments: map[string][]int{"spotify": {1}, "currencies": {0, 1}}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines = strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || lines[0] != "consumer-group Stable, protocol range" ||
+		strings.Join(strings.Fields(lines[2]), " ") != "consumer-1 rates /10.0.0.1 currencies:0,1 spotify:1" {
+		t.Fatalf("unexpected group:\n%s", buf.String())
+	}
+}
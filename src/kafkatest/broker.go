@@ -0,0 +1,277 @@
+// Package kafkatest provides in-process fake of kafka broker, it implements the subset of
+// kafka.Writer and kafka.Reader used by producers and consumers, so pipelines are tested without network
+package kafkatest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kafka-tryout/src/stream"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+var (
+	_ stream.MessageWriter = (*Writer)(nil)
+	_ stream.MessageReader = (*Reader)(nil)
+)
+
+// DefaultPartitions is the number of partitions of topics created on first write or read
+const DefaultPartitions = 3
+
+// ErrClosed is returned by closed writers and readers
+var ErrClosed = errors.New("kafkatest: closed")
+
+// Broker keeps partitioned topics and committed offsets of consumer groups in memory
+type Broker struct {
+	mu     sync.Mutex
+	topics map[string][][]kafka.Message
+	// offsets are committed offsets of group, topic and partition, i.e. offsets of the next message to read
+	offsets map[string]map[string]map[int]int64
+	// written is closed and replaced on every write, so readers wait for new messages without polling
+	written chan struct{}
+}
+
+// NewBroker creates empty Broker
+func NewBroker() *Broker {
+	return &Broker{
+		topics:  make(map[string][][]kafka.Message),
+		offsets: make(map[string]map[string]map[int]int64),
+		written: make(chan struct{}),
+	}
+}
+
+// CreateTopic creates topic with given number of partitions, it's no-op if topic exists
+func (b *Broker) CreateTopic(topic string, partitions int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.topic(topic, partitions)
+}
+
+// topic returns partitions of topic, creating it if needed, it's called with mu locked
+func (b *Broker) topic(topic string, partitions int) [][]kafka.Message {
+	t, ok := b.topics[topic]
+	if !ok {
+		t = make([][]kafka.Message, partitions)
+		b.topics[topic] = t
+	}
+	return t
+}
+
+// Messages returns messages of topic sorted by partition and offset
+func (b *Broker) Messages(topic string) []kafka.Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var msgs []kafka.Message
+	for _, p := range b.topics[topic] {
+		msgs = append(msgs, p...)
+	}
+	return msgs
+}
+
+// Topics returns names of topics sorted alphabetically
+func (b *Broker) Topics() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	names := make([]string, 0, len(b.topics))
+	for name := range b.topics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Committed returns offset committed by group for partition of topic, -1 if nothing is committed
+func (b *Broker) Committed(group, topic string, partition int) int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if offset, ok := b.offsets[group][topic][partition]; ok {
+		return offset
+	}
+	return -1
+}
+
+func (b *Broker) write(topic string, msgs []kafka.Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	t := b.topic(topic, DefaultPartitions)
+	partitions := make([]int, len(t))
+	for i := range partitions {
+		partitions[i] = i
+	}
+	// the same balancer producers use, so messages land on partitions the way they do on real broker
+	balancer := &kafka.Hash{}
+	for _, m := range msgs {
+		p := balancer.Balance(m, partitions...)
+		m.Topic = topic
+		m.Partition = p
+		m.Offset = int64(len(t[p]))
+		if m.Time.IsZero() {
+			m.Time = time.Now()
+		}
+		t[p] = append(t[p], m)
+	}
+
+	close(b.written)
+	b.written = make(chan struct{})
+	return nil
+}
+
+func (b *Broker) commit(group string, msgs []kafka.Message) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	g, ok := b.offsets[group]
+	if !ok {
+		g = make(map[string]map[int]int64)
+		b.offsets[group] = g
+	}
+	for _, m := range msgs {
+		t, ok := g[m.Topic]
+		if !ok {
+			t = make(map[int]int64)
+			g[m.Topic] = t
+		}
+		t[m.Partition] = m.Offset + 1
+	}
+}
+
+// Writer writes messages to a topic of Broker, like kafka.Writer with kafka.Hash balancer
+type Writer struct {
+	broker *Broker
+	topic  string
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// Writer returns writer of given topic
+func (b *Broker) Writer(topic string) *Writer {
+	return &Writer{broker: b, topic: topic}
+}
+
+// WriteMessages writes messages, topics of messages are ignored as kafka.Writer does
+func (w *Writer) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	w.mu.Lock()
+	closed := w.closed
+	w.mu.Unlock()
+	if closed {
+		return ErrClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return w.broker.write(w.topic, msgs)
+}
+
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	return nil
+}
+
+// Reader reads all partitions of a topic as the only member of consumer group, it starts from offsets
+// committed by the group, so new reader of the group continues where previous one committed
+type Reader struct {
+	broker *Broker
+	topic  string
+	group  string
+
+	mu        sync.Mutex
+	positions map[int]int64
+	// next is the partition checked first by the next fetch, so partitions are read evenly
+	next   int
+	closed chan struct{}
+}
+
+// Reader returns reader of given topic in consumer group
+func (b *Broker) Reader(topic, group string) *Reader {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	positions := make(map[int]int64)
+	for p, offset := range b.offsets[group][topic] {
+		positions[p] = offset
+	}
+	return &Reader{broker: b, topic: topic, group: group, positions: positions, closed: make(chan struct{})}
+}
+
+// FetchMessage returns the next message without committing it, it blocks until message is written or ctx is done
+func (r *Reader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	for {
+		r.broker.mu.Lock()
+		written := r.broker.written
+		m, ok := r.nextMessage()
+		r.broker.mu.Unlock()
+		if ok {
+			return m, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return kafka.Message{}, ctx.Err()
+		case <-r.closed:
+			return kafka.Message{}, ErrClosed
+		case <-written:
+		}
+	}
+}
+
+// nextMessage returns the first unread message, it's called with broker mu locked
+func (r *Reader) nextMessage() (kafka.Message, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t := r.broker.topic(r.topic, DefaultPartitions)
+	for i := 0; i < len(t); i++ {
+		p := (r.next + i) % len(t)
+		if pos := r.positions[p]; pos < int64(len(t[p])) {
+			r.positions[p] = pos + 1
+			r.next = p + 1
+			return t[p][pos], true
+		}
+	}
+	return kafka.Message{}, false
+}
+
+// ReadMessage fetches message and commits it right away
+func (r *Reader) ReadMessage(ctx context.Context) (kafka.Message, error) {
+	m, err := r.FetchMessage(ctx)
+	if err != nil {
+		return m, err
+	}
+	return m, r.CommitMessages(ctx, m)
+}
+
+// CommitMessages commits offsets of given messages in reader's group
+func (r *Reader) CommitMessages(_ context.Context, msgs ...kafka.Message) error {
+	select {
+	case <-r.closed:
+		return ErrClosed
+	default:
+	}
+	for _, m := range msgs {
+		if m.Topic != r.topic {
+			return fmt.Errorf("kafkatest: message of %s committed by reader of %s", m.Topic, r.topic)
+		}
+	}
+	r.broker.commit(r.group, msgs)
+	return nil
+}
+
+func (r *Reader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	select {
+	case <-r.closed:
+		return ErrClosed
+	default:
+		close(r.closed)
+	}
+	return nil
+}
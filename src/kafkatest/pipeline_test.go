@@ -0,0 +1,201 @@
+package kafkatest_test
+
+import (
+	"context"
+	"errors"
+	"kafka-tryout/src/consumer"
+	"kafka-tryout/src/kafkatest"
+	"kafka-tryout/src/producer"
+	"kafka-tryout/src/rate"
+	"kafka-tryout/src/spotify_generator"
+	spotifyproducer "kafka-tryout/src/spotify_generator/producer"
+	"kafka-tryout/src/stream"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func nullLogger() logrus.FieldLogger {
+	log, _ := test.NewNullLogger()
+	return log
+}
+
+// waitFor polls condition until it's true or timeout passes
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// allCommitted tells whether group committed every message of topic
+func allCommitted(b *kafkatest.Broker, group, topic string) bool {
+	last := make(map[int]int64)
+	for _, m := range b.Messages(topic) {
+		last[m.Partition] = m.Offset
+	}
+	for p, offset := range last {
+		if b.Committed(group, topic, p) != offset+1 {
+			return false
+		}
+	}
+	return len(last) > 0
+}
+
+// produceRates runs currency producer until it produces the test snapshot twice
+func produceRates(t *testing.T, b *kafkatest.Broker) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := producer.NewProducer(nullLogger(), b.Writer("currencies"), time.Millisecond, time.Second, 2,
+		producer.ProduceCurrenciesFn(rate.NewFileSource("../rate/testdata/latest.json"), nil, producer.RateSchemaVersion), nil)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.Run(ctx)
+	}()
+	waitFor(t, "produced rates", func() bool { return len(b.Messages("currencies")) >= 8 })
+	cancel()
+	<-done
+}
+
+func TestCurrencyPipeline(t *testing.T) {
+	b := kafkatest.NewBroker()
+	produceRates(t, b)
+
+	var (
+		mu       sync.Mutex
+		consumed []rate.SingleCurrency
+	)
+	handle := consumer.HandleCurrencies(func(_ context.Context, c rate.SingleCurrency, _ consumer.Metadata, _ logrus.FieldLogger) error {
+		mu.Lock()
+		defer mu.Unlock()
+		consumed = append(consumed, c)
+		return nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	c := consumer.NewConsumer(nullLogger(), b.Reader("currencies", "pipeline"),
+		consumer.Dispatch{Workers: 2}, consumer.DefaultBackoff, consumer.CommitPerMessage(), time.Second,
+		handle, consumer.Dedup(consumer.NewMemoryDedupStore(10)))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := c.Run(ctx); err != nil {
+			t.Error(err)
+		}
+	}()
+	waitFor(t, "committed offsets", func() bool { return allCommitted(b, "pipeline", "currencies") })
+	cancel()
+	<-done
+
+	// every snapshot was produced at least twice, duplicates are dropped by message id
+	if len(consumed) != 4 {
+		t.Fatalf("expected 4 unique rates, got %d: %+v", len(consumed), consumed)
+	}
+	for _, cur := range consumed {
+		if cur.Rate.Base != "EUR" || cur.Rate.Date != "2020-09-04" {
+			t.Fatalf("unexpected rate %+v", cur)
+		}
+	}
+}
+
+func TestCurrencyPipelineRetry(t *testing.T) {
+	b := kafkatest.NewBroker()
+	produceRates(t, b)
+
+	policy := consumer.DefaultRetryPolicy("currencies")
+	pub := consumer.NewWritersPublisher(func(topic string) stream.MessageWriter { return b.Writer(topic) })
+	handle := consumer.HandleCurrencies(func(_ context.Context, c rate.SingleCurrency, _ consumer.Metadata, _ logrus.FieldLogger) error {
+		if c.Name == "PLN" {
+			return errors.New("PLN rejected")
+		}
+		return nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	c := consumer.NewConsumer(nullLogger(), b.Reader("currencies", "pipeline"),
+		consumer.Dispatch{Workers: 2}, consumer.DefaultBackoff, consumer.CommitPerMessage(), time.Second,
+		handle, consumer.Retry(policy, pub))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := c.Run(ctx); err != nil {
+			t.Error(err)
+		}
+	}()
+	waitFor(t, "committed offsets", func() bool { return allCommitted(b, "pipeline", "currencies") })
+	cancel()
+	<-done
+
+	// rejected rates are committed in source topic and wait in the first retry tier
+	retried := b.Messages(policy.Tiers[0].Topic)
+	if len(retried) == 0 || len(retried) != len(b.Messages("currencies"))/4 {
+		t.Fatalf("expected every PLN rate in %s, got %d", policy.Tiers[0].Topic, len(retried))
+	}
+	for _, m := range retried {
+		if string(m.Key) != "PLN" {
+			t.Fatalf("unexpected retried message %s", m.Key)
+		}
+	}
+}
+
+func TestCurrencyPipelineFailure(t *testing.T) {
+	b := kafkatest.NewBroker()
+	produceRates(t, b)
+
+	handle := consumer.HandleCurrencies(func(_ context.Context, c rate.SingleCurrency, _ consumer.Metadata, _ logrus.FieldLogger) error {
+		if c.Name == "PLN" {
+			return errors.New("PLN rejected")
+		}
+		return nil
+	})
+	c := consumer.NewConsumer(nullLogger(), b.Reader("currencies", "pipeline"),
+		consumer.Dispatch{Workers: 2}, consumer.DefaultBackoff, consumer.CommitPerMessage(), time.Second, handle)
+	// without retry topics failed message stops the consumer
+	if err := c.Run(context.Background()); err == nil || !strings.Contains(err.Error(), "PLN rejected") {
+		t.Fatalf("expected error of PLN, got %v", err)
+	}
+
+	for _, m := range b.Messages("currencies") {
+		if string(m.Key) != "PLN" {
+			continue
+		}
+		if committed := b.Committed("pipeline", "currencies", m.Partition); committed > m.Offset {
+			t.Fatalf("offset %d committed over failed message %d", committed, m.Offset)
+		}
+		break
+	}
+}
+
+func TestSpotifyPipeline(t *testing.T) {
+	b := kafkatest.NewBroker()
+	client := spotifyproducer.NewKafkaClient(b.Writer("spotify"), b.Writer("currently-playing"), "spotify", "currently-playing", nullLogger(), 0,
+		spotifyproducer.BatchConfig{Size: 2, Linger: time.Hour}, time.Second, spotifyproducer.CurrentlyPlayingSchemaVersion)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	messageChan := make(chan interface{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		client.Consume(ctx, messageChan)
+	}()
+	for _, track := range []string{"first", "second", "third"} {
+		messageChan <- spotify_generator.CurrentlyPlaying{TrackName: track, PlayedAt: time.Now()}
+	}
+	messageChan <- spotify_generator.Proposition{TrackName: "proposed"}
+	cancel()
+	<-done
+
+	// partial batches are flushed on shutdown
+	if n := len(b.Messages("currently-playing")); n != 3 {
+		t.Fatalf("expected 3 currently playing messages, got %d", n)
+	}
+	if n := len(b.Messages("spotify")); n != 1 {
+		t.Fatalf("expected 1 proposition, got %d", n)
+	}
+}
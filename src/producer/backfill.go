@@ -0,0 +1,164 @@
+package producer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"kafka-tryout/src/rate"
+	"kafka-tryout/src/stream"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+type Backfiller interface {
+	// Run produces historical snapshots effective between from and to (inclusive) in date order,
+	// days completed by previous run of the same range are skipped, interrupted backfill returns ctx error
+	Run(ctx context.Context, from, to time.Time) error
+}
+
+type backfill struct {
+	w      stream.MessageWriter
+	log    logrus.FieldLogger
+	source rate.HistoricalSource
+	// progress is a file with backfilled range and date of the last produced snapshot
+	progress string
+	// version of codec.SchemaRate used for produced messages
+	version int
+}
+
+// NewBackfiller creates Backfiller encoding rates with given version of codec.SchemaRate,
+// progress file is optional and allows to resume crashed backfill
+func NewBackfiller(log logrus.FieldLogger, w stream.MessageWriter, source rate.HistoricalSource, progress string, version int) Backfiller {
+	return &backfill{
+		w:        w,
+		log:      log,
+		source:   source,
+		progress: progress,
+		version:  version,
+	}
+}
+
+// backfillProgress is the content of progress file, it's tied to the backfilled range,
+// so progress of another range doesn't skip any day
+type backfillProgress struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Last is the date of the last produced snapshot
+	Last string `json:"last"`
+}
+
+func (b *backfill) Run(ctx context.Context, from, to time.Time) error {
+	progress := backfillProgress{From: from.Format(rate.DateLayout), To: to.Format(rate.DateLayout)}
+	saved, err := b.loadProgress()
+	if err != nil {
+		return err
+	}
+	switch {
+	case saved == nil:
+	case saved.From != progress.From || saved.To != progress.To:
+		b.log.Warnf("ignoring backfill progress of %s - %s, starting from %s", saved.From, saved.To, progress.From)
+	case saved.Last != "":
+		lastDate, err := rate.ParseDate(saved.Last)
+		if err != nil {
+			return fmt.Errorf("invalid backfill progress, %w", err)
+		}
+		if next := lastDate.AddDate(0, 0, 1); next.After(from) {
+			b.log.Infof("resuming backfill after %s", saved.Last)
+			from = next
+		}
+	}
+	if from.After(to) {
+		b.log.Info("nothing to backfill")
+		return nil
+	}
+
+	snapshots, err := b.source.History(ctx, from, to)
+	if err != nil {
+		return fmt.Errorf("failed to get history, %w", err)
+	}
+
+	for _, s := range snapshots {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := s.Validate(); err != nil {
+			return fmt.Errorf("invalid snapshot of %s, %w", s.Date, err)
+		}
+		effective, err := rate.ParseDate(s.Date)
+		if err != nil {
+			return err
+		}
+
+		singles := s.Singles()
+		messages := make([]kafka.Message, 0, len(singles))
+		for _, c := range singles {
+			m, err := currencyMessage(c, b.version, 0, effective)
+			if err != nil {
+				return err
+			}
+			messages = append(messages, m)
+		}
+		// snapshots are written one by one so every partition receives them in date order
+		if err := b.w.WriteMessages(ctx, messages...); err != nil {
+			return fmt.Errorf("failed to write snapshot of %s, %w", s.Date, err)
+		}
+		progress.Last = s.Date
+		if err := b.saveProgress(progress); err != nil {
+			return err
+		}
+		b.log.Infof("backfilled %s, count: %d", s.Date, len(messages))
+	}
+	return nil
+}
+
+// loadProgress returns progress of previous run, nil if there is no progress
+func (b *backfill) loadProgress() (*backfillProgress, error) {
+	if b.progress == "" {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(b.progress)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backfill progress, %w", err)
+	}
+	var p backfillProgress
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("invalid backfill progress %s, %w", b.progress, err)
+	}
+	return &p, nil
+}
+
+// saveProgress writes progress to temporary file and renames it, so crash never leaves half written progress
+func (b *backfill) saveProgress(p backfillProgress) error {
+	if b.progress == "" {
+		return nil
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("failed to encode backfill progress, %w", err)
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(b.progress), filepath.Base(b.progress)+".tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create backfill progress file, %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save backfill progress, %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save backfill progress, %w", err)
+	}
+	if err := os.Rename(tmp.Name(), b.progress); err != nil {
+		return fmt.Errorf("failed to replace backfill progress, %w", err)
+	}
+	return nil
+}
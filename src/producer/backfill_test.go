@@ -0,0 +1,102 @@
+package producer
+
+import (
+	"context"
+	"io/ioutil"
+	"kafka-tryout/src/rate"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+// daysSource returns one snapshot per day of requested range
+type daysSource struct{}
+
+func (daysSource) History(_ context.Context, from, to time.Time) ([]*rate.Currencies, error) {
+	var snapshots []*rate.Currencies
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		snapshots = append(snapshots, &rate.Currencies{Base: "EUR", Date: day.Format(rate.DateLayout), Rates: rate.Rates{"USD": 1.18}})
+	}
+	return snapshots, nil
+}
+
+// datesWriter keeps dates of written messages, writes fail after failAfter messages when it's positive,
+// afterWrite is called after every write
+type datesWriter struct {
+	dates      []string
+	failAfter  int
+	afterWrite func()
+}
+
+func (w *datesWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	if w.failAfter > 0 && len(w.dates) >= w.failAfter {
+		return context.DeadlineExceeded
+	}
+	for _, m := range msgs {
+		w.dates = append(w.dates, m.Time.Format(rate.DateLayout))
+	}
+	if w.afterWrite != nil {
+		w.afterWrite()
+	}
+	return nil
+}
+
+func (w *datesWriter) Close() error {
+	return nil
+}
+
+func TestBackfillResume(t *testing.T) {
+	dir := t.TempDir()
+	progress := filepath.Join(dir, "backfill.progress")
+	day := func(d int) time.Time {
+		return time.Date(2020, 9, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	// crashed backfill is resumed after the last written day
+	w := &datesWriter{failAfter: 2}
+	if err := NewBackfiller(logrus.StandardLogger(), w, daysSource{}, progress, RateSchemaVersion).Run(context.Background(), day(1), day(4)); err == nil {
+		t.Fatal("expected write error")
+	}
+	w = &datesWriter{}
+	if err := NewBackfiller(logrus.StandardLogger(), w, daysSource{}, progress, RateSchemaVersion).Run(context.Background(), day(1), day(4)); err != nil {
+		t.Fatal(err)
+	}
+	if len(w.dates) != 2 || w.dates[0] != "2020-09-03" || w.dates[1] != "2020-09-04" {
+		t.Fatalf("expected resumed days 3 and 4, got %v", w.dates)
+	}
+
+	// interrupted backfill reports it and keeps its progress
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w = &datesWriter{afterWrite: cancel}
+	if err := NewBackfiller(logrus.StandardLogger(), w, daysSource{}, progress, RateSchemaVersion).Run(ctx, day(1), day(6)); err != context.Canceled {
+		t.Fatalf("expected interrupted backfill, got %v", err)
+	}
+	if len(w.dates) != 1 || w.dates[0] != "2020-09-01" {
+		t.Fatalf("expected one day before interruption, got %v", w.dates)
+	}
+
+	// progress of another range doesn't skip any day
+	w = &datesWriter{}
+	if err := NewBackfiller(logrus.StandardLogger(), w, daysSource{}, progress, RateSchemaVersion).Run(context.Background(), day(2), day(5)); err != nil {
+		t.Fatal(err)
+	}
+	if len(w.dates) != 4 || w.dates[0] != "2020-09-02" {
+		t.Fatalf("expected the whole new range, got %v", w.dates)
+	}
+
+	// the finished range is a no-op
+	w = &datesWriter{}
+	if err := NewBackfiller(logrus.StandardLogger(), w, daysSource{}, progress, RateSchemaVersion).Run(context.Background(), day(2), day(5)); err != nil {
+		t.Fatal(err)
+	}
+	if len(w.dates) != 0 {
+		t.Fatalf("expected nothing to backfill, got %v", w.dates)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Fatalf("expected only progress file, got %d files", len(files))
+	}
+}
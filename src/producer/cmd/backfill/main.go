@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"kafka-tryout/src/backend"
+	"kafka-tryout/src/codec"
+	"kafka-tryout/src/codec/schemaregistry"
+	"kafka-tryout/src/config"
+	"kafka-tryout/src/producer"
+	"kafka-tryout/src/rate"
+	"kafka-tryout/src/utils"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+func main() {
+	var (
+		from     = flag.String("from", "", "first day to backfill, YYYY-MM-DD")
+		to       = flag.String("to", time.Now().Format(rate.DateLayout), "last day to backfill, YYYY-MM-DD")
+		progress = flag.String("progress", "backfill.progress", "file keeping backfilled range and its last day, empty disables resuming")
+	)
+	log := logrus.New()
+	log.SetLevel(logrus.DebugLevel)
+	logger := log.WithField("application", "Backfill")
+
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		logger.WithError(err).Fatal("invalid configuration")
+	}
+
+	fromDate, err := rate.ParseDate(*from)
+	if err != nil {
+		logger.WithError(err).Fatal("invalid -from")
+	}
+	toDate, err := rate.ParseDate(*to)
+	if err != nil {
+		logger.WithError(err).Fatal("invalid -to")
+	}
+
+	source, err := cfg.Rates.NewSources(logger)
+	if err != nil {
+		logger.WithError(err).Fatal("failed to create rate source")
+	}
+	historical, ok := source.(rate.HistoricalSource)
+	if !ok {
+		logger.Fatal("rate source does not support history")
+	}
+
+	// the same balancer as the producer uses, so backfilled rates land on the same partitions
+	logBackend, err := backend.FromConfig(cfg)
+	if err != nil {
+		logger.WithError(err).Fatal("failed to configure backend")
+	}
+	w, err := logBackend.Writer(cfg.Topics.Currencies, &kafka.Hash{})
+	if err != nil {
+		logger.WithError(err).Fatal("failed to create writer")
+	}
+	// interrupted backfill stops between snapshots and is resumed from progress file
+	ctx, cancel := utils.SignalContext()
+	defer cancel()
+
+	// with schema registry configured, rates are backfilled in Confluent wire format
+	registry, err := schemaregistry.NewClient(http.DefaultClient, cfg.SchemaRegistry.URL, cfg.SchemaRegistry.File)
+	if err != nil {
+		logger.WithError(err).Fatal("failed to create schema registry client")
+	}
+	version := producer.RateSchemaVersion
+	if registry != nil {
+		if err := schemaregistry.RegisterDefaults(ctx, registry, codec.Default, cfg.Topics.Currencies, cfg.Topics.CurrentlyPlaying); err != nil {
+			logger.WithError(err).Fatal("failed to register schemas")
+		}
+		version = schemaregistry.RateWireVersion
+	}
+
+	b := producer.NewBackfiller(logger, w, historical, *progress, version)
+	err = b.Run(ctx, fromDate, toDate)
+	if closeErr := utils.CloseWithin(w, cfg.ShutdownTimeout); closeErr != nil {
+		logger.WithError(closeErr).Error("failed to close writer")
+	}
+	// interrupted backfill is resumed from progress file
+	if errors.Is(err, context.Canceled) {
+		logger.Fatal("backfill interrupted, progress saved")
+	}
+	if err != nil {
+		logger.WithError(err).Fatal("backfill failed")
+	}
+	logger.WithFields(logBackend.MetricsFields()).Info("backfill finished")
+}
@@ -1,11 +1,17 @@
 package main
 
 import (
-	"kafka-tryout/src/kafka_server"
+	"flag"
+	"kafka-tryout/src/backend"
+	"kafka-tryout/src/codec"
+	"kafka-tryout/src/codec/schemaregistry"
+	"kafka-tryout/src/config"
 	"kafka-tryout/src/producer"
+	"kafka-tryout/src/rate"
 	"kafka-tryout/src/utils"
+	"net/http"
+	"os"
 	"sync"
-	"time"
 
 	"github.com/segmentio/kafka-go"
 	"github.com/sirupsen/logrus"
@@ -16,24 +22,84 @@ func main() {
 	log.SetLevel(logrus.DebugLevel)
 	logger := log.WithField("application", "Producer")
 	wg := &sync.WaitGroup{}
-	finish := make(chan struct{})
-
-	w := kafka.NewWriter(kafka.WriterConfig{
-		Brokers: []string{kafka_server.Address},
-		// producer writes one message to one partition at the time, e.g. if we have 3 messages and 4 partitions
-		// it would be the output:
-		// INFO[0004] writing 1 messages to topic (partition: 0)
-		// INFO[0004] writing 1 messages to topic (partition: 2)
-		// INFO[0004] writing 1 messages to topic (partition: 1)
-		Topic: utils.EnvOrDefault("TOPIC", "currencies"),
-		//Logger:      log,
-		//ErrorLogger: log,
-		Balancer: &kafka.LeastBytes{},
-	})
-
-	cli := producer.NewProducer(logger, w, 10*time.Second, finish, wg, 10, producer.ProduceCurrenciesFn)
-	cli.Run()
+
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		logger.WithError(err).Fatal("invalid configuration")
+	}
+	ctx, cancel := utils.SignalContext()
+	defer cancel()
+
+	// with schema registry configured, rates are produced in Confluent wire format
+	registry, err := schemaregistry.NewClient(http.DefaultClient, cfg.SchemaRegistry.URL, cfg.SchemaRegistry.File)
+	if err != nil {
+		logger.WithError(err).Fatal("failed to create schema registry client")
+	}
+	version := producer.RateSchemaVersion
+	if registry != nil {
+		if err := schemaregistry.RegisterDefaults(ctx, registry, codec.Default, cfg.Topics.Currencies, cfg.Topics.CurrentlyPlaying); err != nil {
+			logger.WithError(err).Fatal("failed to register schemas")
+		}
+		version = schemaregistry.RateWireVersion
+	}
+
+	// backend kind "file" writes to local file log instead of Kafka
+	logBackend, err := backend.FromConfig(cfg)
+	if err != nil {
+		logger.WithError(err).Fatal("failed to configure backend")
+	}
+	// currency code is the message key, hashing keeps every currency on the same partition
+	w, err := logBackend.Writer(cfg.Topics.Currencies, &kafka.Hash{})
+	if err != nil {
+		logger.WithError(err).Fatal("failed to create writer")
+	}
+
+	source, err := cfg.Rates.NewSources(logger)
+	if err != nil {
+		logger.WithError(err).Fatal("failed to create rate source")
+	}
+
+	// rates are published only if they changed at least by the change threshold,
+	// state file keeps published rates between restarts, cross rates are tracked by their pairs, e.g. USD/PLN
+	detector, err := rate.NewChangeDetector(cfg.Producer.ChangeThreshold, cfg.Producer.StateFile)
+	if err != nil {
+		logger.WithError(err).Fatal("failed to create change detector")
+	}
+
+	// cross pairs enable publishing of cross rates to cross rates topic,
+	// "all" publishes full matrix, otherwise it's comma separated list of pairs, e.g. "USD/PLN,USD/JPY"
+	if pairsCfg := cfg.Producer.CrossPairs; pairsCfg != "" {
+		var pairs []rate.Pair
+		if pairsCfg != "all" {
+			if pairs, err = rate.ParsePairs(pairsCfg); err != nil {
+				logger.WithError(err).Fatal("invalid cross pairs")
+			}
+		}
+		crossTopic := cfg.Topics.CrossRates
+		// pair is the message key, e.g. USD/PLN
+		crossW, err := logBackend.Writer(crossTopic, &kafka.Hash{})
+		if err != nil {
+			logger.WithError(err).Fatal("failed to create cross rates writer")
+		}
+		crossCli := producer.NewProducer(logger.WithField("topic", crossTopic), crossW, cfg.Producer.Interval, cfg.ShutdownTimeout,
+			cfg.Producer.Goroutines, producer.ProduceCrossRatesFn(source, pairs, detector, version), producer.AckCurrenciesFn(detector))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			crossCli.Run(ctx)
+			if err := utils.CloseWithin(crossW, cfg.ShutdownTimeout); err != nil {
+				logger.WithError(err).Error("failed to close cross rates writer")
+			}
+		}()
+	}
+
+	cli := producer.NewProducer(logger, w, cfg.Producer.Interval, cfg.ShutdownTimeout, cfg.Producer.Goroutines,
+		producer.ProduceCurrenciesFn(source, detector, version), producer.AckCurrenciesFn(detector))
+	cli.Run(ctx)
+	if err := utils.CloseWithin(w, cfg.ShutdownTimeout); err != nil {
+		logger.WithError(err).Error("failed to close writer")
+	}
 
 	wg.Wait()
-	logger.Info("closing")
+	logger.WithFields(logBackend.MetricsFields()).Info("closing")
 }
@@ -1,81 +1,134 @@
 package producer
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
+	"kafka-tryout/src/codec"
 	"kafka-tryout/src/rate"
-	"net/http"
-	"reflect"
 	"strconv"
 	"time"
 
 	"github.com/segmentio/kafka-go"
 )
 
-func ProduceCurrenciesFn(goroutineCount int) (Messages, error) {
-	curr, err := getCurrencies()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get currencies, %w", err)
+// RateSchemaVersion is the version of codec.SchemaRate produced without schema registry,
+// with schema registry commands produce schemaregistry.RateWireVersion
+const RateSchemaVersion = 2
+
+// ProduceCurrenciesFn returns produceFn which fetches the latest rates from given source and encodes them
+// with given version of codec.SchemaRate, if detector is given only changed rates are produced
+func ProduceCurrenciesFn(source rate.RateSource, detector *rate.ChangeDetector, version int) produceFn {
+	return func(goroutineCount int) (Messages, error) {
+		curr, err := source.Latest(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get currencies, %w", err)
+		}
+		if err := curr.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid currencies, %w", err)
+		}
+		return currenciesMessages(divideCurrencies(curr.Singles(), goroutineCount), detector, version), nil
+	}
+}
+
+// ProduceCrossRatesFn returns produceFn which derives given pairs (or the full matrix when pairs are empty)
+// from the latest snapshot, messages are keyed by pair, e.g. "USD/PLN", if detector is given only changed pairs are produced
+func ProduceCrossRatesFn(source rate.RateSource, pairs []rate.Pair, detector *rate.ChangeDetector, version int) produceFn {
+	return func(goroutineCount int) (Messages, error) {
+		curr, err := source.Latest(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get currencies, %w", err)
+		}
+		if err := curr.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid currencies, %w", err)
+		}
+		cross, err := rate.CrossRates(curr, pairs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive cross rates, %w", err)
+		}
+		return currenciesMessages(divideCurrencies(cross, goroutineCount), detector, version), nil
 	}
+}
 
-	// divided currencies for specific goroutines
-	divided := divideCurrencies(curr, goroutineCount)
+// AckCurrenciesFn returns ackFn which marks written rates as published in detector
+func AckCurrenciesFn(detector *rate.ChangeDetector) ackFn {
+	return func(messages []kafka.Message) error {
+		published := make([]rate.SingleCurrency, 0, len(messages))
+		for _, m := range messages {
+			v, _, err := codec.Default.Decode(m)
+			if err != nil {
+				return fmt.Errorf("failed to decode rate, %w", err)
+			}
+			r, ok := v.(*rate.Rate)
+			if !ok {
+				return fmt.Errorf("expected rate, got %T", v)
+			}
+			published = append(published, rate.SingleCurrency{Name: string(m.Key), Rate: *r})
+		}
+		return detector.Mark(published...)
+	}
+}
 
+// currenciesMessages creates messages for divided currencies, unchanged ones are skipped if detector is given
+func currenciesMessages(divided [][]rate.SingleCurrency, detector *rate.ChangeDetector, version int) Messages {
 	messages := make([][]kafka.Message, 0, len(divided))
 
 	for i, div := range divided {
 		m := make([]kafka.Message, 0, len(div))
 		for _, d := range div {
-			// marshal currency rate
-			value, err := json.Marshal(d.Rate)
+			if detector != nil && !detector.Changed(d) {
+				continue
+			}
+			msg, err := currencyMessage(d, version, i, time.Now())
 			if err != nil {
 				continue
 			}
-			m = append(m, kafka.Message{
-				Key:   []byte(d.Name),
-				Value: value,
-				Headers: []kafka.Header{
-					{
-						Key:   "goroutine",
-						Value: []byte(strconv.Itoa(i)),
-					},
-				},
-				Time: time.Now(),
-			})
+			m = append(m, msg)
 		}
 		messages = append(messages, m)
 	}
-	return messages, nil
+	return messages
 }
 
-func getCurrencies() (*rate.Currencies, error) {
-	resp, err := http.Get(apiURL)
+// currencyMessage creates message keyed by currency name with rate encoded by given version of codec.SchemaRate,
+// message id is derived from currency, date and rate, so consumers can drop replayed rates
+func currencyMessage(c rate.SingleCurrency, version, goroutine int, t time.Time) (kafka.Message, error) {
+	value, headers, err := codec.Default.Encode(codec.SchemaRate, version, c.Rate)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get data, %w", err)
-	}
-	var curr rate.Currencies
-	if err := json.NewDecoder(resp.Body).Decode(&curr); err != nil {
-		return nil, fmt.Errorf("failed to decode currencies, %w", err)
+		return kafka.Message{}, fmt.Errorf("failed to encode rate, %w", err)
 	}
-	return &curr, nil
+	return kafka.Message{
+		Key:   []byte(c.Name),
+		Value: value,
+		Headers: append([]kafka.Header{
+			{
+				Key:   "goroutine",
+				Value: []byte(strconv.Itoa(goroutine)),
+			},
+			{
+				Key:   codec.HeaderMessageID,
+				Value: []byte(c.ID()),
+			},
+		}, headers...),
+		Time: t,
+	}, nil
 }
 
-// divideCurrencies divides currencies into chunks for each goroutine
+// divideCurrencies divides currencies into chunks for each goroutine, currency is assigned
+// by hash of its name, the same way kafka.Hash balancer assigns partitions,
+// so given currency always lands in the same chunk regardless of other currencies in the snapshot
 // e.g.
-// 1,2,3,4,5,6,7 for 3 goroutines would be -> [[1,4,7], [2,5], [3,6]]
-func divideCurrencies(c *rate.Currencies, goroutinesCount int) [][]rate.SingleCurrency {
-	val := reflect.Indirect(reflect.ValueOf(c.Rates))
+// USD,PLN,JPY,GBP for 3 goroutines could be -> [[GBP,USD], [JPY], [PLN]]
+func divideCurrencies(currencies []rate.SingleCurrency, goroutinesCount int) [][]rate.SingleCurrency {
 	divided := make([][]rate.SingleCurrency, goroutinesCount)
+	chunks := make([]int, goroutinesCount)
+	for i := range chunks {
+		chunks[i] = i
+	}
+	balancer := &kafka.Hash{}
 
-	for i := 0; i < val.NumField(); i++ {
-		divided[i%goroutinesCount] = append(divided[i%goroutinesCount], rate.SingleCurrency{
-			Name: val.Type().Field(i).Name,
-			Rate: rate.Rate{
-				Base: c.Base,
-				Rate: val.Field(i).Float(),
-				Date: c.Date,
-			},
-		})
+	for _, c := range currencies {
+		i := balancer.Balance(kafka.Message{Key: []byte(c.Name)}, chunks...)
+		divided[i] = append(divided[i], c)
 	}
 	return divided
 }
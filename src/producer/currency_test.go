@@ -0,0 +1,123 @@
+package producer
+
+import (
+	"context"
+	"kafka-tryout/src/codec"
+	"kafka-tryout/src/rate"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func chunkOf(divided [][]rate.SingleCurrency) map[string]int {
+	chunks := make(map[string]int)
+	for i, div := range divided {
+		for _, d := range div {
+			chunks[d.Name] = i
+		}
+	}
+	return chunks
+}
+
+func TestDivideCurrencies(t *testing.T) {
+	c := &rate.Currencies{
+		Base:  "EUR",
+		Date:  "2020-09-04",
+		Rates: rate.Rates{"USD": 1.18, "PLN": 4.42, "JPY": 125.55, "GBP": 0.89, "CHF": 1.08},
+	}
+	divided := divideCurrencies(c.Singles(), 3)
+	if !reflect.DeepEqual(divided, divideCurrencies(c.Singles(), 3)) {
+		t.Fatal("division should be deterministic")
+	}
+	chunks := chunkOf(divided)
+	if len(chunks) != len(c.Rates) {
+		t.Fatalf("expected %d currencies, got %d", len(c.Rates), len(chunks))
+	}
+
+	// adding or removing currencies must not move the other ones
+	delete(c.Rates, "GBP")
+	c.Rates["SEK"] = 10.4
+	for code, chunk := range chunkOf(divideCurrencies(c.Singles(), 3)) {
+		if prev, ok := chunks[code]; ok && prev != chunk {
+			t.Fatalf("%s moved from chunk %d to %d", code, prev, chunk)
+		}
+	}
+}
+
+func TestCurrencyMessageID(t *testing.T) {
+	c := rate.SingleCurrency{Name: "USD", Rate: rate.Rate{Base: "EUR", Rate: 1.18, Date: "2020-09-04"}}
+	first, err := currencyMessage(c, RateSchemaVersion, 0, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the same rate produced later by another goroutine, e.g. during backfill
+	again, err := currencyMessage(c, RateSchemaVersion, 3, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ := codec.Header(first, codec.HeaderMessageID)
+	if againID, _ := codec.Header(again, codec.HeaderMessageID); id == "" || id != againID {
+		t.Fatalf("expected the same message id, got %q and %q", id, againID)
+	}
+	c.Rate.Date = "2020-09-07"
+	next, err := currencyMessage(c, RateSchemaVersion, 0, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nextID, _ := codec.Header(next, codec.HeaderMessageID); nextID == id {
+		t.Fatal("rates of different days should have different ids")
+	}
+
+	// corrected rate of the same day isn't dropped as duplicate
+	c.Rate.Date, c.Rate.Rate = "2020-09-04", 1.19
+	corrected, err := currencyMessage(c, RateSchemaVersion, 0, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if correctedID, _ := codec.Header(corrected, codec.HeaderMessageID); correctedID == id {
+		t.Fatal("corrected rate should have a new id")
+	}
+}
+
+type staticSource struct {
+	curr *rate.Currencies
+}
+
+func (s staticSource) Latest(context.Context) (*rate.Currencies, error) {
+	return s.curr, nil
+}
+
+func TestProduceCrossRatesUnchanged(t *testing.T) {
+	detector, err := rate.NewChangeDetector(0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := staticSource{&rate.Currencies{Base: "EUR", Date: "2020-09-04", Rates: rate.Rates{"USD": 1.18, "PLN": 4.42}}}
+	produce, ack := ProduceCrossRatesFn(source, nil, detector, RateSchemaVersion), AckCurrenciesFn(detector)
+
+	count := func() int {
+		messages, err := produce(2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		for _, m := range messages {
+			n += len(m)
+			if err := ack(m); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return n
+	}
+	if n := count(); n == 0 {
+		t.Fatal("expected cross rates of the first snapshot")
+	}
+	// the same snapshot doesn't republish the matrix
+	if n := count(); n != 0 {
+		t.Fatalf("expected no unchanged cross rates, got %d", n)
+	}
+	source.curr.Rates["PLN"] = 4.5
+	if n := count(); n == 0 {
+		t.Fatal("expected changed cross rates")
+	}
+}
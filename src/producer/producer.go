@@ -3,6 +3,8 @@ package producer
 import (
 	"context"
 	"fmt"
+	"kafka-tryout/src/stream"
+	"kafka-tryout/src/utils"
 	"sync"
 	"time"
 
@@ -10,11 +12,11 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-const apiURL = "https://api.ratesapi.io/api/latest"
-
 // produceFn takes number of goroutines and returns array of messages for each gouroutine
+// ackFn is called with chunk of messages after it was successfully written
 type (
 	produceFn func(int) (Messages, error)
+	ackFn     func([]kafka.Message) error
 	Messages  [][]kafka.Message
 )
 
@@ -27,67 +29,80 @@ func (m Messages) Len() int {
 }
 
 type Producer interface {
-	// Run runs producer, it produces messages constantly
-	Run()
+	// Run runs producer, it produces messages constantly until ctx is done,
+	// messages being written by then are given shutdown timeout to finish
+	Run(ctx context.Context)
 }
 
 type handler struct {
-	w            *kafka.Writer
-	log          logrus.FieldLogger
-	sleep        time.Duration
-	finish       chan struct{}
-	wg           *sync.WaitGroup
-	goroutines   int
-	produceMsgFn produceFn
+	w               stream.MessageWriter
+	log             logrus.FieldLogger
+	sleep           time.Duration
+	shutdownTimeout time.Duration
+	goroutines      int
+	produceMsgFn    produceFn
+	ackMsgFn        ackFn
 }
 
-func NewProducer(log logrus.FieldLogger, w *kafka.Writer, sleep time.Duration,
-	finish chan struct{}, wg *sync.WaitGroup, goroutines int, fn produceFn) Producer {
+// NewProducer creates Producer, ack is optional and may be nil
+func NewProducer(log logrus.FieldLogger, w stream.MessageWriter, sleep, shutdownTimeout time.Duration,
+	goroutines int, fn produceFn, ack ackFn) Producer {
 
 	return &handler{
-		w:            w,
-		log:          log,
-		sleep:        sleep,
-		finish:       finish,
-		wg:           wg,
-		goroutines:   goroutines,
-		produceMsgFn: fn,
+		w:               w,
+		log:             log,
+		sleep:           sleep,
+		shutdownTimeout: shutdownTimeout,
+		goroutines:      goroutines,
+		produceMsgFn:    fn,
+		ackMsgFn:        ack,
 	}
 }
 
-func (h *handler) Run() {
+func (h *handler) Run(ctx context.Context) {
+	// writes started before shutdown are not cancelled right away
+	drain, cancel := utils.WithShutdownDeadline(ctx, h.shutdownTimeout)
+	defer cancel()
+
 	for {
 		select {
-		case <-h.finish:
+		case <-ctx.Done():
+			h.log.Info("graceful shutdown")
 			return
 		case <-time.After(h.sleep):
-			if err := h.handleProducedMessages(h.produceMsgFn); err != nil {
+			if err := h.handleProducedMessages(drain, h.produceMsgFn); err != nil {
 				h.log.WithError(err).Error("failed to handle produced messages")
 			}
 		}
 	}
 }
 
-func (h *handler) handleProducedMessages(fn produceFn) error {
+func (h *handler) handleProducedMessages(ctx context.Context, fn produceFn) error {
 	messages, err := fn(h.goroutines)
 	if err != nil {
 		return fmt.Errorf("failed to produce messages, %w", err)
 	}
-	ctx := context.Background()
+	wg := &sync.WaitGroup{}
 	for i, chunk := range messages {
-		h.wg.Add(1)
+		wg.Add(1)
 		// create goroutine to write chunk of messages
 		go func(chunk []kafka.Message, log logrus.FieldLogger) {
 			log.Info("start handling messages")
 
+			defer wg.Done()
 			if err := h.w.WriteMessages(ctx, chunk...); err != nil {
 				log.WithError(err).Error("failed to write messages")
+				return
+			}
+			if h.ackMsgFn != nil {
+				if err := h.ackMsgFn(chunk); err != nil {
+					log.WithError(err).Error("failed to acknowledge messages")
+				}
 			}
-			h.wg.Done()
 
 		}(chunk, h.log.WithField("goroutine", i))
 	}
-	h.wg.Wait()
+	wg.Wait()
 	h.log.Info("messages handled, count: ", messages.Len())
 	return nil
 }
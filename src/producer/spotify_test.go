@@ -1,9 +1,14 @@
 package producer
 
 import (
+	"os"
 	"testing"
 )
 
+// TestProduceSpotifyFn calls live Spotify API, so it runs only with SPOTIFY_LIVE_TEST set
 func TestProduceSpotifyFn(t *testing.T) {
+	if os.Getenv("SPOTIFY_LIVE_TEST") == "" {
+		t.Skip("SPOTIFY_LIVE_TEST is not set")
+	}
 	ProduceSpotifyFn(10)
 }
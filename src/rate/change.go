@@ -0,0 +1,108 @@
+package rate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ChangeDetector remembers last published Rate of every currency and tells
+// whether a new one is worth publishing
+type ChangeDetector struct {
+	mu sync.Mutex
+	// threshold is minimal relative change of rate, e.g. 0.001 means 0.1%
+	threshold float64
+	// path of the state file, state is kept in memory only if empty
+	path string
+	last map[string]Rate
+}
+
+// NewChangeDetector creates ChangeDetector, if path is given previously persisted state is loaded from it
+func NewChangeDetector(threshold float64, path string) (*ChangeDetector, error) {
+	if threshold < 0 {
+		return nil, fmt.Errorf("threshold must not be negative, got %v", threshold)
+	}
+	d := &ChangeDetector{
+		threshold: threshold,
+		path:      path,
+		last:      make(map[string]Rate),
+	}
+	if path == "" {
+		return d, nil
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return d, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rates state, %w", err)
+	}
+	if err := json.Unmarshal(b, &d.last); err != nil {
+		return nil, fmt.Errorf("failed to decode rates state, %w", err)
+	}
+	return d, nil
+}
+
+// Changed reports whether given currency differs from the last published one:
+// it is newer or its rate moved at least by threshold, rates older than published ones are never changed
+func (d *ChangeDetector) Changed(c SingleCurrency) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	last, ok := d.last[c.Name]
+	if !ok || last.Base != c.Rate.Base {
+		return true
+	}
+	// dates are in YYYY-MM-DD format so they can be compared as strings
+	if c.Rate.Date != last.Date {
+		return c.Rate.Date > last.Date
+	}
+	if c.Rate.Rate == last.Rate {
+		return false
+	}
+	return math.Abs(c.Rate.Rate-last.Rate)/last.Rate >= d.threshold
+}
+
+// Mark remembers given currencies as published and persists state if detector has a file
+func (d *ChangeDetector) Mark(currencies ...SingleCurrency) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, c := range currencies {
+		d.last[c.Name] = c.Rate
+	}
+	if d.path == "" {
+		return nil
+	}
+	return d.save()
+}
+
+// save writes state to temporary file and renames it, so crash never leaves half written state
+func (d *ChangeDetector) save() error {
+	b, err := json.Marshal(d.last)
+	if err != nil {
+		return fmt.Errorf("failed to encode rates state, %w", err)
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(d.path), filepath.Base(d.path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create rates state file, %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write rates state, %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write rates state, %w", err)
+	}
+	if err := os.Rename(tmp.Name(), d.path); err != nil {
+		return fmt.Errorf("failed to replace rates state, %w", err)
+	}
+	return nil
+}
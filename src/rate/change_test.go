@@ -0,0 +1,49 @@
+package rate
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestChangeDetector(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	d, err := NewChangeDetector(0.01, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	usd := SingleCurrency{Name: "USD", Rate: Rate{Base: "EUR", Rate: 1.18, Date: "2020-09-04"}}
+	if !d.Changed(usd) {
+		t.Fatal("unseen currency should be changed")
+	}
+	if err := d.Mark(usd); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		rate    Rate
+		changed bool
+	}{
+		{"same", Rate{Base: "EUR", Rate: 1.18, Date: "2020-09-04"}, false},
+		{"below threshold", Rate{Base: "EUR", Rate: 1.185, Date: "2020-09-04"}, false},
+		{"above threshold", Rate{Base: "EUR", Rate: 1.2, Date: "2020-09-04"}, true},
+		{"new date", Rate{Base: "EUR", Rate: 1.18, Date: "2020-09-07"}, true},
+		{"stale date", Rate{Base: "EUR", Rate: 1.3, Date: "2020-09-03"}, false},
+		{"other base", Rate{Base: "USD", Rate: 1.18, Date: "2020-09-04"}, true},
+	}
+	for _, tt := range tests {
+		if got := d.Changed(SingleCurrency{Name: "USD", Rate: tt.rate}); got != tt.changed {
+			t.Errorf("%s: expected changed=%v, got %v", tt.name, tt.changed, got)
+		}
+	}
+
+	// state survives restart
+	restored, err := NewChangeDetector(0.01, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Changed(usd) {
+		t.Fatal("restored detector should remember published rate")
+	}
+}
@@ -0,0 +1,126 @@
+package rate
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Pair of currencies, its rate tells how many units of Quote one unit of Base is worth
+type Pair struct {
+	Base, Quote string
+}
+
+func (p Pair) String() string {
+	return p.Base + "/" + p.Quote
+}
+
+// ParsePair parses pair in "USD/PLN" format
+func ParsePair(s string) (Pair, error) {
+	parts := strings.Split(strings.ToUpper(strings.TrimSpace(s)), "/")
+	if len(parts) != 2 {
+		return Pair{}, fmt.Errorf("invalid pair %q, expected BASE/QUOTE", s)
+	}
+	p := Pair{Base: parts[0], Quote: parts[1]}
+	if !ValidCode(p.Base) || !ValidCode(p.Quote) {
+		return Pair{}, fmt.Errorf("invalid pair %q, unknown currency", s)
+	}
+	if p.Base == p.Quote {
+		return Pair{}, fmt.Errorf("invalid pair %q, same currencies", s)
+	}
+	return p, nil
+}
+
+// ParsePairs parses comma separated list of pairs, e.g. "USD/PLN,USD/JPY"
+func ParsePairs(s string) ([]Pair, error) {
+	var pairs []Pair
+	for _, part := range strings.Split(s, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		p, err := ParsePair(part)
+		if err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, p)
+	}
+	return pairs, nil
+}
+
+// rateOf returns rate of given currency against snapshot's base, base itself is always 1
+func (c *Currencies) rateOf(code string) (float64, error) {
+	if code == c.Base {
+		return 1, nil
+	}
+	r, ok := c.Rates[code]
+	if !ok {
+		return 0, fmt.Errorf("no rate of %s in snapshot based on %s", code, c.Base)
+	}
+	return r, nil
+}
+
+// CrossRate derives rate of given pair from the snapshot
+func (c *Currencies) CrossRate(p Pair) (float64, error) {
+	base, err := c.rateOf(p.Base)
+	if err != nil {
+		return 0, err
+	}
+	quote, err := c.rateOf(p.Quote)
+	if err != nil {
+		return 0, err
+	}
+	return quote / base, nil
+}
+
+// Rebase returns snapshot with rates expressed against given currency
+func (c *Currencies) Rebase(base string) (*Currencies, error) {
+	base = strings.ToUpper(base)
+	if _, err := c.rateOf(base); err != nil {
+		return nil, err
+	}
+	rebased := &Currencies{Base: base, Date: c.Date, Rates: make(Rates, len(c.Rates))}
+	for _, code := range append(c.Rates.Codes(), c.Base) {
+		if code == base {
+			continue
+		}
+		r, err := c.CrossRate(Pair{Base: base, Quote: code})
+		if err != nil {
+			return nil, err
+		}
+		rebased.Rates[code] = r
+	}
+	return rebased, nil
+}
+
+// Pairs returns every pair of currencies available in the snapshot (N×N without identities)
+func (c *Currencies) Pairs() []Pair {
+	codes := append(c.Rates.Codes(), c.Base)
+	pairs := make([]Pair, 0, len(codes)*(len(codes)-1))
+	for _, base := range codes {
+		for _, quote := range codes {
+			if base != quote {
+				pairs = append(pairs, Pair{Base: base, Quote: quote})
+			}
+		}
+	}
+	return pairs
+}
+
+// CrossRates derives rates of given pairs, full matrix is derived when pairs are empty,
+// Name of each returned currency is the pair, e.g. "USD/PLN", and Rate.Base is the pair's base
+func CrossRates(c *Currencies, pairs []Pair) ([]SingleCurrency, error) {
+	if len(pairs) == 0 {
+		pairs = c.Pairs()
+	}
+	rates := make([]SingleCurrency, 0, len(pairs))
+	for _, p := range pairs {
+		r, err := c.CrossRate(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive %s, %w", p, err)
+		}
+		rates = append(rates, SingleCurrency{
+			Name: p.String(),
+			Rate: Rate{Base: p.Base, Rate: r, Date: c.Date},
+		})
+	}
+	return rates, nil
+}
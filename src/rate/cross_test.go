@@ -0,0 +1,64 @@
+package rate
+
+import (
+	"math"
+	"testing"
+)
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestCrossRates(t *testing.T) {
+	c := &Currencies{Base: "EUR", Date: "2020-09-04", Rates: Rates{"USD": 1.2, "PLN": 4.5, "JPY": 126}}
+
+	pairs, err := ParsePairs("usd/pln, PLN/EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cross, err := CrossRates(c, pairs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cross[0].Name != "USD/PLN" || cross[0].Rate.Base != "USD" || !almostEqual(cross[0].Rate.Rate, 3.75) {
+		t.Fatalf("unexpected USD/PLN: %+v", cross[0])
+	}
+	if cross[1].Name != "PLN/EUR" || !almostEqual(cross[1].Rate.Rate, 1/4.5) {
+		t.Fatalf("unexpected PLN/EUR: %+v", cross[1])
+	}
+
+	matrix, err := CrossRates(c, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matrix) != 4*3 {
+		t.Fatalf("expected full matrix of 12 pairs, got %d", len(matrix))
+	}
+
+	if _, err := CrossRates(c, []Pair{{Base: "USD", Quote: "GBP"}}); err == nil {
+		t.Fatal("expected error for missing currency")
+	}
+	for _, invalid := range []string{"USD", "USD/USD", "USD/ABC"} {
+		if _, err := ParsePair(invalid); err == nil {
+			t.Errorf("expected error for %q", invalid)
+		}
+	}
+}
+
+func TestRebase(t *testing.T) {
+	c := &Currencies{Base: "EUR", Date: "2020-09-04", Rates: Rates{"USD": 1.2, "PLN": 4.5}}
+
+	rebased, err := c.Rebase("usd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rebased.Base != "USD" || len(rebased.Rates) != 2 {
+		t.Fatalf("unexpected rebased snapshot: %+v", rebased)
+	}
+	if !almostEqual(rebased.Rates["EUR"], 1/1.2) || !almostEqual(rebased.Rates["PLN"], 3.75) {
+		t.Fatalf("unexpected rebased rates: %+v", rebased.Rates)
+	}
+	if err := rebased.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,119 @@
+package rate
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	ECBDailyURL = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-daily.xml"
+	// ECBHistoryURL contains every reference rate since 1999
+	ECBHistoryURL = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-hist.xml"
+	ecbBase       = "EUR"
+)
+
+// ecbEnvelope reflects the structure of ECB reference rates feed:
+// <gesmes:Envelope><Cube><Cube time="..."><Cube currency="USD" rate="1.18"/>...</Cube></Cube></gesmes:Envelope>
+type ecbEnvelope struct {
+	Days []struct {
+		Time  string `xml:"time,attr"`
+		Rates []struct {
+			Currency string  `xml:"currency,attr"`
+			Rate     float64 `xml:"rate,attr"`
+		} `xml:"Cube"`
+	} `xml:"Cube>Cube"`
+}
+
+type ecbSource struct {
+	client     *http.Client
+	url        string
+	historyURL string
+}
+
+// NewECBSource returns RateSource reading European Central Bank daily reference rates,
+// ECBDailyURL and ECBHistoryURL are used when urls are empty
+func NewECBSource(client *http.Client, url, historyURL string) RateSource {
+	if url == "" {
+		url = ECBDailyURL
+	}
+	if historyURL == "" {
+		historyURL = ECBHistoryURL
+	}
+	return &ecbSource{client: client, url: url, historyURL: historyURL}
+}
+
+func (e *ecbSource) Latest(ctx context.Context) (*Currencies, error) {
+	body, err := get(ctx, e.client, e.url)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return decodeECB(body)
+}
+
+func (e *ecbSource) History(ctx context.Context, from, to time.Time) ([]*Currencies, error) {
+	body, err := get(ctx, e.client, e.historyURL)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	days, err := decodeECBDays(body)
+	if err != nil {
+		return nil, err
+	}
+	return between(days, from, to)
+}
+
+// decodeECB decodes the latest day of the feed
+func decodeECB(r io.Reader) (*Currencies, error) {
+	days, err := decodeECBDays(r)
+	if err != nil {
+		return nil, err
+	}
+	// daily feed contains exactly one day, the first one is the latest in historical feeds
+	return days[0], nil
+}
+
+// decodeECBDays decodes every day of the feed
+func decodeECBDays(r io.Reader) ([]*Currencies, error) {
+	var env ecbEnvelope
+	if err := xml.NewDecoder(r).Decode(&env); err != nil {
+		return nil, fmt.Errorf("failed to decode ecb feed, %w", err)
+	}
+	if len(env.Days) == 0 {
+		return nil, fmt.Errorf("ecb feed contains no rates")
+	}
+	days := make([]*Currencies, 0, len(env.Days))
+	for _, day := range env.Days {
+		curr := &Currencies{Base: ecbBase, Date: day.Time, Rates: make(Rates, len(day.Rates))}
+		for _, r := range day.Rates {
+			if err := curr.Rates.Set(r.Currency, r.Rate); err != nil {
+				return nil, fmt.Errorf("failed to decode ecb feed, %w", err)
+			}
+		}
+		days = append(days, curr)
+	}
+	return days, nil
+}
+
+// get performs GET request and returns body of successful response
+func get(ctx context.Context, client *http.Client, url string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request, %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get data, %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status from %s: %s", url, resp.Status)
+	}
+	return resp.Body, nil
+}
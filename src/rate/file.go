@@ -0,0 +1,67 @@
+package rate
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type fileSource struct {
+	path string
+	log  logrus.FieldLogger
+}
+
+// NewFileSource returns RateSource reading local fixture, ".xml" files are read as ECB feed,
+// everything else as JSON in DefaultJSONMapping format, JSON file may contain an array of snapshots
+func NewFileSource(path string) RateSource {
+	return &fileSource{path: path, log: logrus.StandardLogger()}
+}
+
+func (f *fileSource) Latest(_ context.Context) (*Currencies, error) {
+	snapshots, err := f.read()
+	if err != nil {
+		return nil, err
+	}
+	latest := snapshots[0]
+	for _, s := range snapshots[1:] {
+		if s.Date > latest.Date {
+			latest = s
+		}
+	}
+	return latest, nil
+}
+
+func (f *fileSource) History(_ context.Context, from, to time.Time) ([]*Currencies, error) {
+	snapshots, err := f.read()
+	if err != nil {
+		return nil, err
+	}
+	return between(snapshots, from, to)
+}
+
+func (f *fileSource) read() ([]*Currencies, error) {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open rates file, %w", err)
+	}
+	defer file.Close()
+
+	var snapshots []*Currencies
+	if strings.EqualFold(filepath.Ext(f.path), ".xml") {
+		snapshots, err = decodeECBDays(file)
+	} else {
+		snapshots, err = decodeJSONDocs(file, DefaultJSONMapping, f.log)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(snapshots) == 0 {
+		return nil, fmt.Errorf("rates file %s is empty", f.path)
+	}
+	return snapshots, nil
+}
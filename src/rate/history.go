@@ -0,0 +1,66 @@
+package rate
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DateLayout is the format of Currencies.Date
+const DateLayout = "2006-01-02"
+
+// HistoricalSource provides snapshots of past currency rates
+type HistoricalSource interface {
+	// History returns snapshots effective between from and to (inclusive), sorted by date
+	History(ctx context.Context, from, to time.Time) ([]*Currencies, error)
+}
+
+// ParseDate parses date in DateLayout format
+func ParseDate(date string) (time.Time, error) {
+	t, err := time.Parse(DateLayout, date)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, %w", date, err)
+	}
+	return t, nil
+}
+
+// between returns snapshots effective between from and to (inclusive) sorted by date,
+// only the first snapshot of a day is kept
+func between(snapshots []*Currencies, from, to time.Time) ([]*Currencies, error) {
+	fromDate, toDate := from.Format(DateLayout), to.Format(DateLayout)
+	seen := make(map[string]struct{}, len(snapshots))
+	filtered := make([]*Currencies, 0, len(snapshots))
+	for _, s := range snapshots {
+		if _, err := ParseDate(s.Date); err != nil {
+			return nil, err
+		}
+		if _, ok := seen[s.Date]; ok || s.Date < fromDate || s.Date > toDate {
+			continue
+		}
+		seen[s.Date] = struct{}{}
+		filtered = append(filtered, s)
+	}
+	sort.Slice(filtered, func(i, j int) bool { return filtered[i].Date < filtered[j].Date })
+	return filtered, nil
+}
+
+func (f *fallbackSource) History(ctx context.Context, from, to time.Time) ([]*Currencies, error) {
+	var errs []string
+	for i, src := range f.sources {
+		hist, ok := src.(HistoricalSource)
+		if !ok {
+			continue
+		}
+		snapshots, err := hist.History(ctx, from, to)
+		if err == nil {
+			return snapshots, nil
+		}
+		errs = append(errs, fmt.Sprintf("source %d: %s", i, err))
+	}
+	if len(errs) == 0 {
+		return nil, fmt.Errorf("none of rate sources supports history")
+	}
+	return nil, fmt.Errorf("all rate sources failed: %s", strings.Join(errs, "; "))
+}
@@ -0,0 +1,32 @@
+package rate
+
+import "strings"
+
+// iso4217 contains active ISO 4217 currency codes, together with a few recently
+// withdrawn ones which are still published by rate providers (e.g. HRK)
+var iso4217 = toSet(`
+AED AFN ALL AMD ANG AOA ARS AUD AWG AZN BAM BBD BDT BGN BHD BIF BMD BND BOB BOV
+BRL BSD BTN BWP BYN BZD CAD CDF CHE CHF CHW CLF CLP CNY COP COU CRC CUC CUP CVE
+CZK DJF DKK DOP DZD EGP ERN ETB EUR FJD FKP GBP GEL GHS GIP GMD GNF GTQ GYD HKD
+HNL HRK HTG HUF IDR ILS INR IQD IRR ISK JMD JOD JPY KES KGS KHR KMF KPW KRW KWD
+KYD KZT LAK LBP LKR LRD LSL LYD MAD MDL MGA MKD MMK MNT MOP MRU MUR MVR MWK MXN
+MXV MYR MZN NAD NGN NIO NOK NPR NZD OMR PAB PEN PGK PHP PKR PLN PYG QAR RON RSD
+RUB RWF SAR SBD SCR SDG SEK SGD SHP SLE SLL SOS SRD SSP STN SVC SYP SZL THB TJS
+TMT TND TOP TRY TTD TWD TZS UAH UGX USD USN UYI UYU UYW UZS VED VES VND VUV WST
+XAF XAG XAU XBA XBB XBC XBD XCD XDR XOF XPD XPF XPT XSU XTS XUA XXX YER ZAR ZMW
+ZWL
+`)
+
+func toSet(codes string) map[string]struct{} {
+	set := make(map[string]struct{})
+	for _, code := range strings.Fields(codes) {
+		set[code] = struct{}{}
+	}
+	return set
+}
+
+// ValidCode reports whether code is known ISO 4217 currency code
+func ValidCode(code string) bool {
+	_, ok := iso4217[code]
+	return ok
+}
@@ -0,0 +1,183 @@
+package rate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// JSONMapping tells where base, date and rates live in JSON document,
+// nested fields are separated with dots, e.g. "data.rates"
+type JSONMapping struct {
+	Base  string
+	Date  string
+	Rates string
+	// FixedBase is used when document does not contain base currency
+	FixedBase string
+}
+
+// DefaultJSONMapping matches ratesapi.io/exchangeratesapi.io responses:
+// {"base": "EUR", "date": "2020-09-04", "rates": {"USD": 1.18, ...}}
+var DefaultJSONMapping = JSONMapping{
+	Base:  "base",
+	Date:  "date",
+	Rates: "rates",
+}
+
+const (
+	DefaultJSONURL = "https://api.exchangerate.host/latest"
+	// DefaultJSONHistoryURL is a template, DatePlaceholder is replaced with requested day
+	DefaultJSONHistoryURL = "https://api.exchangerate.host/" + DatePlaceholder
+	DatePlaceholder       = "{date}"
+)
+
+type jsonSource struct {
+	client     *http.Client
+	url        string
+	historyURL string
+	mapping    JSONMapping
+	log        logrus.FieldLogger
+}
+
+// NewJSONSource returns RateSource reading generic JSON endpoint,
+// fields of the response are found with given mapping, empty mapping fields are taken from DefaultJSONMapping,
+// historyURL is queried for every day with DatePlaceholder replaced by the date, currencies unknown to ISO 4217,
+// e.g. BTC, are skipped with a warning logged to log
+func NewJSONSource(log logrus.FieldLogger, client *http.Client, url, historyURL string, mapping JSONMapping) RateSource {
+	if url == "" {
+		url = DefaultJSONURL
+	}
+	if historyURL == "" {
+		historyURL = DefaultJSONHistoryURL
+	}
+	if mapping.Base == "" {
+		mapping.Base = DefaultJSONMapping.Base
+	}
+	if mapping.Date == "" {
+		mapping.Date = DefaultJSONMapping.Date
+	}
+	if mapping.Rates == "" {
+		mapping.Rates = DefaultJSONMapping.Rates
+	}
+	return &jsonSource{client: client, url: url, historyURL: historyURL, mapping: mapping, log: log}
+}
+
+func (j *jsonSource) Latest(ctx context.Context) (*Currencies, error) {
+	body, err := get(ctx, j.client, j.url)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return decodeJSON(body, j.mapping, j.log)
+}
+
+// History queries endpoint day by day, endpoints usually answer with the last business day
+// for weekends and holidays, such duplicates are dropped
+func (j *jsonSource) History(ctx context.Context, from, to time.Time) ([]*Currencies, error) {
+	var snapshots []*Currencies
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		url := strings.Replace(j.historyURL, DatePlaceholder, day.Format(DateLayout), -1)
+		body, err := get(ctx, j.client, url)
+		if err != nil {
+			return nil, err
+		}
+		curr, err := decodeJSON(body, j.mapping, j.log)
+		body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s, %w", day.Format(DateLayout), err)
+		}
+		snapshots = append(snapshots, curr)
+	}
+	return between(snapshots, from, to)
+}
+
+func decodeJSON(r io.Reader, mapping JSONMapping, log logrus.FieldLogger) (*Currencies, error) {
+	var doc map[string]interface{}
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to decode currencies, %w", err)
+	}
+	return decodeJSONDoc(doc, mapping, log)
+}
+
+// decodeJSONDocs decodes either single document or an array of documents
+func decodeJSONDocs(r io.Reader, mapping JSONMapping, log logrus.FieldLogger) ([]*Currencies, error) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("failed to decode currencies, %w", err)
+	}
+	var docs []map[string]interface{}
+	if err := json.Unmarshal(raw, &docs); err != nil {
+		var doc map[string]interface{}
+		if err := json.Unmarshal(raw, &doc); err != nil {
+			return nil, fmt.Errorf("failed to decode currencies, %w", err)
+		}
+		docs = append(docs, doc)
+	}
+
+	snapshots := make([]*Currencies, 0, len(docs))
+	for _, doc := range docs {
+		curr, err := decodeJSONDoc(doc, mapping, log)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, curr)
+	}
+	return snapshots, nil
+}
+
+// decodeJSONDoc decodes single snapshot, unknown currencies are skipped so one of them doesn't fail the whole snapshot
+func decodeJSONDoc(doc map[string]interface{}, mapping JSONMapping, log logrus.FieldLogger) (*Currencies, error) {
+	curr := &Currencies{Base: mapping.FixedBase, Rates: make(Rates)}
+	if curr.Base == "" {
+		base, ok := lookup(doc, mapping.Base).(string)
+		if !ok {
+			return nil, fmt.Errorf("base not found under %q", mapping.Base)
+		}
+		curr.Base = base
+	}
+	date, ok := lookup(doc, mapping.Date).(string)
+	if !ok {
+		return nil, fmt.Errorf("date not found under %q", mapping.Date)
+	}
+	curr.Date = date
+
+	rates, ok := lookup(doc, mapping.Rates).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("rates not found under %q", mapping.Rates)
+	}
+	for code, v := range rates {
+		value, ok := v.(float64)
+		if !ok {
+			return nil, fmt.Errorf("rate of %s is not a number", code)
+		}
+		err := curr.Rates.Set(code, value)
+		if errors.Is(err, ErrUnknownCurrency) {
+			log.WithField("date", curr.Date).Warnf("skipping unknown currency %s", code)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return curr, nil
+}
+
+// lookup walks through doc using dotted path, returns nil if path does not exist
+func lookup(doc map[string]interface{}, path string) interface{} {
+	var current interface{} = doc
+	for _, key := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = m[key]
+	}
+	return current
+}
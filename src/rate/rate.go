@@ -1,5 +1,13 @@
 package rate
 
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
 // TODO: refactor, separate package
 type Currencies struct {
 	Base  string `json:"base"`
@@ -7,39 +15,68 @@ type Currencies struct {
 	Date  string `json:"date"`
 }
 
-type Rates struct {
-	GBP float64 `json:"GBP"`
-	HKD float64 `json:"HKD"`
-	IDR float64 `json:"IDR"`
-	ILS float64 `json:"ILS"`
-	DKK float64 `json:"DKK"`
-	INR float64 `json:"INR"`
-	CHF float64 `json:"CHF"`
-	MXN float64 `json:"MXN"`
-	CZK float64 `json:"CZK"`
-	SGD float64 `json:"SGD"`
-	THB float64 `json:"THB"`
-	HRK float64 `json:"HRK"`
-	MYR float64 `json:"MYR"`
-	NOK float64 `json:"NOK"`
-	CNY float64 `json:"CNY"`
-	BGN float64 `json:"BGN"`
-	PHP float64 `json:"PHP"`
-	SEK float64 `json:"SEK"`
-	PLN float64 `json:"PLN"`
-	ZAR float64 `json:"ZAR"`
-	CAD float64 `json:"CAD"`
-	ISK float64 `json:"ISK"`
-	BRL float64 `json:"BRL"`
-	RON float64 `json:"RON"`
-	NZD float64 `json:"NZD"`
-	TRY float64 `json:"TRY"`
-	JPY float64 `json:"JPY"`
-	RUB float64 `json:"RUB"`
-	KRW float64 `json:"KRW"`
-	USD float64 `json:"USD"`
-	HUF float64 `json:"HUF"`
-	AUD float64 `json:"AUD"`
+// ErrUnknownCurrency is returned for codes which aren't ISO 4217 currencies, e.g. BTC
+var ErrUnknownCurrency = errors.New("unknown currency code")
+
+// Rates maps ISO 4217 currency code to its rate against base currency
+type Rates map[string]float64
+
+// Set validates given currency code and sets its rate
+func (r Rates) Set(code string, value float64) error {
+	code = strings.ToUpper(code)
+	if !ValidCode(code) {
+		return fmt.Errorf("%w: %q", ErrUnknownCurrency, code)
+	}
+	if value <= 0 {
+		return fmt.Errorf("invalid rate of %s: %v", code, value)
+	}
+	r[code] = value
+	return nil
+}
+
+// Codes returns sorted currency codes
+func (r Rates) Codes() []string {
+	codes := make([]string, 0, len(r))
+	for code := range r {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// Singles splits snapshot into separate currencies sorted by code
+func (c *Currencies) Singles() []SingleCurrency {
+	singles := make([]SingleCurrency, 0, len(c.Rates))
+	for _, code := range c.Rates.Codes() {
+		singles = append(singles, SingleCurrency{
+			Name: code,
+			Rate: Rate{
+				Base: c.Base,
+				Rate: c.Rates[code],
+				Date: c.Date,
+			},
+		})
+	}
+	return singles
+}
+
+// Validate checks base currency and every rate of the snapshot
+func (c *Currencies) Validate() error {
+	if !ValidCode(c.Base) {
+		return fmt.Errorf("invalid base currency: %q", c.Base)
+	}
+	if len(c.Rates) == 0 {
+		return fmt.Errorf("no rates for base %s", c.Base)
+	}
+	for _, code := range c.Rates.Codes() {
+		if !ValidCode(code) {
+			return fmt.Errorf("invalid currency code: %q", code)
+		}
+		if c.Rates[code] <= 0 {
+			return fmt.Errorf("invalid rate of %s: %v", code, c.Rates[code])
+		}
+	}
+	return nil
 }
 
 type SingleCurrency struct {
@@ -47,6 +84,12 @@ type SingleCurrency struct {
 	Rate Rate
 }
 
+// ID identifies rate of the currency on given day, e.g. "EUR:USD:2020-09-04:1.18",
+// the same rate produced again, e.g. by backfill, has the same id, corrected rate of the day has a new one
+func (c SingleCurrency) ID() string {
+	return c.Rate.Base + ":" + c.Name + ":" + c.Rate.Date + ":" + strconv.FormatFloat(c.Rate.Rate, 'g', -1, 64)
+}
+
 type Rate struct {
 	Base string
 	Rate float64
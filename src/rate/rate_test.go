@@ -0,0 +1,33 @@
+package rate
+
+import "testing"
+
+func TestRatesSet(t *testing.T) {
+	r := make(Rates)
+	if err := r.Set("usd", 1.18); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r["USD"] != 1.18 {
+		t.Fatalf("code should be normalized, got %v", r)
+	}
+	if err := r.Set("XYZ", 1); err == nil {
+		t.Fatal("expected error for unknown code")
+	}
+	if err := r.Set("PLN", 0); err == nil {
+		t.Fatal("expected error for non positive rate")
+	}
+}
+
+func TestCurrenciesValidate(t *testing.T) {
+	c := &Currencies{Base: "EUR", Date: "2020-09-04", Rates: Rates{"USD": 1.18, "PLN": 4.42}}
+	if err := c.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c.Rates["ABC"] = 1
+	if err := c.Validate(); err == nil {
+		t.Fatal("expected error for invalid code")
+	}
+	if err := (&Currencies{Base: "EU"}).Validate(); err == nil {
+		t.Fatal("expected error for invalid base")
+	}
+}
@@ -0,0 +1,118 @@
+package rate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	SourceECB  = "ecb"
+	SourceJSON = "json"
+	SourceFile = "file"
+
+	_defaultTimeout = 10 * time.Second
+)
+
+// RateSource provides the latest snapshot of currency rates,
+// every source built by NewSource implements HistoricalSource as well
+type RateSource interface {
+	// Latest returns the most recent rates published by the source
+	Latest(ctx context.Context) (*Currencies, error)
+}
+
+// SourceConfig describes which RateSource should be used and how to build it
+type SourceConfig struct {
+	// Kind is one of SourceECB, SourceJSON, SourceFile
+	Kind string
+	// URL of the upstream, used by SourceECB and SourceJSON, defaults are used when empty
+	URL string
+	// HistoryURL of the upstream's historical data, SourceJSON expects DatePlaceholder in it
+	HistoryURL string
+	// Path of the fixture file, used by SourceFile
+	Path string
+	// Mapping tells SourceJSON where to look for the fields in the response
+	Mapping JSONMapping
+
+	Client *http.Client
+	// Log receives warnings, e.g. about skipped currencies, standard logger is used when it's nil
+	Log logrus.FieldLogger
+}
+
+// NewSource builds RateSource for given config
+func NewSource(c SourceConfig) (RateSource, error) {
+	client := c.Client
+	if client == nil {
+		client = &http.Client{Timeout: _defaultTimeout}
+	}
+	log := c.Log
+	if log == nil {
+		log = logrus.StandardLogger()
+	}
+
+	switch strings.ToLower(c.Kind) {
+	case SourceECB:
+		return NewECBSource(client, c.URL, c.HistoryURL), nil
+	case SourceJSON:
+		return NewJSONSource(log, client, c.URL, c.HistoryURL, c.Mapping), nil
+	case SourceFile:
+		if c.Path == "" {
+			return nil, errors.New("file source requires path")
+		}
+		return &fileSource{path: c.Path, log: log}, nil
+	}
+	return nil, fmt.Errorf("unknown rate source: %q", c.Kind)
+}
+
+// NewSources builds RateSource for every given kind with config returned by configOf and chains them with fallback,
+// e.g. "ecb,file" would read from ECB feed and fall back to the local file
+func NewSources(kinds string, configOf func(kind string) SourceConfig) (RateSource, error) {
+	var sources []RateSource
+	for _, kind := range strings.Split(kinds, ",") {
+		kind = strings.ToLower(strings.TrimSpace(kind))
+		if kind == "" {
+			continue
+		}
+		c := configOf(kind)
+		c.Kind = kind
+		src, err := NewSource(c)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, src)
+	}
+	if len(sources) == 0 {
+		return nil, errors.New("no rate source configured")
+	}
+	if len(sources) == 1 {
+		return sources[0], nil
+	}
+	return NewFallbackSource(sources...), nil
+}
+
+type fallbackSource struct {
+	sources []RateSource
+}
+
+// NewFallbackSource returns RateSource which asks given sources in order
+// and returns the first successful snapshot
+func NewFallbackSource(sources ...RateSource) RateSource {
+	return &fallbackSource{sources: sources}
+}
+
+func (f *fallbackSource) Latest(ctx context.Context) (*Currencies, error) {
+	errs := make([]string, 0, len(f.sources))
+	for i, src := range f.sources {
+		curr, err := src.Latest(ctx)
+		if err == nil {
+			return curr, nil
+		}
+		errs = append(errs, fmt.Sprintf("source %d: %s", i, err))
+	}
+	return nil, fmt.Errorf("all rate sources failed: %s", strings.Join(errs, "; "))
+}
@@ -0,0 +1,131 @@
+package rate
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func serveFile(t *testing.T, path string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, path)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func assertSnapshot(t *testing.T, curr *Currencies, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if curr.Base != "EUR" || curr.Date != "2020-09-04" {
+		t.Fatalf("unexpected base/date: %s/%s", curr.Base, curr.Date)
+	}
+	if curr.Rates["USD"] != 1.1823 || curr.Rates["PLN"] != 4.4275 || curr.Rates["JPY"] != 125.55 {
+		t.Fatalf("unexpected rates: %+v", curr.Rates)
+	}
+}
+
+func TestECBSource(t *testing.T) {
+	srv := serveFile(t, "testdata/eurofxref-daily.xml")
+
+	curr, err := NewECBSource(srv.Client(), srv.URL, "").Latest(context.Background())
+	assertSnapshot(t, curr, err)
+}
+
+func TestJSONSource(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":{"day":"2020-09-04","quotes":{"USD":1.1823,"JPY":125.55,"PLN":4.4275,"BTC":0.0001}}}`))
+	}))
+	defer srv.Close()
+
+	// currencies unknown to ISO 4217 are skipped instead of failing the snapshot
+	src := NewJSONSource(logrus.StandardLogger(), srv.Client(), srv.URL, "", JSONMapping{
+		Date:      "data.day",
+		Rates:     "data.quotes",
+		FixedBase: "EUR",
+	})
+	curr, err := src.Latest(context.Background())
+	assertSnapshot(t, curr, err)
+	if _, ok := curr.Rates["BTC"]; ok || len(curr.Rates) != 3 {
+		t.Fatalf("expected BTC to be skipped, got %+v", curr.Rates)
+	}
+}
+
+func TestFileSource(t *testing.T) {
+	for _, path := range []string{"testdata/latest.json", "testdata/eurofxref-daily.xml"} {
+		curr, err := NewFileSource(path).Latest(context.Background())
+		assertSnapshot(t, curr, err)
+	}
+}
+
+func TestFallbackSource(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	src, err := NewSources("json,file", func(string) SourceConfig {
+		return SourceConfig{URL: down.URL, Path: "testdata/latest.json", Client: down.Client()}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	curr, err := src.Latest(context.Background())
+	assertSnapshot(t, curr, err)
+
+	// every source is asked at its own URL
+	up := serveFile(t, "testdata/latest.json")
+	urls := map[string]string{SourceECB: down.URL, SourceJSON: up.URL}
+	src, err = NewSources("ECB, json", func(kind string) SourceConfig {
+		return SourceConfig{URL: urls[kind], Client: up.Client()}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	curr, err = src.Latest(context.Background())
+	assertSnapshot(t, curr, err)
+
+	if _, err := NewSource(SourceConfig{Kind: "unknown"}); err == nil {
+		t.Fatal("expected error for unknown source")
+	}
+}
+
+func TestHistory(t *testing.T) {
+	from, _ := ParseDate("2020-09-03")
+	to, _ := ParseDate("2020-09-06")
+
+	srv := serveFile(t, "testdata/eurofxref-hist.xml")
+	ecb := NewECBSource(srv.Client(), "", srv.URL).(HistoricalSource)
+	// the same day is returned for weekends, it must be dropped
+	jsonSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		date := strings.TrimPrefix(r.URL.Path, "/")
+		if date > "2020-09-04" {
+			date = "2020-09-04"
+		}
+		fmt.Fprintf(w, `{"base":"EUR","date":%q,"rates":{"USD":1.18}}`, date)
+	}))
+	defer jsonSrv.Close()
+
+	sources := map[string]HistoricalSource{
+		"ecb":  ecb,
+		"json": NewJSONSource(logrus.StandardLogger(), jsonSrv.Client(), "", jsonSrv.URL+"/"+DatePlaceholder, JSONMapping{}).(HistoricalSource),
+		"file": NewFileSource("testdata/eurofxref-hist.xml").(HistoricalSource),
+	}
+	for name, src := range sources {
+		snapshots, err := src.History(context.Background(), from, to)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		if len(snapshots) != 2 || snapshots[0].Date != "2020-09-03" || snapshots[1].Date != "2020-09-04" {
+			t.Fatalf("%s: expected 2020-09-03 and 2020-09-04 in order, got %+v", name, snapshots)
+		}
+	}
+}
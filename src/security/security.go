@@ -0,0 +1,110 @@
+// Package security builds TLS and SASL settings of Kafka clients from configuration,
+// so every command connects to secured clusters the same way
+package security
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"kafka-tryout/src/config"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+// DefaultDialTimeout limits connecting to broker, including TLS handshake and SASL authentication
+const DefaultDialTimeout = 10 * time.Second
+
+// Security is TLS and SASL setting of connections, zero value connects plaintext without authentication
+type Security struct {
+	TLS  *tls.Config
+	SASL sasl.Mechanism
+}
+
+// FromConfig loads certificates and builds SASL mechanism of configuration
+func FromConfig(c config.Config) (Security, error) {
+	tlsConfig, err := TLSConfig(c.TLS)
+	if err != nil {
+		return Security{}, err
+	}
+	mechanism, err := Mechanism(c.SASL)
+	if err != nil {
+		return Security{}, err
+	}
+	return Security{TLS: tlsConfig, SASL: mechanism}, nil
+}
+
+// TLSConfig returns nil if TLS is disabled
+func TLSConfig(c config.TLS) (*tls.Config, error) {
+	if !c.Enabled {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+	if c.CAFile != "" {
+		pem, err := ioutil.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file, %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in CA file %s", c.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate, %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// Mechanism returns nil if SASL is disabled
+func Mechanism(c config.SASL) (sasl.Mechanism, error) {
+	switch c.Mechanism {
+	case "":
+		return nil, nil
+	case config.SASLPlain:
+		return plain.Mechanism{Username: c.Username, Password: c.Password}, nil
+	case config.SASLScramSHA256, config.SASLScramSHA512:
+		algo := scram.SHA256
+		if c.Mechanism == config.SASLScramSHA512 {
+			algo = scram.SHA512
+		}
+		m, err := scram.Mechanism(algo, c.Username, c.Password)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s mechanism, %w", c.Mechanism, err)
+		}
+		return m, nil
+	}
+	return nil, errors.New("unknown SASL mechanism " + c.Mechanism)
+}
+
+// Dialer returns dialer for readers, writers and connections
+func (s Security) Dialer() *kafka.Dialer {
+	return &kafka.Dialer{
+		Timeout:       DefaultDialTimeout,
+		DualStack:     true,
+		TLS:           s.TLS,
+		SASLMechanism: s.SASL,
+	}
+}
+
+// Transport returns transport for kafka.Client
+func (s Security) Transport() *kafka.Transport {
+	return &kafka.Transport{
+		DialTimeout: DefaultDialTimeout,
+		TLS:         s.TLS,
+		SASL:        s.SASL,
+	}
+}
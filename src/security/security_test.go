@@ -0,0 +1,181 @@
+package security
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"kafka-tryout/src/config"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// certificate is a key pair signed by parent, or self-signed CA if parent is nil
+type certificate struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func newCertificate(t *testing.T, name string, parent *certificate) *certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA, template.BasicConstraintsValid = true, true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &certificate{cert: cert, key: key, der: der}
+}
+
+// write saves certificate and key as PEM files in dir and returns their paths
+func (c *certificate) write(t *testing.T, dir, name string) (string, string) {
+	t.Helper()
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPath, keyPath := filepath.Join(dir, name+".pem"), filepath.Join(dir, name+"-key.pem")
+	for path, block := range map[string]*pem.Block{
+		certPath: {Type: "CERTIFICATE", Bytes: c.der},
+		keyPath:  {Type: "EC PRIVATE KEY", Bytes: keyDER},
+	} {
+		if err := ioutil.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return certPath, keyPath
+}
+
+// listenTLS accepts connections of clients signed by ca and reports their common names
+func listenTLS(t *testing.T, ca, server *certificate) (string, <-chan string) {
+	t.Helper()
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{server.der}, PrivateKey: server.key}},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	clients := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			tlsConn := conn.(*tls.Conn)
+			if err := tlsConn.Handshake(); err == nil {
+				clients <- tlsConn.ConnectionState().PeerCertificates[0].Subject.CommonName
+			}
+			conn.Close()
+		}
+	}()
+	return l.Addr().String(), clients
+}
+
+func TestDialerTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "security")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := newCertificate(t, "ca", nil)
+	caPath, _ := ca.write(t, dir, "ca")
+	certPath, keyPath := newCertificate(t, "producer", ca).write(t, dir, "client")
+	addr, clients := listenTLS(t, ca, newCertificate(t, "broker", ca))
+
+	sec, err := FromConfig(config.Config{TLS: config.TLS{Enabled: true, CAFile: caPath, CertFile: certPath, KeyFile: keyPath}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := sec.Dialer().DialContext(ctx, "tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	select {
+	case name := <-clients:
+		if name != "producer" {
+			t.Fatalf("broker saw unexpected client %s", name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("broker didn't accept client certificate")
+	}
+
+	// broker signed by other CA isn't trusted
+	other := newCertificate(t, "other", nil)
+	otherAddr, _ := listenTLS(t, other, newCertificate(t, "broker", other))
+	if _, err := sec.Dialer().DialContext(ctx, "tcp", otherAddr); err == nil {
+		t.Fatal("expected unknown authority error")
+	}
+}
+
+func TestMechanism(t *testing.T) {
+	for mechanism, name := range map[string]string{
+		config.SASLPlain:       "PLAIN",
+		config.SASLScramSHA256: "SCRAM-SHA-256",
+		config.SASLScramSHA512: "SCRAM-SHA-512",
+	} {
+		m, err := Mechanism(config.SASL{Mechanism: mechanism, Username: "rates", Password: "secret"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Name() != name {
+			t.Fatalf("expected %s, got %s", name, m.Name())
+		}
+		_, first, err := m.Start(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(first), "rates") {
+			t.Fatalf("%s: first message doesn't carry user, got %q", name, first)
+		}
+	}
+
+	if m, err := Mechanism(config.SASL{}); m != nil || err != nil {
+		t.Fatalf("empty mechanism should disable SASL, got %v, %v", m, err)
+	}
+	if _, err := Mechanism(config.SASL{Mechanism: "gssapi"}); err == nil {
+		t.Fatal("expected unknown mechanism error")
+	}
+}
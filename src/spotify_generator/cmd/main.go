@@ -8,17 +8,20 @@
 package main
 
 import (
-	"context"
+	"flag"
 	"fmt"
-	"kafka-tryout/src/kafka_server"
+	"kafka-tryout/src/backend"
+	"kafka-tryout/src/codec"
+	"kafka-tryout/src/codec/schemaregistry"
+	"kafka-tryout/src/config"
 	"kafka-tryout/src/spotify_generator/generator"
 	"kafka-tryout/src/spotify_generator/producer"
+	"kafka-tryout/src/stream"
+	"kafka-tryout/src/utils"
 	"log"
 	"net/http"
 	"os"
-	"os/signal"
 	"sync"
-	"syscall"
 
 	"github.com/segmentio/kafka-go"
 
@@ -38,6 +41,11 @@ var (
 )
 
 func main() {
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// first start an HTTP server
 	http.HandleFunc("/callback", completeAuth)
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
@@ -60,57 +68,67 @@ func main() {
 	logger := logrus.New()
 	logger.SetLevel(logrus.DebugLevel)
 
-	spotifyW := kafka.NewWriter(kafka.WriterConfig{
-		Brokers: []string{kafka_server.Address},
-		// producer writes one message to one partition at the time, e.g. if we have 3 messages and 4 partitions
-		// it would be the output:
-		// INFO[0004] writing 1 messages to topic (partition: 0)
-		// INFO[0004] writing 1 messages to topic (partition: 2)
-		// INFO[0004] writing 1 messages to topic (partition: 1)
-		Topic: "spotify",
-		//Logger:      log,
-		//ErrorLogger: log,
-		Balancer: &kafka.LeastBytes{},
-	})
+	// backend kind "file" writes to local file log instead of Kafka
+	logBackend, err := backend.FromConfig(cfg)
+	if err != nil {
+		logger.WithError(err).Fatal("failed to configure backend")
+	}
+	// producer writes one message to one partition at the time, e.g. if we have 3 messages and 4 partitions
+	// it would be the output:
+	// INFO[0004] writing 1 messages to topic (partition: 0)
+	// INFO[0004] writing 1 messages to topic (partition: 2)
+	// INFO[0004] writing 1 messages to topic (partition: 1)
+	spotifyW, err := logBackend.Writer(cfg.Topics.Spotify, &kafka.LeastBytes{})
+	if err != nil {
+		logger.WithError(err).Fatal("failed to create spotify writer")
+	}
+	currW, err := logBackend.Writer(cfg.Topics.CurrentlyPlaying, &kafka.LeastBytes{})
+	if err != nil {
+		logger.WithError(err).Fatal("failed to create currently playing writer")
+	}
 
-	currW := kafka.NewWriter(kafka.WriterConfig{
-		Brokers: []string{kafka_server.Address},
-		// producer writes one message to one partition at the time, e.g. if we have 3 messages and 4 partitions
-		// it would be the output:
-		// INFO[0004] writing 1 messages to topic (partition: 0)
-		// INFO[0004] writing 1 messages to topic (partition: 2)
-		// INFO[0004] writing 1 messages to topic (partition: 1)
-		Topic: "currently-playing",
-		//Logger:      log,
-		//ErrorLogger: log,
-		Balancer: &kafka.LeastBytes{},
-	})
+	ctx, cancel := utils.SignalContext()
+	defer cancel()
 
-	finish := make(chan struct{})
-	signalChannel := make(chan os.Signal, 2)
-	signal.Notify(signalChannel, os.Interrupt, syscall.SIGTERM)
-	go func() {
-		<-signalChannel
-		close(finish)
-	}()
+	// with schema registry configured, currently playing tracks are produced in Confluent wire format
+	registry, err := schemaregistry.NewClient(http.DefaultClient, cfg.SchemaRegistry.URL, cfg.SchemaRegistry.File)
+	if err != nil {
+		logger.WithError(err).Fatal("failed to create schema registry client")
+	}
+	version := producer.CurrentlyPlayingSchemaVersion
+	if registry != nil {
+		if err := schemaregistry.RegisterDefaults(ctx, registry, codec.Default, cfg.Topics.Currencies, cfg.Topics.CurrentlyPlaying); err != nil {
+			logger.WithError(err).Fatal("failed to register schemas")
+		}
+		version = schemaregistry.CurrentlyPlayingWireVersion
+	}
 
 	var (
-		goroutinesCount = 1
+		goroutinesCount = cfg.Spotify.Goroutines
 		messageChan     = make(chan interface{}, goroutinesCount)
+		batch           = producer.BatchConfig{Size: cfg.Spotify.BatchSize, Bytes: cfg.Spotify.BatchBytes, Linger: cfg.Spotify.BatchLinger}
 	)
 
-	cli := generator.NewClient(logger, client, user.ID, goroutinesCount, finish)
-	//cli.StartGettingPropositions(messageChan)
-	cli.StartGettingCurrentlyPlaying(messageChan)
+	cli := generator.NewClient(logger, client, user.ID, goroutinesCount)
+	//cli.StartGettingPropositions(ctx, messageChan)
+	cli.StartGettingCurrentlyPlaying(ctx, messageChan)
 
-	ctx := context.Background()
 	wg := sync.WaitGroup{}
 	for i := 0; i < 2*goroutinesCount; i++ {
-		pr := producer.NewKafkaClient(spotifyW, currW, logger.WithField("goR", i), ctx, i, 5, finish, &wg)
-		pr.Consume(messageChan)
+		pr := producer.NewKafkaClient(spotifyW, currW, cfg.Topics.Spotify, cfg.Topics.CurrentlyPlaying, logger.WithField("goR", i), i, batch, cfg.ShutdownTimeout, version)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pr.Consume(ctx, messageChan)
+		}()
 	}
 	wg.Wait()
-	logger.Info("spotify generator finished")
+	for _, w := range []stream.MessageWriter{spotifyW, currW} {
+		if err := utils.CloseWithin(w, cfg.ShutdownTimeout); err != nil {
+			logger.WithError(err).Error("failed to close writer")
+		}
+	}
+	logger.WithFields(logBackend.MetricsFields()).Info("spotify generator finished")
 }
 
 func completeAuth(w http.ResponseWriter, r *http.Request) {
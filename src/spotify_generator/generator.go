@@ -17,7 +17,8 @@ type Meta struct {
 
 // Proposition keeps info of found proposition for user
 type Proposition struct {
-	Meta
+	// Meta is encoded as nested object, otherwise its TrackName would be shadowed
+	Meta      `json:"meta"`
 	TrackName string
 	Artists   []string
 	Album     string
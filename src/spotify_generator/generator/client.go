@@ -42,11 +42,9 @@ type Client struct {
 
 	propOpts *propositionOptions
 	currOpts *currentlyPlayingOptions
-
-	finish chan struct{}
 }
 
-func NewClient(log logrus.FieldLogger, client *spotify.Client, userID string, goroutinesCount int, finish chan struct{}) *Client {
+func NewClient(log logrus.FieldLogger, client *spotify.Client, userID string, goroutinesCount int) *Client {
 	return &Client{
 		userID: userID,
 		client: client,
@@ -66,7 +64,5 @@ func NewClient(log logrus.FieldLogger, client *spotify.Client, userID string, go
 		currOpts: &currentlyPlayingOptions{
 			limit: 10,
 		},
-
-		finish: finish,
 	}
 }
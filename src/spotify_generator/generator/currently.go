@@ -1,19 +1,21 @@
 package generator
 
 import (
+	"context"
 	"kafka-tryout/src/spotify_generator"
 	"time"
 
 	"github.com/zmb3/spotify"
 )
 
-func (c *Client) StartGettingCurrentlyPlaying(messageChan chan interface{}) {
+// StartGettingCurrentlyPlaying start getting recently played tracks periodically, until ctx is done
+func (c *Client) StartGettingCurrentlyPlaying(ctx context.Context, messageChan chan interface{}) {
 	go func() {
 		for {
 			select {
 			case <-time.After(5 * time.Second):
-				c.getCurrentlyPlaying(messageChan)
-			case <-c.finish:
+				c.getCurrentlyPlaying(ctx, messageChan)
+			case <-ctx.Done():
 				c.log.Info("start method finished")
 				return
 			}
@@ -21,7 +23,7 @@ func (c *Client) StartGettingCurrentlyPlaying(messageChan chan interface{}) {
 	}()
 }
 
-func (c *Client) getCurrentlyPlaying(messageChan chan interface{}) {
+func (c *Client) getCurrentlyPlaying(ctx context.Context, messageChan chan interface{}) {
 	log := c.log.WithField("method", "getCurrentlyPlaying")
 	items, err := c.client.PlayerRecentlyPlayedOpt(&spotify.RecentlyPlayedOptions{
 		Limit:         50,
@@ -41,12 +43,17 @@ func (c *Client) getCurrentlyPlaying(messageChan chan interface{}) {
 			continue
 		}
 
-		messageChan <- spotify_generator.CurrentlyPlaying{
+		cp := spotify_generator.CurrentlyPlaying{
 			PlayedAt:   item.PlayedAt,
 			Artists:    convertArtists(artists),
 			TrackName:  item.Track.Name,
 			DurationMs: item.Track.Duration,
 		}
+		select {
+		case messageChan <- cp:
+		case <-ctx.Done():
+			return
+		}
 	}
 
 }
@@ -1,6 +1,7 @@
 package generator
 
 import (
+	"context"
 	"kafka-tryout/src/spotify_generator"
 	"time"
 
@@ -8,14 +9,14 @@ import (
 	"github.com/zmb3/spotify"
 )
 
-// StartGettingPropositions start getting proposition periodically
-func (c *Client) StartGettingPropositions(messageChan chan interface{}) {
+// StartGettingPropositions start getting proposition periodically, until ctx is done
+func (c *Client) StartGettingPropositions(ctx context.Context, messageChan chan interface{}) {
 	go func() {
 		for {
 			select {
 			case <-time.After(5 * time.Second):
-				c.getPropositions(messageChan)
-			case <-c.finish:
+				c.getPropositions(ctx, messageChan)
+			case <-ctx.Done():
 				c.log.Info("start method finished")
 				return
 			}
@@ -28,7 +29,7 @@ func (c *Client) StartGettingPropositions(messageChan chan interface{}) {
 //
 // we want method to returns only a few proposition at once
 // so we've created playlist and tracks options
-func (c *Client) getPropositions(propChan chan interface{}) {
+func (c *Client) getPropositions(ctx context.Context, propChan chan interface{}) {
 	log := c.log.WithFields(logrus.Fields{
 		"method": "getPropositions",
 		"userID": c.userID,
@@ -92,7 +93,7 @@ func (c *Client) getPropositions(propChan chan interface{}) {
 		// write seen album
 		seenAlbums[album.ID] = struct{}{}
 		for _, t := range at.Tracks {
-			propChan <- spotify_generator.Proposition{
+			p := spotify_generator.Proposition{
 				Meta: spotify_generator.Meta{
 					PlaylistInx: opts.playlistOptions.offset,
 					TrackInx:    opts.trackOptions.offset + i,
@@ -103,6 +104,11 @@ func (c *Client) getPropositions(propChan chan interface{}) {
 				Artists:   trimArtists(t.Artists),
 				Album:     album.Name,
 			}
+			select {
+			case propChan <- p:
+			case <-ctx.Done():
+				return
+			}
 		}
 	}
 
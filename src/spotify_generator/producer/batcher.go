@@ -0,0 +1,101 @@
+package producer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+// BatchConfig tells when buffered messages are written, batch is flushed when it reaches
+// Size messages or Bytes of keys, values and headers, or Linger passed since its first message,
+// zero value disables given limit
+type BatchConfig struct {
+	Size   int
+	Bytes  int
+	Linger time.Duration
+}
+
+// DefaultBatchConfig writes up to 100 messages or 1MB at once, waiting at most a second for more messages
+var DefaultBatchConfig = BatchConfig{Size: 100, Bytes: 1 << 20, Linger: time.Second}
+
+// writeFn writes messages to kafka, e.g. kafka.Writer.WriteMessages
+type writeFn func(ctx context.Context, msgs ...kafka.Message) error
+
+// batcher buffers messages of a single topic, every flush hands over the buffer
+// and starts a new one, so written messages are never modified
+type batcher struct {
+	log   logrus.FieldLogger
+	write writeFn
+	cfg   BatchConfig
+	// ctx is used by flushes triggered by linger timer
+	ctx context.Context
+
+	mu    sync.Mutex
+	buf   []kafka.Message
+	bytes int
+	timer *time.Timer
+
+	// writeMu keeps batches written in the order they were flushed
+	writeMu sync.Mutex
+}
+
+func newBatcher(ctx context.Context, log logrus.FieldLogger, write writeFn, cfg BatchConfig) *batcher {
+	return &batcher{
+		log:   log,
+		write: write,
+		cfg:   cfg,
+		ctx:   ctx,
+	}
+}
+
+// add buffers message and writes the batch if it's full
+func (b *batcher) add(ctx context.Context, m kafka.Message) {
+	b.mu.Lock()
+	b.buf = append(b.buf, m)
+	b.bytes += messageSize(m)
+	full := (b.cfg.Size > 0 && len(b.buf) >= b.cfg.Size) || (b.cfg.Bytes > 0 && b.bytes >= b.cfg.Bytes)
+	if !full && len(b.buf) == 1 && b.cfg.Linger > 0 {
+		b.timer = time.AfterFunc(b.cfg.Linger, func() { b.flush(b.ctx) })
+	}
+	b.mu.Unlock()
+
+	if full {
+		b.flush(ctx)
+	}
+}
+
+// flush writes buffered messages
+func (b *batcher) flush(ctx context.Context) {
+	b.writeMu.Lock()
+	defer b.writeMu.Unlock()
+
+	b.mu.Lock()
+	batch := b.buf
+	b.buf, b.bytes = nil, 0
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	b.mu.Unlock()
+
+	if len(batch) == 0 {
+		return
+	}
+	if err := b.write(ctx, batch...); err != nil {
+		b.log.WithField("len", len(batch)).WithError(err).Error("failed to write messages to kafka")
+		return
+	}
+	b.log.Infof("successfully written messages: %d", len(batch))
+}
+
+// messageSize returns number of bytes message takes in batch, without protocol overhead
+func messageSize(m kafka.Message) int {
+	size := len(m.Key) + len(m.Value)
+	for _, h := range m.Headers {
+		size += len(h.Key) + len(h.Value)
+	}
+	return size
+}
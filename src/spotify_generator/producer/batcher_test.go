@@ -0,0 +1,136 @@
+package producer
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+// recorder keeps written batches, so test can check they are not modified after being written
+type recorder struct {
+	mu      sync.Mutex
+	batches [][]kafka.Message
+	written chan struct{}
+}
+
+func newRecorder() *recorder {
+	return &recorder{written: make(chan struct{}, 1)}
+}
+
+func (r *recorder) write(_ context.Context, msgs ...kafka.Message) error {
+	r.mu.Lock()
+	r.batches = append(r.batches, msgs)
+	r.mu.Unlock()
+	select {
+	case r.written <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (r *recorder) values() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var values []string
+	for _, batch := range r.batches {
+		for _, m := range batch {
+			values = append(values, string(m.Value))
+		}
+	}
+	return values
+}
+
+func newTestBatcher(r *recorder, cfg BatchConfig) *batcher {
+	log, _ := test.NewNullLogger()
+	return newBatcher(context.Background(), log, r.write, cfg)
+}
+
+func message(i int) kafka.Message {
+	return kafka.Message{Value: []byte(strconv.Itoa(i))}
+}
+
+func TestBatcherFlushOnSize(t *testing.T) {
+	r := newRecorder()
+	b := newTestBatcher(r, BatchConfig{Size: 3})
+	for i := 0; i < 7; i++ {
+		b.add(context.Background(), message(i))
+	}
+	if len(r.batches) != 2 {
+		t.Fatalf("expected 2 full batches, got %d", len(r.batches))
+	}
+	b.flush(context.Background())
+
+	// no message is dropped, including the one which filled the batch
+	values := r.values()
+	if len(values) != 7 {
+		t.Fatalf("expected 7 messages, got %v", values)
+	}
+	for i, v := range values {
+		if v != strconv.Itoa(i) {
+			t.Fatalf("expected messages in order, got %v", values)
+		}
+	}
+}
+
+func TestBatcherFlushOnBytes(t *testing.T) {
+	r := newRecorder()
+	b := newTestBatcher(r, BatchConfig{Bytes: 10})
+	big := kafka.Message{Key: []byte("key"), Value: []byte("value")}
+	b.add(context.Background(), big)
+	if len(r.batches) != 0 {
+		t.Fatal("batch below byte limit should not be written")
+	}
+	b.add(context.Background(), big)
+	if len(r.batches) != 1 || len(r.batches[0]) != 2 {
+		t.Fatalf("expected batch of 2 messages, got %v", r.batches)
+	}
+}
+
+func TestBatcherFlushOnLinger(t *testing.T) {
+	r := newRecorder()
+	b := newTestBatcher(r, BatchConfig{Size: 100, Linger: 10 * time.Millisecond})
+	b.add(context.Background(), message(1))
+	select {
+	case <-r.written:
+	case <-time.After(time.Second):
+		t.Fatal("partial batch should be written after linger")
+	}
+	if values := r.values(); len(values) != 1 || values[0] != "1" {
+		t.Fatalf("unexpected messages %v", values)
+	}
+}
+
+func TestBatcherConcurrentAdds(t *testing.T) {
+	r := newRecorder()
+	b := newTestBatcher(r, BatchConfig{Size: 7, Linger: time.Millisecond})
+
+	wg := sync.WaitGroup{}
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				b.add(context.Background(), message(g*50+i))
+			}
+		}(g)
+	}
+	wg.Wait()
+	b.flush(context.Background())
+
+	// written batches are never reused, so every message is seen exactly once
+	seen := make(map[string]bool)
+	for _, v := range r.values() {
+		if seen[v] {
+			t.Fatalf("message %s written twice", v)
+		}
+		seen[v] = true
+	}
+	if len(seen) != 200 {
+		t.Fatalf("expected 200 messages, got %d", len(seen))
+	}
+}
@@ -2,17 +2,24 @@ package producer
 
 import (
 	"context"
-	"encoding/json"
+	"fmt"
+	"kafka-tryout/src/codec"
 	"kafka-tryout/src/spotify_generator"
+	"kafka-tryout/src/stream"
+	"kafka-tryout/src/utils"
 	"strconv"
-	"sync"
 	"time"
 
 	"github.com/segmentio/kafka-go"
 	"github.com/sirupsen/logrus"
 )
 
-const _chunkSize = 20
+// propositionSchemaVersion is the version of codec.SchemaProposition used for produced messages
+const propositionSchemaVersion = 1
+
+// CurrentlyPlayingSchemaVersion is the version of codec.SchemaCurrentlyPlaying produced without schema registry,
+// with schema registry commands produce schemaregistry.CurrentlyPlayingWireVersion
+const CurrentlyPlayingSchemaVersion = 2
 
 type PropositionHandler interface {
 	// Consume gets Proposition and handles it
@@ -20,167 +27,124 @@ type PropositionHandler interface {
 }
 
 type kafkaClient struct {
-	spotifyWriter *kafka.Writer
-	currWriter    *kafka.Writer
+	spotifyWriter stream.MessageWriter
+	currWriter    stream.MessageWriter
+	// topics written by the writers, as configured
+	spotifyTopic string
+	currTopic    string
 
-	ctx context.Context
 	log logrus.FieldLogger
 
 	index int
 
-	finish chan struct{}
-
-	spotifyChunk []kafka.Message
-	currChunk    []kafka.Message
-
-	chunkSize int
-	wg        *sync.WaitGroup
+	batch           BatchConfig
+	shutdownTimeout time.Duration
+	// currentlyPlayingVersion is the version of codec.SchemaCurrentlyPlaying used for produced messages
+	currentlyPlayingVersion int
 }
 
-func NewKafkaClient(spotifyW, currentlyW *kafka.Writer, log logrus.FieldLogger, ctx context.Context, index, chunkSize int, finish chan struct{}, wg *sync.WaitGroup) *kafkaClient {
+// NewKafkaClient creates client writing propositions with spotifyW to spotifyTopic and currently playing tracks
+// with currentlyW to currentlyTopic
+func NewKafkaClient(spotifyW, currentlyW stream.MessageWriter, spotifyTopic, currentlyTopic string, log logrus.FieldLogger, index int,
+	batch BatchConfig, shutdownTimeout time.Duration, currentlyPlayingVersion int) *kafkaClient {
 	return &kafkaClient{
-		spotifyWriter: spotifyW,
-		currWriter:    currentlyW,
-		log:           log,
-		ctx:           ctx,
-		index:         index,
-		finish:        finish,
-		chunkSize:     chunkSize,
-		spotifyChunk:  make([]kafka.Message, chunkSize),
-		currChunk:     make([]kafka.Message, chunkSize),
-		wg:            wg,
+		spotifyWriter:           spotifyW,
+		currWriter:              currentlyW,
+		spotifyTopic:            spotifyTopic,
+		currTopic:               currentlyTopic,
+		log:                     log,
+		index:                   index,
+		batch:                   batch,
+		shutdownTimeout:         shutdownTimeout,
+		currentlyPlayingVersion: currentlyPlayingVersion,
 	}
 }
 
-// Consume registers given channel and process data from it
-func (k *kafkaClient) Consume(messageChan chan interface{}) {
-	k.wg.Add(1)
-	spotifyCounter := 0
-	currCounter := 0
+// Consume processes data from given channel until ctx is done, then it sends
+// data already waiting in the channel and buffered batches within shutdown timeout
+func (k *kafkaClient) Consume(ctx context.Context, messageChan chan interface{}) {
+	drain, cancel := utils.WithShutdownDeadline(ctx, k.shutdownTimeout)
+	defer cancel()
 	k.log.Info("start consuming data")
 
-	go func() {
-		for {
-			select {
-			case <-k.finish:
-				k.log.Info("graceful shutdown")
-				k.wg.Done()
-				return
-			case data := <-messageChan:
-				var m kafka.Message
-
-				// handle data, what ever type it is
-				switch data.(type) {
-				// handle Proposition
-				case spotify_generator.Proposition:
-					k.log.Debug("handling proposition")
-					m = k.handleProposition(data.(spotify_generator.Proposition))
-					// process kafka message
-					if spotifyCounter >= k.chunkSize {
-						go k.sendSpotify(k.spotifyChunk)
-						spotifyCounter = 0
-					} else {
-						k.spotifyChunk[spotifyCounter] = m
-						spotifyCounter++
-					}
-				case spotify_generator.CurrentlyPlaying:
-					k.log.Debug("handling currently playing")
-					m = k.handleCurrentlyPlaying(data.(spotify_generator.CurrentlyPlaying))
-					// process kafka message
-					if currCounter >= k.chunkSize {
-						go k.sendCurr(k.currChunk)
-						currCounter = 0
-					} else {
-						k.currChunk[currCounter] = m
-						currCounter++
-					}
+	// every topic has its own buffer
+	spotify := newBatcher(drain, k.log.WithField("topic", k.spotifyTopic), k.spotifyWriter.WriteMessages, k.batch)
+	curr := newBatcher(drain, k.log.WithField("topic", k.currTopic), k.currWriter.WriteMessages, k.batch)
+
+	for {
+		select {
+		case <-ctx.Done():
+			k.log.Info("graceful shutdown")
+			for done := false; !done; {
+				select {
+				case data := <-messageChan:
+					k.handle(drain, data, spotify, curr)
+				default:
+					done = true
 				}
-
 			}
+			spotify.flush(drain)
+			curr.flush(drain)
+			return
+		case data := <-messageChan:
+			k.handle(drain, data, spotify, curr)
 		}
-	}()
-}
-
-// handleProposition generate kafka.Message from Proposition
-func (k kafkaClient) handleProposition(p spotify_generator.Proposition) kafka.Message {
-	m := kafka.Message{
-		Topic: "spotify",
-		Key:   []byte(p.TrackName),
-		Value: []byte(p.Album),
-		Headers: []kafka.Header{
-			{
-				Key:   "goroutine",
-				Value: []byte(strconv.Itoa(k.index)),
-			},
-		},
-		Time: time.Now(),
-	}
-
-	for _, artist := range p.Artists {
-		m.Headers = append(m.Headers, kafka.Header{
-			Key:   "artist",
-			Value: []byte(artist),
-		})
-	}
-	for k, v := range p.GetMeta() {
-		m.Headers = append(m.Headers, kafka.Header{
-			Key:   k,
-			Value: []byte(v),
-		})
 	}
-	return m
 }
 
-func (k *kafkaClient) handleCurrentlyPlaying(c spotify_generator.CurrentlyPlaying) kafka.Message {
-	m := kafka.Message{
-		Key:   []byte("track-name"),
-		Value: []byte(c.TrackName),
-		Headers: []kafka.Header{
-			{
-				Key:   "played-at",
-				Value: []byte(strconv.Itoa(c.PlayedAt.Second())),
-			},
-			{
-				Key:   "duration",
-				Value: []byte(strconv.Itoa(c.DurationMs)),
-			},
-		},
-		Time: time.Now(),
-	}
-	for _, artist := range c.Artists {
-		b, err := json.Marshal(artist)
+// handle handles data, what ever type it is
+func (k *kafkaClient) handle(ctx context.Context, data interface{}, spotify, curr *batcher) {
+	switch data := data.(type) {
+	// handle Proposition
+	case spotify_generator.Proposition:
+		k.log.Debug("handling proposition")
+		m, err := k.handleProposition(data)
+		if err != nil {
+			k.log.WithError(err).Error("failed to handle proposition")
+			return
+		}
+		spotify.add(ctx, m)
+	case spotify_generator.CurrentlyPlaying:
+		k.log.Debug("handling currently playing")
+		m, err := k.handleCurrentlyPlaying(data)
 		if err != nil {
-			k.log.WithError(err).Error("failed to marshal artist")
-			return m
+			k.log.WithError(err).Error("failed to handle currently playing")
+			return
 		}
-		m.Headers = append(m.Headers, kafka.Header{
-			Key:   "artist",
-			Value: b,
-		})
+		curr.add(ctx, m)
 	}
-	return m
 }
 
-// sendSpotify sends given messages to kafka cluster
-func (k kafkaClient) sendSpotify(messages []kafka.Message) {
-	if err := k.spotifyWriter.WriteMessages(k.ctx, messages...); err != nil {
-		k.log.WithFields(logrus.Fields{
-			"method": "sendSpotify",
-			"len":    len(messages),
-		}).WithError(err).Error("failed to write messages to kafka")
-	} else {
-		k.log.Infof("successfully writen spotify messages: %d", len(messages))
+// handleProposition generate kafka.Message from Proposition
+func (k *kafkaClient) handleProposition(p spotify_generator.Proposition) (kafka.Message, error) {
+	value, headers, err := codec.Default.Encode(codec.SchemaProposition, propositionSchemaVersion, p)
+	if err != nil {
+		return kafka.Message{}, fmt.Errorf("failed to encode proposition, %w", err)
 	}
+	return kafka.Message{
+		Topic: k.spotifyTopic,
+		Key:   []byte(p.TrackName),
+		Value: value,
+		Headers: append([]kafka.Header{
+			{
+				Key:   "goroutine",
+				Value: []byte(strconv.Itoa(k.index)),
+			},
+		}, headers...),
+		Time: time.Now(),
+	}, nil
 }
 
-// sendCurr sends given messages to kafka cluster
-func (k kafkaClient) sendCurr(messages []kafka.Message) {
-	if err := k.currWriter.WriteMessages(k.ctx, messages...); err != nil {
-		k.log.WithFields(logrus.Fields{
-			"method": "sendCurr",
-			"len":    len(messages),
-		}).WithError(err).Error("failed to write messages to kafka")
-	} else {
-		k.log.Infof("successfully writen curr messages: %d", len(messages))
+// handleCurrentlyPlaying generate kafka.Message from CurrentlyPlaying
+func (k *kafkaClient) handleCurrentlyPlaying(c spotify_generator.CurrentlyPlaying) (kafka.Message, error) {
+	value, headers, err := codec.Default.Encode(codec.SchemaCurrentlyPlaying, k.currentlyPlayingVersion, c)
+	if err != nil {
+		return kafka.Message{}, fmt.Errorf("failed to encode currently playing, %w", err)
 	}
+	return kafka.Message{
+		Key:     []byte("track-name"),
+		Value:   value,
+		Headers: headers,
+		Time:    time.Now(),
+	}, nil
 }
@@ -0,0 +1,114 @@
+package stream
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Metrics counts messages passing through decorated writers and readers
+type Metrics struct {
+	messages int64
+	bytes    int64
+	errors   int64
+}
+
+// MetricsSnapshot is a point in time copy of Metrics
+type MetricsSnapshot struct {
+	Messages int64
+	Bytes    int64
+	Errors   int64
+}
+
+func (m *Metrics) Snapshot() MetricsSnapshot {
+	return MetricsSnapshot{
+		Messages: atomic.LoadInt64(&m.messages),
+		Bytes:    atomic.LoadInt64(&m.bytes),
+		Errors:   atomic.LoadInt64(&m.errors),
+	}
+}
+
+func (m *Metrics) record(err error, msgs ...kafka.Message) {
+	if err != nil {
+		atomic.AddInt64(&m.errors, 1)
+		return
+	}
+	atomic.AddInt64(&m.messages, int64(len(msgs)))
+	for _, msg := range msgs {
+		atomic.AddInt64(&m.bytes, int64(len(msg.Key)+len(msg.Value)))
+	}
+}
+
+type metricsWriter struct {
+	MessageWriter
+	metrics *Metrics
+}
+
+// WriterWithMetrics records written messages in m
+func WriterWithMetrics(w MessageWriter, m *Metrics) MessageWriter {
+	return &metricsWriter{MessageWriter: w, metrics: m}
+}
+
+func (w *metricsWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	err := w.MessageWriter.WriteMessages(ctx, msgs...)
+	w.metrics.record(err, msgs...)
+	return err
+}
+
+type metricsReader struct {
+	MessageReader
+	metrics *Metrics
+}
+
+// ReaderWithMetrics records fetched messages in m
+func ReaderWithMetrics(r MessageReader, m *Metrics) MessageReader {
+	return &metricsReader{MessageReader: r, metrics: m}
+}
+
+func (r *metricsReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	msg, err := r.MessageReader.FetchMessage(ctx)
+	if ctx.Err() == nil {
+		r.metrics.record(err, msg)
+	}
+	return msg, err
+}
+
+type rateLimitedWriter struct {
+	MessageWriter
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// RateLimited writes at most perSecond messages per second, writes wait until they fit the limit
+func RateLimited(w MessageWriter, perSecond int) MessageWriter {
+	if perSecond < 1 {
+		return w
+	}
+	return &rateLimitedWriter{MessageWriter: w, interval: time.Second / time.Duration(perSecond)}
+}
+
+func (w *rateLimitedWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	// every write reserves time for its messages, so concurrent writes share the limit
+	w.mu.Lock()
+	now := time.Now()
+	if w.next.Before(now) {
+		w.next = now
+	}
+	start := w.next
+	w.next = w.next.Add(time.Duration(len(msgs)) * w.interval)
+	w.mu.Unlock()
+
+	if wait := time.Until(start); wait > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+	return w.MessageWriter.WriteMessages(ctx, msgs...)
+}
@@ -0,0 +1,65 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// printedMessage is the JSON line written by stdout writer, value is printed as text if it's valid JSON
+type printedMessage struct {
+	Topic   string            `json:"topic"`
+	Key     string            `json:"key,omitempty"`
+	Value   json.RawMessage   `json:"value,omitempty"`
+	Raw     []byte            `json:"raw,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Time    time.Time         `json:"time"`
+}
+
+type stdoutWriter struct {
+	mu    sync.Mutex
+	out   io.Writer
+	topic string
+}
+
+// NewStdoutWriter returns MessageWriter printing messages as JSON lines to out, e.g. os.Stdout
+func NewStdoutWriter(out io.Writer, topic string) MessageWriter {
+	return &stdoutWriter{out: out, topic: topic}
+}
+
+func (w *stdoutWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	enc := json.NewEncoder(w.out)
+	for _, m := range msgs {
+		p := printedMessage{Topic: w.topic, Key: string(m.Key), Time: m.Time}
+		if json.Valid(m.Value) {
+			p.Value = m.Value
+		} else {
+			p.Raw = m.Value
+		}
+		if len(m.Headers) > 0 {
+			p.Headers = make(map[string]string, len(m.Headers))
+			for _, h := range m.Headers {
+				p.Headers[h.Key] = string(h.Value)
+			}
+		}
+		if p.Time.IsZero() {
+			p.Time = time.Now()
+		}
+		if err := enc.Encode(p); err != nil {
+			return fmt.Errorf("failed to print message, %w", err)
+		}
+	}
+	return nil
+}
+
+func (w *stdoutWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,28 @@
+// Package stream defines contract of message backends used by producers and consumers,
+// kafka.Writer and kafka.Reader implement it, as well as test, file and stdout backends and decorators
+package stream
+
+import (
+	"context"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// MessageWriter writes messages to a single topic
+type MessageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// MessageReader reads messages of a topic as a member of consumer group, offsets are committed explicitly
+type MessageReader interface {
+	// FetchMessage blocks until message is available or ctx is done
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+var (
+	_ MessageWriter = (*kafka.Writer)(nil)
+	_ MessageReader = (*kafka.Reader)(nil)
+)
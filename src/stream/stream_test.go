@@ -0,0 +1,90 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+type failingWriter struct {
+	fail bool
+}
+
+func (w *failingWriter) WriteMessages(context.Context, ...kafka.Message) error {
+	if w.fail {
+		return errors.New("broker unavailable")
+	}
+	return nil
+}
+
+func (w *failingWriter) Close() error { return nil }
+
+func TestWriterWithMetrics(t *testing.T) {
+	inner := &failingWriter{}
+	m := &Metrics{}
+	w := WriterWithMetrics(inner, m)
+
+	msgs := []kafka.Message{{Key: []byte("USD"), Value: []byte("1.18")}, {Key: []byte("PLN"), Value: []byte("4.42")}}
+	if err := w.WriteMessages(context.Background(), msgs...); err != nil {
+		t.Fatal(err)
+	}
+	inner.fail = true
+	if err := w.WriteMessages(context.Background(), msgs...); err == nil {
+		t.Fatal("expected error")
+	}
+	if s := m.Snapshot(); s.Messages != 2 || s.Bytes != 14 || s.Errors != 1 {
+		t.Fatalf("unexpected metrics %+v", s)
+	}
+}
+
+func TestRateLimited(t *testing.T) {
+	w := RateLimited(&failingWriter{}, 100)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if err := w.WriteMessages(context.Background(), kafka.Message{}, kafka.Message{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 10 messages at 100 per second, the first one goes right away
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Fatalf("writes were not limited, took %s", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := w.WriteMessages(ctx, make([]kafka.Message, 100)...); !errors.Is(err, context.Canceled) {
+		t.Fatalf("waiting write should be cancelled, got %v", err)
+	}
+}
+
+func TestStdoutWriter(t *testing.T) {
+	var out bytes.Buffer
+	w := NewStdoutWriter(&out, "currencies")
+	err := w.WriteMessages(context.Background(),
+		kafka.Message{Key: []byte("USD"), Value: []byte(`{"Rate":1.18}`), Headers: []kafka.Header{{Key: "schema", Value: []byte("rate")}}},
+		kafka.Message{Key: []byte("PLN"), Value: []byte{0x0a, 0x03}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dec := json.NewDecoder(&out)
+	var first, second printedMessage
+	if err := dec.Decode(&first); err != nil {
+		t.Fatal(err)
+	}
+	if err := dec.Decode(&second); err != nil {
+		t.Fatal(err)
+	}
+	if first.Topic != "currencies" || string(first.Value) != `{"Rate":1.18}` || first.Headers["schema"] != "rate" {
+		t.Fatalf("unexpected JSON message %+v", first)
+	}
+	if !bytes.Equal(second.Raw, []byte{0x0a, 0x03}) || second.Value != nil {
+		t.Fatalf("binary value should be printed raw, got %+v", second)
+	}
+}
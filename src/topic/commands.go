@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"kafka-tryout/src/admin"
+	"kafka-tryout/src/config"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// tool runs commands of topic CLI against cluster
+type tool struct {
+	log   logrus.FieldLogger
+	admin admin.Admin
+	out   printer
+	cfg   config.Config
+}
+
+func (t *tool) run(ctx context.Context, command string, args []string) error {
+	commands := map[string]func(context.Context, []string) error{
+		"list":           t.list,
+		"describe":       t.describe,
+		"create":         t.create,
+		"delete":         t.delete,
+		"add-partitions": t.addPartitions,
+		"alter-configs":  t.alterConfigs,
+		"reconcile":      t.reconcile,
+	}
+	cmd, ok := commands[command]
+	if !ok {
+		return fmt.Errorf("unknown command %q", command)
+	}
+	return cmd(ctx, args)
+}
+
+func (t *tool) list(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	internal := fs.Bool("internal", false, "include internal topics, e.g. __consumer_offsets")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	names, err := t.admin.ListTopics(ctx, *internal)
+	if err != nil {
+		return err
+	}
+	return t.out.Names(names)
+}
+
+func (t *tool) describe(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("describe", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	infos, err := t.admin.DescribeTopics(ctx, fs.Args()...)
+	if err != nil {
+		return err
+	}
+	return t.out.Topics(infos)
+}
+
+func (t *tool) create(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("create", flag.ContinueOnError)
+	partitions := fs.Int("partitions", t.cfg.Topics.Partitions, "number of partitions")
+	replication := fs.Int("replication-factor", t.cfg.Topics.ReplicationFactor, "number of replicas of every partition")
+	configs := configFlags(fs)
+	names, err := parseTopics(fs, args)
+	if err != nil {
+		return err
+	}
+	cfgs, err := configs()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		spec := admin.TopicSpec{Name: name, Partitions: *partitions, ReplicationFactor: *replication, Configs: cfgs}
+		created, err := t.admin.CreateTopic(ctx, spec)
+		if err != nil {
+			return err
+		}
+		if created {
+			t.log.WithField("topic", name).Info("topic created")
+		} else {
+			t.log.WithField("topic", name).Info("topic already exists")
+		}
+	}
+	return nil
+}
+
+func (t *tool) delete(ctx context.Context, args []string) error {
+	names, err := parseTopics(flag.NewFlagSet("delete", flag.ContinueOnError), args)
+	if err != nil {
+		return err
+	}
+	if err := t.admin.DeleteTopics(ctx, names...); err != nil {
+		return err
+	}
+	t.log.WithField("topics", names).Info("topics deleted")
+	return nil
+}
+
+func (t *tool) addPartitions(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("add-partitions", flag.ContinueOnError)
+	count := fs.Int("count", 0, "total number of partitions, has to be larger than current one")
+	names, err := parseTopics(fs, args)
+	if err != nil {
+		return err
+	}
+	if *count <= 0 {
+		return errors.New("-count is required")
+	}
+	for _, name := range names {
+		if err := t.admin.CreatePartitions(ctx, name, *count); err != nil {
+			return err
+		}
+		t.log.WithField("topic", name).Infof("topic has %d partitions", *count)
+	}
+	return nil
+}
+
+func (t *tool) alterConfigs(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("alter-configs", flag.ContinueOnError)
+	configs := configFlags(fs)
+	names, err := parseTopics(fs, args)
+	if err != nil {
+		return err
+	}
+	cfgs, err := configs()
+	if err != nil {
+		return err
+	}
+	if len(cfgs) == 0 {
+		return errors.New("no configs given")
+	}
+	for _, name := range names {
+		if err := t.admin.AlterConfigs(ctx, name, cfgs); err != nil {
+			return err
+		}
+		t.log.WithField("topic", name).Infof("configs set: %v", cfgs)
+	}
+	return nil
+}
+
+func (t *tool) reconcile(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "only print the plan")
+	force := fs.Bool("force", false, "apply destructive changes, topics are deleted and created again")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("reconcile needs manifest file")
+	}
+	m, err := admin.LoadManifest(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	changes, err := admin.Plan(ctx, t.admin, m)
+	if err != nil {
+		return err
+	}
+	if err := t.out.Plan(changes); err != nil {
+		return err
+	}
+	if *dryRun || len(changes) == 0 {
+		return nil
+	}
+	if err := admin.Apply(ctx, t.admin, changes, *force); err != nil {
+		return err
+	}
+	t.log.Infof("%d changes applied", len(changes))
+	return nil
+}
+
+// parseTopics parses args with fs and returns topic names left after flags
+func parseTopics(fs *flag.FlagSet, args []string) ([]string, error) {
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() == 0 {
+		return nil, fmt.Errorf("%s needs at least one topic", fs.Name())
+	}
+	return fs.Args(), nil
+}
+
+// configValues is repeatable name=value flag
+type configValues map[string]string
+
+func (c configValues) String() string {
+	return fmt.Sprint(map[string]string(c))
+}
+
+func (c configValues) Set(s string) error {
+	i := strings.Index(s, "=")
+	if i <= 0 {
+		return fmt.Errorf("expected name=value, got %q", s)
+	}
+	c[s[:i]] = s[i+1:]
+	return nil
+}
+
+// configFlags registers topic config flags in fs, returned func collects configs set by them after parsing,
+// -set with empty value resets config to broker's default
+func configFlags(fs *flag.FlagSet) func() (map[string]string, error) {
+	values := configValues{}
+	fs.Var(values, "set", "topic config as name=value, can be repeated, e.g. -set max.message.bytes=2000000")
+	retention := fs.Duration("retention", 0, "retention.ms, how long messages are kept")
+	segment := fs.Duration("segment", 0, "segment.ms, how often log segment is rolled")
+	cleanup := fs.String("cleanup-policy", "", "cleanup.policy, delete, compact or compact,delete")
+
+	return func() (map[string]string, error) {
+		for name, d := range map[string]time.Duration{"retention.ms": *retention, "segment.ms": *segment} {
+			if d < 0 {
+				return nil, fmt.Errorf("negative %s, use -set %s=-1 for infinite", name, name)
+			}
+			if d > 0 {
+				values[name] = strconv.FormatInt(int64(d/time.Millisecond), 10)
+			}
+		}
+		if *cleanup != "" {
+			values["cleanup.policy"] = *cleanup
+		}
+		if len(values) == 0 {
+			return nil, nil
+		}
+		return values, nil
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"kafka-tryout/src/admin"
+	"strings"
+	"testing"
+)
+
+func TestConfigFlags(t *testing.T) {
+	fs := flag.NewFlagSet("create", flag.ContinueOnError)
+	configs := configFlags(fs)
+	names, err := parseTopics(fs, []string{"-retention", "24h", "-cleanup-policy", "compact", "-set", "segment.ms=20000", "-set", "min.insync.replicas=", "logs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || names[0] != "logs" {
+		t.Fatalf("unexpected topics %v", names)
+	}
+	got, err := configs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"retention.ms": "86400000", "cleanup.policy": "compact", "segment.ms": "20000", "min.insync.replicas": ""}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for name, value := range want {
+		if v, ok := got[name]; !ok || v != value {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+
+	fs = flag.NewFlagSet("create", flag.ContinueOnError)
+	configs = configFlags(fs)
+	if _, err := parseTopics(fs, []string{"logs"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := configs(); err != nil || got != nil {
+		t.Fatalf("expected no configs, got %v, %v", got, err)
+	}
+	if err := fs.Set("set", "retention.ms"); err == nil {
+		t.Fatal("expected error of config without value")
+	}
+}
+
+func TestPrinters(t *testing.T) {
+	topics := []admin.TopicInfo{{
+		Name:              "logs",
+		ReplicationFactor: 2,
+		Partitions:        []admin.PartitionInfo{{ID: 0, Leader: 1, Replicas: []int{1, 2}, ISR: []int{1}}},
+		Configs:           map[string]string{"segment.ms": "20000", "cleanup.policy": "compact"},
+	}}
+
+	var buf bytes.Buffer
+	p, _ := newPrinter(outputTable, &buf)
+	if err := p.Topics(topics); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || strings.Join(strings.Fields(lines[1]), " ") != "logs 0 1 1,2 1" ||
+		lines[2] != "logs configs: cleanup.policy=compact segment.ms=20000" {
+		t.Fatalf("unexpected table:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := p.Plan([]admin.Change{{Action: admin.ActionRecreate, Spec: admin.TopicSpec{Name: "test2"}, Reasons: []string{"partitions 12, want 6"}}}); err != nil {
+		t.Fatal(err)
+	}
+	lines = strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[1], "!") || !strings.Contains(lines[1], "partitions 12, want 6") {
+		t.Fatalf("unexpected plan:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	p, _ = newPrinter(outputJSON, &buf)
+	if err := p.Names(nil); err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(buf.String()) != "[]" {
+		t.Fatalf("expected empty array, got %s", buf.String())
+	}
+
+	if _, err := newPrinter("yaml", &buf); err == nil {
+		t.Fatal("expected unknown output error")
+	}
+}
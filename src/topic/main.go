@@ -1,45 +1,72 @@
 package main
 
 import (
-	"context"
+	"flag"
 	"fmt"
-	"kafka-tryout/src/kafka_server"
-	"time"
+	"kafka-tryout/src/admin"
+	"kafka-tryout/src/config"
+	"kafka-tryout/src/security"
+	"kafka-tryout/src/utils"
+	"os"
 
 	"github.com/segmentio/kafka-go"
 	"github.com/sirupsen/logrus"
 )
 
+const usage = `usage: topic [flags] <command> [command flags] [topics]
+
+commands:
+  list            lists topic names, -internal includes internal topics
+  describe        describes partitions and configs of topics, all topics if none given
+  create          creates topics, topic existing with the same spec isn't an error
+  delete          deletes topics
+  add-partitions  raises number of partitions of topics to -count
+  alter-configs   sets configs of topics, e.g. -retention 24h -set max.message.bytes=2000000
+  reconcile       brings topics to state of YAML manifest, destructive changes need -force, e.g. reconcile topics.yaml
+
+flags:
+`
+
 func main() {
-	if err := CreateTopic(logrus.New()); err != nil {
-		panic(err)
-	}
-}
+	log := logrus.New()
+	logger := log.WithField("application", "Topic")
 
-func CreateTopic(log logrus.FieldLogger) error {
-	cli := kafka.Client{
-		Addr:      kafka.TCP(kafka_server.Address),
-		Timeout:   10 * time.Second,
-		Transport: nil,
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	output := flag.String("output", outputTable, "output format of list and describe, table or json")
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		logger.WithError(err).Fatal("invalid configuration")
 	}
-	resp, err := cli.CreateTopics(context.Background(), &kafka.CreateTopicsRequest{
-		Addr: kafka.TCP(kafka_server.Address),
-		Topics: []kafka.TopicConfig{
-			{
-				Topic:         "currently-playing",
-				NumPartitions: 10,
-				//  replication factor: x larger than available brokers: 1
-				ReplicationFactor:  1,
-				ReplicaAssignments: nil,
-				// https://docs.confluent.io/current/installation/configuration/topic-configs.html
-				ConfigEntries: nil,
-			},
-		},
-		ValidateOnly: false,
-	})
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	p, err := newPrinter(*output, os.Stdout)
+	if err != nil {
+		logger.WithError(err).Fatal("invalid output")
+	}
+
+	sec, err := security.FromConfig(cfg)
 	if err != nil {
-		return fmt.Errorf("failed to craete topic, %w", err)
+		logger.WithError(err).Fatal("failed to configure connection security")
+	}
+	t := &tool{
+		log: logger,
+		admin: admin.NewAdmin(&kafka.Client{
+			Addr:      kafka.TCP(cfg.Brokers...),
+			Timeout:   admin.DefaultTimeout,
+			Transport: sec.Transport(),
+		}),
+		out: p,
+		cfg: cfg,
+	}
+
+	ctx, cancel := utils.SignalContext()
+	defer cancel()
+	if err := t.run(ctx, flag.Arg(0), flag.Args()[1:]); err != nil {
+		logger.WithError(err).Fatal(flag.Arg(0) + " failed")
 	}
-	log.Infof("create topic resp: %+v", resp)
-	return nil
 }
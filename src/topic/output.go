@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"kafka-tryout/src/admin"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// printer writes results of list, describe and reconcile commands
+type printer interface {
+	Names(names []string) error
+	Topics(topics []admin.TopicInfo) error
+	Plan(changes []admin.Change) error
+}
+
+func newPrinter(format string, w io.Writer) (printer, error) {
+	switch format {
+	case outputTable:
+		return &tablePrinter{w: w}, nil
+	case outputJSON:
+		return &jsonPrinter{w: w}, nil
+	}
+	return nil, fmt.Errorf("unknown output %q, expected %s or %s", format, outputTable, outputJSON)
+}
+
+type tablePrinter struct {
+	w io.Writer
+}
+
+func (p *tablePrinter) Names(names []string) error {
+	for _, name := range names {
+		if _, err := fmt.Fprintln(p.w, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Topics prints partitions table and configs of every topic, e.g.
+// TOPIC  PARTITION  LEADER  REPLICAS  ISR
+// logs   0          1       1,2       1,2
+// logs configs: cleanup.policy=compact segment.ms=20000
+func (p *tablePrinter) Topics(topics []admin.TopicInfo) error {
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TOPIC\tPARTITION\tLEADER\tREPLICAS\tISR")
+	for _, t := range topics {
+		for _, part := range t.Partitions {
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\n", t.Name, part.ID, part.Leader, joinInts(part.Replicas), joinInts(part.ISR))
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	for _, t := range topics {
+		if len(t.Configs) == 0 {
+			continue
+		}
+		configs := make([]string, 0, len(t.Configs))
+		for name, value := range t.Configs {
+			configs = append(configs, name+"="+value)
+		}
+		sort.Strings(configs)
+		if _, err := fmt.Fprintf(p.w, "%s configs: %s\n", t.Name, strings.Join(configs, " ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Plan prints changes one per line, destructive ones are marked with "!"
+func (p *tablePrinter) Plan(changes []admin.Change) error {
+	if len(changes) == 0 {
+		_, err := fmt.Fprintln(p.w, "topics match manifest")
+		return err
+	}
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "\tACTION\tTOPIC\tREASONS")
+	for _, c := range changes {
+		mark := ""
+		if c.Destructive() {
+			mark = "!"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", mark, c.Action, c.Spec.Name, strings.Join(c.Reasons, ", "))
+	}
+	return tw.Flush()
+}
+
+type jsonPrinter struct {
+	w io.Writer
+}
+
+func (p *jsonPrinter) Names(names []string) error {
+	if names == nil {
+		names = []string{}
+	}
+	return p.encode(names)
+}
+
+func (p *jsonPrinter) Topics(topics []admin.TopicInfo) error {
+	if topics == nil {
+		topics = []admin.TopicInfo{}
+	}
+	return p.encode(topics)
+}
+
+func (p *jsonPrinter) Plan(changes []admin.Change) error {
+	if changes == nil {
+		changes = []admin.Change{}
+	}
+	return p.encode(changes)
+}
+
+func (p *jsonPrinter) encode(v interface{}) error {
+	enc := json.NewEncoder(p.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func joinInts(ints []int) string {
+	s := make([]string, len(ints))
+	for i, n := range ints {
+		s[i] = fmt.Sprint(n)
+	}
+	return strings.Join(s, ",")
+}
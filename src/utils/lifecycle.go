@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// DefaultShutdownTimeout is the time given to drain in-flight work after shutdown was requested
+const DefaultShutdownTimeout = 10 * time.Second
+
+// SignalContext returns context cancelled on SIGINT or SIGTERM, the second signal kills the process
+func SignalContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-signals:
+			cancel()
+		case <-ctx.Done():
+			signal.Stop(signals)
+			return
+		}
+		<-signals
+		os.Exit(1)
+	}()
+	return ctx, cancel
+}
+
+// WithShutdownDeadline returns context which outlives ctx by timeout, it's used to finish
+// in-flight work after ctx is cancelled, without letting it last forever
+func WithShutdownDeadline(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	drain, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-drain.Done():
+			return
+		}
+		select {
+		case <-time.After(timeout):
+			cancel()
+		case <-drain.Done():
+		}
+	}()
+	return drain, cancel
+}
+
+// CloseWithin closes c, giving up after timeout
+func CloseWithin(c io.Closer, timeout time.Duration) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Close()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		return fmt.Errorf("failed to close within %s", timeout)
+	}
+}
@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWithShutdownDeadline(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	drain, cancelDrain := WithShutdownDeadline(ctx, 20*time.Millisecond)
+	defer cancelDrain()
+
+	cancel()
+	select {
+	case <-drain.Done():
+		t.Fatal("drain context should outlive parent by timeout")
+	case <-time.After(5 * time.Millisecond):
+	}
+	select {
+	case <-drain.Done():
+	case <-time.After(time.Second):
+		t.Fatal("drain context should be cancelled after timeout")
+	}
+}
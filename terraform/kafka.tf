@@ -4,6 +4,8 @@ provider "kafka" {
   tls_enabled = false
 }
 
+// keep in sync with topics.yaml, which is applied by: cd src && go run ./topic reconcile ../topics.yaml,
+// except replication factor, topics.yaml has 1 for the single broker of local compose
 resource "kafka_topic" "logs" {
   name               = "logs"
   // Every topic partition in Kafka is replicated n times, where n is the replication factor of the topic.